@@ -0,0 +1,319 @@
+// Package cachecontrol computes HTTP cache policies from @cacheControl hints
+// and caches whole responses of cacheable queries
+package cachecontrol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql/language/ast"
+)
+
+// TypeDefs declares the @cacheControl directive, add it to the TypeDefs of
+// the schema
+const TypeDefs = `
+enum CacheControlScope {
+	PUBLIC
+	PRIVATE
+}
+
+directive @cacheControl(
+	maxAge: Int
+	scope: CacheControlScope
+	inheritMaxAge: Boolean
+) on FIELD_DEFINITION | OBJECT | INTERFACE | UNION
+`
+
+// Scope is the audience of a cacheable response
+type Scope string
+
+// Scopes
+const (
+	ScopePublic  Scope = "PUBLIC"
+	ScopePrivate Scope = "PRIVATE"
+)
+
+// Hint is the @cacheControl hint of a type or field
+type Hint struct {
+	MaxAge        *int
+	Scope         Scope
+	InheritMaxAge bool
+}
+
+// Config configures cache control
+type Config struct {
+	// DefaultMaxAge applies to root fields and fields returning composite
+	// types without a maxAge hint, defaults to 0 which is uncacheable
+	DefaultMaxAge int
+
+	// Store enables the whole response cache
+	Store Store
+
+	// SessionIDFn identifies the user of a request so responses with a
+	// private scope can be cached, they are not cached without it
+	SessionIDFn SessionIDFn
+}
+
+// CacheControl collects the @cacheControl hints of a schema and computes the
+// cache policy of its responses
+type CacheControl struct {
+	mx            sync.RWMutex
+	types         map[string]Hint
+	fields        map[string]Hint
+	defaultMaxAge int
+	store         Store
+	sessionIDFn   SessionIDFn
+}
+
+// New creates a new cache control
+func New(config Config) *CacheControl {
+	return &CacheControl{
+		types:         make(map[string]Hint),
+		fields:        make(map[string]Hint),
+		defaultMaxAge: config.DefaultMaxAge,
+		store:         config.Store,
+		sessionIDFn:   config.SessionIDFn,
+	}
+}
+
+// Directive records the hints of the schema, register it as the cacheControl
+// schema directive
+func (cc *CacheControl) Directive() *tools.SchemaDirectiveVisitor {
+	return &tools.SchemaDirectiveVisitor{
+		VisitObject: func(p tools.VisitObjectParams) error {
+			cc.setHint(cc.types, p.Config.Name, p.Args)
+			return nil
+		},
+		VisitInterface: func(p tools.VisitInterfaceParams) error {
+			cc.setHint(cc.types, p.Config.Name, p.Args)
+			return nil
+		},
+		VisitUnion: func(p tools.VisitUnionParams) error {
+			cc.setHint(cc.types, p.Config.Name, p.Args)
+			return nil
+		},
+		VisitFieldDefinition: func(p tools.VisitFieldDefinitionParams) error {
+			cc.setHint(cc.fields, p.ParentName+"."+p.Node.Name.Value, p.Args)
+			return nil
+		},
+	}
+}
+
+// Plugin computes the policy of the responses in contexts prepared with
+// WithPolicy and serves their queries from the response cache
+func (cc *CacheControl) Plugin() executor.Plugin {
+	return &plugin{cc: cc}
+}
+
+func (cc *CacheControl) setHint(hints map[string]Hint, name string, args map[string]any) {
+	hint := Hint{}
+	if maxAge, ok := toInt(args["maxAge"]); ok {
+		hint.MaxAge = &maxAge
+	}
+	if scope, ok := args["scope"].(string); ok {
+		hint.Scope = Scope(scope)
+	}
+	if inherit, ok := args["inheritMaxAge"].(bool); ok {
+		hint.InheritMaxAge = inherit
+	}
+
+	cc.mx.Lock()
+	hints[name] = hint
+	cc.mx.Unlock()
+}
+
+// Int directive arguments are coerced to int64, other integer types are
+// accepted as well
+func toInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+func (cc *CacheControl) typeHint(name string) Hint {
+	cc.mx.RLock()
+	defer cc.mx.RUnlock()
+	return cc.types[name]
+}
+
+func (cc *CacheControl) fieldHint(typeName, fieldName string) Hint {
+	cc.mx.RLock()
+	defer cc.mx.RUnlock()
+	return cc.fields[typeName+"."+fieldName]
+}
+
+// Policy is the cache policy of a response, the most restrictive policy of
+// the resolved fields
+type Policy struct {
+	mx       sync.Mutex
+	computed bool
+	maxAge   int
+	scope    Scope
+	doc      *ast.Document
+}
+
+type policyKey struct{}
+
+const maxInt = int(^uint(0) >> 1)
+
+// WithPolicy returns a context that collects the policy of the operation
+// executed with it
+func WithPolicy(ctx context.Context) (context.Context, *Policy) {
+	policy := &Policy{}
+	return context.WithValue(ctx, policyKey{}, policy), policy
+}
+
+func policyFrom(ctx context.Context) *Policy {
+	policy, _ := ctx.Value(policyKey{}).(*Policy)
+	return policy
+}
+
+// MaxAge returns the number of seconds the response may be cached
+func (p *Policy) MaxAge() int {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.maxAge
+}
+
+// Scope returns the audience of the response
+func (p *Policy) Scope() Scope {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.scope == "" {
+		return ScopePublic
+	}
+	return p.scope
+}
+
+// Cacheable reports whether the response may be cached
+func (p *Policy) Cacheable() bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.computed && p.maxAge > 0
+}
+
+// Header returns the value of the Cache-Control header, or an empty string
+// before the policy has been computed
+func (p *Policy) Header() string {
+	if p == nil {
+		return ""
+	}
+
+	p.mx.Lock()
+	computed := p.computed
+	p.mx.Unlock()
+
+	if !computed {
+		return ""
+	}
+	if !p.Cacheable() {
+		return "no-store"
+	}
+	if p.Scope() == ScopePrivate {
+		return fmt.Sprintf("max-age=%d, private", p.MaxAge())
+	}
+	return fmt.Sprintf("max-age=%d, public", p.MaxAge())
+}
+
+// restricts the policy by the max age and scope of a field
+func (p *Policy) restrict(maxAge int, scope Scope) {
+	if maxAge < p.maxAge {
+		p.maxAge = maxAge
+	}
+	if scope == ScopePrivate {
+		p.scope = ScopePrivate
+	}
+}
+
+// computes the policy of a result
+type plugin struct {
+	executor.NoopPlugin
+	cc *CacheControl
+}
+
+func (pl *plugin) OnExecute(ctx context.Context, req *executor.Request, doc *ast.Document) (context.Context, error) {
+	if policy := policyFrom(ctx); policy != nil {
+		policy.mx.Lock()
+		policy.doc = doc
+		policy.mx.Unlock()
+	}
+	return ctx, nil
+}
+
+// serves cached responses, only requests with a policy are served so that
+// transports that do not store responses are not answered from the cache
+func (pl *plugin) Lookup(ctx context.Context, req *executor.Request) (*graphql.Result, bool) {
+	if policyFrom(ctx) == nil {
+		return nil, false
+	}
+
+	res, ok := pl.cc.Lookup(req)
+	if !ok {
+		return nil, false
+	}
+
+	var result graphql.Result
+	if err := json.Unmarshal(res.Body, &result); err != nil {
+		return nil, false
+	}
+	return &result, true
+}
+
+func (pl *plugin) OnResult(ctx context.Context, req *executor.Request, result *graphql.Result) {
+	policy := policyFrom(ctx)
+	if policy == nil {
+		return
+	}
+
+	policy.mx.Lock()
+	defer policy.mx.Unlock()
+
+	policy.computed = true
+	policy.maxAge = 0
+
+	// only successful queries are cacheable
+	data, ok := result.Data.(map[string]any)
+	if policy.doc == nil || req.Schema == nil || result.HasErrors() || !ok {
+		return
+	}
+
+	w := &walker{
+		cc:        pl.cc,
+		schema:    req.Schema,
+		policy:    policy,
+		fragments: map[string]*ast.FragmentDefinition{},
+	}
+
+	var operation *ast.OperationDefinition
+	for _, def := range policy.doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			w.fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if req.OperationName == "" || (d.Name != nil && d.Name.Value == req.OperationName) {
+				operation = d
+			}
+		}
+	}
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return
+	}
+
+	// start unrestricted and let each resolved field lower the max age
+	policy.maxAge = maxInt
+	w.walk(operation.SelectionSet, req.Schema.QueryType(), data, 0, true)
+	if policy.maxAge == maxInt {
+		policy.maxAge = pl.cc.defaultMaxAge
+	}
+}
@@ -0,0 +1,269 @@
+package cachecontrol
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql-go-tools/executor"
+)
+
+func testExecutor(t *testing.T, cc *CacheControl) *executor.Executor {
+	post := map[string]any{"id": "1", "title": "hello", "votes": 3, "author": map[string]any{"name": "jane"}}
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: []string{TypeDefs, `
+type Post @cacheControl(maxAge: 240) {
+	id: ID!
+	title: String
+	votes: Int @cacheControl(maxAge: 30)
+	author: User
+}
+
+type User @cacheControl(maxAge: 60, scope: PRIVATE) {
+	name: String
+}
+
+type Query {
+	post: Post
+	latest: Post @cacheControl(maxAge: 10)
+	posts: [Post]
+	hello: String
+	cachedHello: String @cacheControl(maxAge: 100)
+	fail: String @cacheControl(maxAge: 100)
+}`},
+		Resolvers: tools.ResolverMap{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"post":        &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return post, nil }},
+					"latest":      &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return post, nil }},
+					"posts":       &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return []any{post, post}, nil }},
+					"hello":       &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "world", nil }},
+					"cachedHello": &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "world", nil }},
+					"fail":        &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return nil, errors.New("failed") }},
+				},
+			},
+		},
+		SchemaDirectives: tools.SchemaDirectiveVisitorMap{
+			"cacheControl": cc.Directive(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+
+	return executor.New(&executor.Config{
+		Schema:  &schema,
+		Plugins: []executor.Plugin{cc.Plugin()},
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	exec := testExecutor(t, New(Config{}))
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`{ post { id title } }`, "max-age=240, public"},
+		{`{ post { id votes } }`, "max-age=30, public"},
+		{`{ post { author { name } } }`, "max-age=60, private"},
+		{`{ latest { id } }`, "max-age=10, public"},
+		{`{ posts { id } latest { id } }`, "max-age=10, public"},
+		{`{ hello }`, "no-store"},
+		{`{ cachedHello post { id } }`, "max-age=100, public"},
+		{`{ cachedHello post { id } hello }`, "no-store"},
+		{`{ ...hello } fragment hello on Query { cachedHello }`, "max-age=100, public"},
+		{`{ ... on Query { post { votes } } }`, "max-age=30, public"},
+		{`{ fail }`, "no-store"},
+		{`mutation { hello }`, "no-store"},
+	}
+
+	for _, test := range tests {
+		ctx, policy := WithPolicy(context.Background())
+		exec.Execute(&executor.Request{Query: test.query, Context: ctx})
+		if header := policy.Header(); header != test.expected {
+			t.Errorf("expected %q for %s, got %q", test.expected, test.query, header)
+			return
+		}
+	}
+}
+
+func TestDefaultMaxAge(t *testing.T) {
+	exec := testExecutor(t, New(Config{DefaultMaxAge: 5}))
+
+	ctx, policy := WithPolicy(context.Background())
+	exec.Execute(&executor.Request{Query: `{ hello post { id } }`, Context: ctx})
+	if header := policy.Header(); header != "max-age=5, public" {
+		t.Errorf("expected the default max age, got %q", header)
+		return
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Errorf("failed to create file store: %v", err)
+		return
+	}
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": fileStore} {
+		cc := New(Config{
+			Store: store,
+			SessionIDFn: func(r *http.Request) string {
+				return r.Header.Get("Session")
+			},
+		})
+
+		public := &Policy{computed: true, maxAge: 60, scope: ScopePublic}
+		private := &Policy{computed: true, maxAge: 60, scope: ScopePrivate}
+		uncacheable := &Policy{computed: true}
+		variables := map[string]any{"id": "1"}
+
+		schema := testExecutor(t, cc).Schema()
+		request := func(r *http.Request, query string, variables map[string]any) *executor.Request {
+			return &executor.Request{Query: query, Variables: variables, HTTPRequest: r, Schema: schema}
+		}
+
+		anonymous := httptest.NewRequest(http.MethodGet, "/", nil)
+		jane := httptest.NewRequest(http.MethodGet, "/", nil)
+		jane.Header.Set("Session", "jane")
+		john := httptest.NewRequest(http.MethodGet, "/", nil)
+		john.Header.Set("Session", "john")
+
+		cc.Store(request(anonymous, "{ public }", variables), public, []byte(`{"data":{"public":1}}`))
+		cc.Store(request(anonymous, "{ private }", variables), private, []byte(`{"data":{"private":"anonymous"}}`))
+		cc.Store(request(jane, "{ private }", variables), private, []byte(`{"data":{"private":"jane"}}`))
+		cc.Store(request(anonymous, "{ uncacheable }", variables), uncacheable, []byte(`{"data":{"uncacheable":1}}`))
+
+		tests := []struct {
+			r        *http.Request
+			query    string
+			vars     map[string]any
+			expected string
+		}{
+			{anonymous, "{ public }", variables, `{"data":{"public":1}}`},
+			{john, "{ public }", variables, `{"data":{"public":1}}`},
+			{anonymous, "{ public }", map[string]any{"id": "2"}, ""},
+			{jane, "{ private }", variables, `{"data":{"private":"jane"}}`},
+			{john, "{ private }", variables, ""},
+			{anonymous, "{ private }", variables, ""},
+			{anonymous, "{ uncacheable }", variables, ""},
+		}
+
+		for _, test := range tests {
+			res, ok := cc.Lookup(request(test.r, test.query, test.vars))
+			body := ""
+			if ok {
+				body = string(res.Body)
+			}
+			if body != test.expected {
+				t.Errorf("%s: expected %q for %s, got %q", name, test.expected, test.query, body)
+				return
+			}
+		}
+
+		res, _ := cc.Lookup(request(jane, "{ private }", variables))
+		if res.CacheControl != "max-age=60, private" {
+			t.Errorf("%s: expected the cached Cache-Control header, got %q", name, res.CacheControl)
+			return
+		}
+
+		// responses are not served for another version of the schema
+		updated, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+			TypeDefs: []string{`type Query { public: String }`},
+		})
+		if err != nil {
+			t.Errorf("failed to make schema: %v", err)
+			return
+		}
+		if _, ok := cc.Lookup(&executor.Request{Query: "{ public }", Variables: variables, HTTPRequest: anonymous, Schema: &updated}); ok {
+			t.Errorf("%s: expected a miss after the schema changed", name)
+			return
+		}
+	}
+}
+
+// rejects every request
+type rejectPlugin struct {
+	executor.NoopPlugin
+}
+
+func (rejectPlugin) OnRequest(ctx context.Context, req *executor.Request) (context.Context, error) {
+	return ctx, errors.New("rejected")
+}
+
+func TestPluginLookup(t *testing.T) {
+	cc := New(Config{Store: NewMemoryStore()})
+	exec := testExecutor(t, cc)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ctx, policy := WithPolicy(context.Background())
+	req := &executor.Request{Query: `{ cachedHello }`, HTTPRequest: r, Context: ctx}
+	exec.Execute(req)
+	cc.Store(req, policy, []byte(`{"data":{"cachedHello":"cached"}}`))
+
+	ctx, policy = WithPolicy(context.Background())
+	req = &executor.Request{Query: `{ cachedHello }`, HTTPRequest: r, Context: ctx}
+	result := exec.Execute(req)
+	if !req.Cached || req.Executed {
+		t.Error("expected the result to be served from the cache")
+		return
+	}
+	if data, _ := result.Data.(map[string]any); data["cachedHello"] != "cached" {
+		t.Errorf("expected the cached result, got %v", result.Data)
+		return
+	}
+	if header := policy.Header(); header != "max-age=100, public" {
+		t.Errorf("expected the policy of the cached result, got %q", header)
+		return
+	}
+
+	// requests without a policy are executed
+	req = &executor.Request{Query: `{ cachedHello }`, HTTPRequest: r}
+	exec.Execute(req)
+	if req.Cached {
+		t.Error("expected a request without a policy to be executed")
+		return
+	}
+
+	// requests rejected by another plugin are not served from the cache
+	rejecting := executor.New(&executor.Config{
+		Schema:  exec.Schema(),
+		Plugins: []executor.Plugin{rejectPlugin{}, cc.Plugin()},
+	})
+	ctx, _ = WithPolicy(context.Background())
+	req = &executor.Request{Query: `{ cachedHello }`, HTTPRequest: r, Context: ctx}
+	result = rejecting.Execute(req)
+	if req.Cached || !result.HasErrors() {
+		t.Errorf("expected the request to be rejected, got %v", result.Data)
+		return
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Errorf("failed to create file store: %v", err)
+		return
+	}
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": fileStore} {
+		store.Set("short", []byte("short"), time.Millisecond)
+		store.Set("long", []byte("long"), time.Minute)
+		time.Sleep(5 * time.Millisecond)
+
+		if _, ok := store.Get("short"); ok {
+			t.Errorf("%s: expected the short entry to expire", name)
+			return
+		}
+		if value, ok := store.Get("long"); !ok || string(value) != "long" {
+			t.Errorf("%s: expected the long entry, got %q", name, value)
+			return
+		}
+	}
+}
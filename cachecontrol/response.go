@@ -0,0 +1,206 @@
+package cachecontrol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/executor"
+)
+
+// SessionIDFn identifies the user of a request, return an empty string for
+// anonymous requests
+type SessionIDFn func(r *http.Request) string
+
+// CachedResponse is a response served from the response cache
+type CachedResponse struct {
+	Body         []byte `json:"body"`
+	CacheControl string `json:"cacheControl"`
+}
+
+// Enabled reports whether the whole response cache is enabled
+func (cc *CacheControl) Enabled() bool {
+	return cc.store != nil
+}
+
+// Lookup gets the cached response of an operation, private responses of the
+// session take precedence over public responses. The plugin looks responses
+// up once the other plugins accepted the request
+func (cc *CacheControl) Lookup(req *executor.Request) (*CachedResponse, bool) {
+	if cc.store == nil {
+		return nil, false
+	}
+
+	key, ok := responseKey(req)
+	if !ok {
+		return nil, false
+	}
+
+	if session := cc.sessionID(req.HTTPRequest); session != "" {
+		if res, ok := cc.get(privateKey(key, session)); ok {
+			return res, true
+		}
+	}
+	return cc.get(key)
+}
+
+// Store caches the response of an operation for the max age of its policy.
+// uncacheable responses and private responses without a session are skipped
+func (cc *CacheControl) Store(req *executor.Request, policy *Policy, body []byte) {
+	if cc.store == nil || policy == nil || !policy.Cacheable() {
+		return
+	}
+
+	key, ok := responseKey(req)
+	if !ok {
+		return
+	}
+
+	if policy.Scope() == ScopePrivate {
+		session := cc.sessionID(req.HTTPRequest)
+		if session == "" {
+			return
+		}
+		key = privateKey(key, session)
+	}
+
+	buff, err := json.Marshal(&CachedResponse{Body: body, CacheControl: policy.Header()})
+	if err != nil {
+		return
+	}
+	cc.store.Set(key, buff, time.Duration(policy.MaxAge())*time.Second)
+}
+
+func (cc *CacheControl) get(key string) (*CachedResponse, bool) {
+	buff, ok := cc.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	var res CachedResponse
+	if err := json.Unmarshal(buff, &res); err != nil {
+		return nil, false
+	}
+	return &res, true
+}
+
+func (cc *CacheControl) sessionID(r *http.Request) string {
+	if cc.sessionIDFn == nil || r == nil {
+		return ""
+	}
+	return cc.sessionIDFn(r)
+}
+
+// hashes the schema and the operation, variables are marshaled with sorted
+// keys so equal variables produce equal keys. the query is the query after
+// plugins replaced a trusted document ID
+func responseKey(req *executor.Request) (string, bool) {
+	if req.Schema == nil {
+		return "", false
+	}
+
+	vars, err := json.Marshal(req.Variables)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(schemaHash(req.Schema)))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Query))
+	h.Write([]byte{0})
+	h.Write([]byte(req.OperationName))
+	h.Write([]byte{0})
+	h.Write(vars)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// hashes of the schemas responses were cached for
+var schemaHashes sync.Map
+
+// hashes the types and directives of a schema so that responses cached for
+// another version of the schema, in this process or a previous one using the
+// same store, are not served
+func schemaHash(schema *graphql.Schema) string {
+	if sum, ok := schemaHashes.Load(schema); ok {
+		return sum.(string)
+	}
+
+	h := sha256.New()
+	typeMap := schema.TypeMap()
+	names := make([]string, 0, len(typeMap))
+	for name := range typeMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(h, "%s %T\n", name, typeMap[name])
+		switch t := typeMap[name].(type) {
+		case *graphql.Object:
+			for _, iface := range t.Interfaces() {
+				fmt.Fprintf(h, "  implements %s\n", iface.Name())
+			}
+			hashFields(h, t.Fields())
+		case *graphql.Interface:
+			hashFields(h, t.Fields())
+		case *graphql.Union:
+			for _, object := range t.Types() {
+				fmt.Fprintf(h, "  %s\n", object.Name())
+			}
+		case *graphql.Enum:
+			for _, value := range t.Values() {
+				fmt.Fprintf(h, "  %s\n", value.Name)
+			}
+		case *graphql.InputObject:
+			fields := t.Fields()
+			fieldNames := make([]string, 0, len(fields))
+			for fieldName := range fields {
+				fieldNames = append(fieldNames, fieldName)
+			}
+			sort.Strings(fieldNames)
+			for _, fieldName := range fieldNames {
+				fmt.Fprintf(h, "  %s: %s\n", fieldName, fields[fieldName].Type)
+			}
+		}
+	}
+
+	for _, directive := range schema.Directives() {
+		fmt.Fprintf(h, "@%s %v\n", directive.Name, directive.Locations)
+		for _, arg := range directive.Args {
+			fmt.Fprintf(h, "  %s: %s\n", arg.Name(), arg.Type)
+		}
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	schemaHashes.Store(schema, sum)
+	return sum
+}
+
+func hashFields(h hash.Hash, fields graphql.FieldDefinitionMap) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := fields[name]
+		fmt.Fprintf(h, "  %s: %s\n", name, field.Type)
+		for _, arg := range field.Args {
+			fmt.Fprintf(h, "    %s: %s\n", arg.Name(), arg.Type)
+		}
+	}
+}
+
+func privateKey(key, session string) string {
+	sum := sha256.Sum256([]byte(key + "\x00" + session))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,127 @@
+package cachecontrol
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store stores cached responses until their ttl expires
+type Store interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+type storeEntry struct {
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+func (e *storeEntry) expired() bool {
+	return time.Now().After(e.Expires)
+}
+
+// MemoryStore stores responses in memory, expired entries are removed when
+// they are read or when new entries are set
+type MemoryStore struct {
+	mx      sync.Mutex
+	entries map[string]*storeEntry
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*storeEntry),
+	}
+}
+
+// Get gets an entry that has not expired
+func (s *MemoryStore) Get(key string) ([]byte, bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if entry.expired() {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// Set sets an entry that expires after ttl
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for k, entry := range s.entries {
+		if entry.expired() {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = &storeEntry{Value: value, Expires: time.Now().Add(ttl)}
+}
+
+// FileStore stores responses as files in a directory so they survive
+// restarts and can be shared between processes
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a new store in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Get gets an entry that has not expired
+func (s *FileStore) Get(key string) ([]byte, bool) {
+	path := s.path(key)
+	buff, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry storeEntry
+	if err := json.Unmarshal(buff, &entry); err != nil || entry.expired() {
+		os.Remove(path)
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// Set sets an entry that expires after ttl. the entry is written to a
+// temporary file first so readers never see a partial entry
+func (s *FileStore) Set(key string, value []byte, ttl time.Duration) {
+	buff, err := json.Marshal(&storeEntry{Value: value, Expires: time.Now().Add(ttl)})
+	if err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(buff)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// keys are hashes so they are safe to use as file names
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key)
+}
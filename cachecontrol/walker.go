@@ -0,0 +1,119 @@
+package cachecontrol
+
+import (
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/language/ast"
+)
+
+// walks the selections of an operation along the response data so only the
+// fields that were resolved restrict the policy
+type walker struct {
+	cc        *CacheControl
+	schema    *graphql.Schema
+	policy    *Policy
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// walks a selection set of parentType. parentMaxAge is inherited by leaf
+// fields and fields with inheritMaxAge
+func (w *walker) walk(set *ast.SelectionSet, parentType graphql.Type, data map[string]any, parentMaxAge int, root bool) {
+	if set == nil {
+		return
+	}
+
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			key := s.Name.Value
+			if s.Alias != nil {
+				key = s.Alias.Value
+			}
+			value, ok := data[key]
+			if !ok || s.Name.Value == "__typename" {
+				continue
+			}
+			w.field(s, parentType, value, parentMaxAge, root)
+
+		case *ast.InlineFragment:
+			fragmentType := parentType
+			if s.TypeCondition != nil {
+				fragmentType = w.schema.Type(s.TypeCondition.Name.Value)
+			}
+			w.walk(s.SelectionSet, fragmentType, data, parentMaxAge, root)
+
+		case *ast.FragmentSpread:
+			if fragment, ok := w.fragments[s.Name.Value]; ok {
+				w.walk(fragment.SelectionSet, w.schema.Type(fragment.TypeCondition.Name.Value), data, parentMaxAge, root)
+			}
+		}
+	}
+}
+
+// restricts the policy by a resolved field and walks its value
+func (w *walker) field(field *ast.Field, parentType graphql.Type, value any, parentMaxAge int, root bool) {
+	def := getFieldDef(parentType, field.Name.Value)
+	if def == nil {
+		return
+	}
+
+	namedType, _ := graphql.GetNamed(def.Type).(graphql.Type)
+	if namedType == nil {
+		return
+	}
+	fieldHint := w.cc.fieldHint(parentType.Name(), field.Name.Value)
+	typeHint := Hint{}
+	leaf := graphql.IsLeafType(namedType)
+	if !leaf {
+		typeHint = w.cc.typeHint(namedType.Name())
+	}
+
+	scope := fieldHint.Scope
+	if scope == "" {
+		scope = typeHint.Scope
+	}
+
+	// the field hint takes precedence over the hint of the returned type.
+	// leaf fields inherit the max age of their parent unless they are root
+	// fields, other fields fall back to the default
+	maxAge := parentMaxAge
+	switch {
+	case fieldHint.MaxAge != nil:
+		maxAge = *fieldHint.MaxAge
+	case fieldHint.InheritMaxAge && !root:
+	case typeHint.MaxAge != nil:
+		maxAge = *typeHint.MaxAge
+	case typeHint.InheritMaxAge && !root:
+	case leaf && !root:
+	default:
+		maxAge = w.cc.defaultMaxAge
+	}
+
+	w.policy.restrict(maxAge, scope)
+
+	if field.SelectionSet != nil {
+		w.value(field.SelectionSet, namedType, value, maxAge)
+	}
+}
+
+// walks the objects of a field value, which may be nested in lists
+func (w *walker) value(set *ast.SelectionSet, parentType graphql.Type, value any, maxAge int) {
+	switch v := value.(type) {
+	case map[string]any:
+		w.walk(set, parentType, v, maxAge, false)
+	case []any:
+		for _, item := range v {
+			w.value(set, parentType, item, maxAge)
+		}
+	}
+}
+
+// gets the definition of a field of an object or interface
+func getFieldDef(parentType graphql.Type, name string) *graphql.FieldDefinition {
+	switch t := parentType.(type) {
+	case *graphql.Object:
+		return t.Fields()[name]
+	case *graphql.Interface:
+		return t.Fields()[name]
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/parser"
+	"github.com/dagger/graphql/language/source"
+)
+
+// validates client operations against the schema
+func runCheckOps(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("check-ops", stderr)
+	asJSON := flags.Bool("json", false, "print the errors as JSON")
+	schemaPath := flags.String("schema", "", "schema file or directory")
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+	if *schemaPath == "" || flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: graphql-go-tools check-ops [-json] -schema <schema> <operations>...")
+		return exitInvalid
+	}
+
+	schema, err := loadSchema([]string{*schemaPath})
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to build schema: %v\n", err)
+		return exitInvalid
+	}
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+
+	// parse every file first so operations can use fragments of other files
+	problems := []problem{}
+	docs := make([]*ast.Document, 0, len(files))
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, file := range files {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitInvalid
+		}
+
+		doc, err := parser.Parse(parser.ParseParams{
+			Source: &source.Source{Body: body, Name: file},
+		})
+		if err != nil {
+			problems = append(problems, toProblems(err)...)
+			continue
+		}
+		docs = append(docs, doc)
+
+		for _, def := range doc.Definitions {
+			if fragment, ok := def.(*ast.FragmentDefinition); ok {
+				fragments[fragment.Name.Value] = fragment
+			}
+		}
+	}
+
+	seen := map[problem]bool{}
+	for _, doc := range docs {
+		for _, err := range validateOperations(&schema, doc, fragments) {
+			for _, p := range toProblems(err) {
+				// errors in shared fragments are reported by every file using them
+				if !seen[p] {
+					seen[p] = true
+					problems = append(problems, p)
+				}
+			}
+		}
+	}
+
+	if *asJSON {
+		writeJSON(stdout, problems)
+	} else {
+		for _, p := range problems {
+			fmt.Fprintln(stdout, p)
+		}
+		fmt.Fprintf(stdout, "%d files checked, %d errors\n", len(files), len(problems))
+	}
+
+	if len(problems) > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// validates the operations of a document together with the fragments of other
+// documents they use. Documents with only fragments are not checked for unused
+// fragments
+func validateOperations(schema *graphql.Schema, doc *ast.Document, fragments map[string]*ast.FragmentDefinition) []error {
+	defined := map[string]bool{}
+	hasOperations := false
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			defined[d.Name.Value] = true
+		case *ast.OperationDefinition:
+			hasOperations = true
+		}
+	}
+
+	full := ast.NewDocument(&ast.Document{Loc: doc.Loc})
+	full.Definitions = append(full.Definitions, doc.Definitions...)
+	for i := 0; i < len(full.Definitions); i++ {
+		def, ok := full.Definitions[i].(ast.Definition)
+		if !ok {
+			continue
+		}
+		for _, name := range fragmentSpreads(def.GetSelectionSet()) {
+			if fragment, ok := fragments[name]; ok && !defined[name] {
+				defined[name] = true
+				full.Definitions = append(full.Definitions, fragment)
+			}
+		}
+	}
+
+	rules := graphql.SpecifiedRules
+	if !hasOperations {
+		rules = withoutRule(rules, graphql.NoUnusedFragmentsRule)
+	}
+
+	errs := []error{}
+	for _, err := range graphql.ValidateDocument(schema, full, rules).Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// returns the names of the fragments spread in a selection set
+func fragmentSpreads(set *ast.SelectionSet) []string {
+	if set == nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.FragmentSpread:
+			names = append(names, s.Name.Value)
+		case *ast.Field:
+			names = append(names, fragmentSpreads(s.SelectionSet)...)
+		case *ast.InlineFragment:
+			names = append(names, fragmentSpreads(s.SelectionSet)...)
+		}
+	}
+	return names
+}
+
+func withoutRule(rules []graphql.ValidationRuleFn, rule graphql.ValidationRuleFn) []graphql.ValidationRuleFn {
+	filtered := []graphql.ValidationRuleFn{}
+	for _, r := range rules {
+		if reflect.ValueOf(r).Pointer() != reflect.ValueOf(rule).Pointer() {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+// compares two schemas, exits with exitFailed on breaking changes
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("diff", stderr)
+	asJSON := flags.Bool("json", false, "print the changes as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(stderr, "usage: graphql-go-tools diff [-json] <old> <new>")
+		return exitInvalid
+	}
+
+	typeDefs := make([]string, 2)
+	for i, path := range flags.Args() {
+		var err error
+		if typeDefs[i], err = loadTypeDefs(path); err != nil {
+			fmt.Fprintf(stderr, "failed to read %s: %v\n", path, err)
+			return exitInvalid
+		}
+	}
+
+	diff, err := tools.DiffSchemas(typeDefs[0], typeDefs[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to diff schemas: %v\n", err)
+		return exitInvalid
+	}
+
+	if *asJSON {
+		writeJSON(stdout, diff)
+	} else {
+		for _, change := range diff {
+			fmt.Fprintf(stdout, "%-9s %s: %s\n", change.Severity, change.Path, change.Message)
+		}
+		fmt.Fprintf(stdout, "%d breaking, %d dangerous, %d safe changes\n",
+			len(diff.Breaking()), len(diff.Dangerous()), len(diff)-len(diff.Breaking())-len(diff.Dangerous()))
+	}
+
+	if diff.HasBreaking() {
+		return exitFailed
+	}
+	return exitOK
+}
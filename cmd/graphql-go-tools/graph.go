@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+// prints the dependency graph of the schema as DOT or JSON
+func runGraph(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("graph", stderr)
+	asJSON := flags.Bool("json", false, "print the graph as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: graphql-go-tools graph [-json] <schema>...")
+		return exitInvalid
+	}
+
+	typeDefs, err := loadAllTypeDefs(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+
+	doc, err := (&tools.ExecutableSchema{TypeDefs: typeDefs}).ConcatenateTypeDefs()
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to parse schema: %v\n", err)
+		return exitInvalid
+	}
+
+	graph, err := tools.NewDependencyGraph(doc)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to build graph: %v\n", err)
+		return exitInvalid
+	}
+
+	if *asJSON {
+		writeJSON(stdout, graph)
+		return exitOK
+	}
+	if err := graph.WriteDOT(stdout); err != nil {
+		fmt.Fprintf(stderr, "failed to write graph: %v\n", err)
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+// prints the result of the introspection query
+func runIntrospect(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("introspect", stderr)
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: graphql-go-tools introspect <schema>...")
+		return exitInvalid
+	}
+
+	schema, err := loadSchema(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to build schema: %v\n", err)
+		return exitInvalid
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: tools.IntrospectionQuery,
+	})
+	if result.HasErrors() {
+		for _, err := range result.Errors {
+			fmt.Fprintln(stderr, err.Message)
+		}
+		return exitFailed
+	}
+
+	writeJSON(stdout, result)
+	return exitOK
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dagger/graphql-go-tools/lint"
+)
+
+// lints the schema files and fails on errors
+func runLint(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("lint", stderr)
+	asJSON := flags.Bool("json", false, "print the diagnostics as JSON")
+	configPath := flags.String("config", "", "JSON file setting the severity of rules")
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: graphql-go-tools lint [-json] [-config lint.json] <schema>...")
+		return exitInvalid
+	}
+
+	config := lint.Config{}
+	if *configPath != "" {
+		var err error
+		if config, err = lint.LoadConfig(*configPath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitInvalid
+		}
+	}
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+	doc, err := lint.ParseFiles(files...)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to parse schema: %v\n", err)
+		return exitInvalid
+	}
+
+	diagnostics, err := lint.Lint(doc, config)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+
+	if *asJSON {
+		diagnostics.WriteJSON(stdout)
+	} else {
+		diagnostics.WriteText(stdout)
+	}
+
+	if diagnostics.HasErrors() {
+		return exitFailed
+	}
+	return exitOK
+}
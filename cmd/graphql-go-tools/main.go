@@ -0,0 +1,233 @@
+// Command graphql-go-tools checks GraphQL schemas and operations, for example
+// in pre-commit hooks and CI. Every command exits with 0 on success, 1 when it
+// found problems and 2 on invalid arguments or unreadable input. Commands that
+// report problems accept -json for machine readable output, introspect always
+// prints JSON
+//
+//	graphql-go-tools <command> [flags] [args]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql/gqlerrors"
+)
+
+// exit codes
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitInvalid = 2
+)
+
+type command struct {
+	usage string
+	run   func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"validate": {
+		usage: "validate [-json] <schema>...\n\tbuilds the schema and reports its errors",
+		run:   runValidate,
+	},
+	"print": {
+		usage: "print <schema>...\n\tprints the merged SDL with type extensions applied",
+		run:   runPrint,
+	},
+	"introspect": {
+		usage: "introspect <schema>...\n\tprints the result of the introspection query as JSON",
+		run:   runIntrospect,
+	},
+	"diff": {
+		usage: "diff [-json] <old> <new>\n\tcompares two schemas and fails on breaking changes",
+		run:   runDiff,
+	},
+	"graph": {
+		usage: "graph [-json] <schema>...\n\tprints the dependency graph of the types as DOT",
+		run:   runGraph,
+	},
+	"lint": {
+		usage: "lint [-json] [-config lint.json] <schema>...\n\tchecks the style of the schema and fails on errors",
+		run:   runLint,
+	},
+	"check-ops": {
+		usage: "check-ops [-json] -schema <schema> <operations>...\n\tvalidates client operations against the schema",
+		run:   runCheckOps,
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitInvalid
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return exitInvalid
+	}
+	return cmd.run(args[1:], stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: graphql-go-tools <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+}
+
+// creates the flag set of a command, errors are written to stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// loads the type definitions of a file or of the .graphql and .gql files of
+// a directory
+func loadTypeDefs(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return tools.ReadSourceFiles(path, true)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// loads and concatenates the type definitions of several paths
+func loadAllTypeDefs(paths []string) ([]string, error) {
+	typeDefs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		defs, err := loadTypeDefs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		typeDefs = append(typeDefs, defs)
+	}
+	return typeDefs, nil
+}
+
+// builds the schema of several paths without resolvers
+func loadSchema(paths []string) (graphql.Schema, error) {
+	typeDefs, err := loadAllTypeDefs(paths)
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+	return tools.BuildSchema(typeDefs)
+}
+
+// lists the .graphql and .gql files of files and directories
+func sourceFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		if err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".gql", ".graphql":
+				if !d.IsDir() {
+					files = append(files, p)
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// problem is an error at a position of a file in machine readable output
+type problem struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p problem) String() string {
+	if p.File == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// converts an error to problems, one per location of GraphQL errors
+func toProblems(err error) []problem {
+	var gqlErr *gqlerrors.Error
+	formatted, ok := err.(gqlerrors.FormattedError)
+	if ok {
+		gqlErr, _ = formatted.OriginalError().(*gqlerrors.Error)
+	} else {
+		gqlErr, ok = err.(*gqlerrors.Error)
+		if ok {
+			formatted = gqlerrors.FormatError(gqlErr)
+		}
+	}
+	if !ok || len(formatted.Locations) == 0 {
+		return []problem{{Message: err.Error()}}
+	}
+
+	file := ""
+	if gqlErr != nil && gqlErr.Source != nil {
+		file = gqlErr.Source.Name
+	}
+	problems := make([]problem, 0, len(formatted.Locations))
+	for _, loc := range formatted.Locations {
+		problems = append(problems, problem{
+			File:    file,
+			Line:    loc.Line,
+			Column:  loc.Column,
+			Message: formatted.Message,
+		})
+	}
+	return problems
+}
+
+// writes a value as indented JSON
+func writeJSON(w io.Writer, value any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(value)
+}
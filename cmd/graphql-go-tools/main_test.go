@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writes files to a temporary directory and returns their paths
+func writeFiles(t *testing.T, files map[string]string) map[string]string {
+	dir := t.TempDir()
+	paths := map[string]string{}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		paths[name] = path
+	}
+	return paths
+}
+
+func TestDiff(t *testing.T) {
+	paths := writeFiles(t, map[string]string{
+		"old.graphql":  "type Query {\n\thello: String\n\tlegacy: String\n}",
+		"safe.graphql": "type Query {\n\thello: String!\n\tlegacy: String\n\tworld: String\n}",
+		"new.graphql":  "type Query {\n\thello: String\n}",
+	})
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"diff", paths["old.graphql"], paths["safe.graphql"]}, exitOK, "0 breaking, 0 dangerous, 2 safe changes"},
+		{[]string{"diff", paths["old.graphql"], paths["new.graphql"]}, exitFailed, "BREAKING  Query.legacy: Query.legacy was removed"},
+		{[]string{"diff", "-json", paths["old.graphql"], paths["new.graphql"]}, exitFailed, `"type": "FIELD_REMOVED"`},
+		{[]string{"diff", paths["old.graphql"]}, exitInvalid, ""},
+		{[]string{"unknown"}, exitInvalid, ""},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, &stdout, &stderr); code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d: %s", test.args, test.code, code, stderr.String())
+			return
+		}
+		if !strings.Contains(stdout.String(), test.expected) {
+			t.Errorf("%v: unexpected output %s", test.args, stdout.String())
+			return
+		}
+	}
+}
+
+const testSchema = `
+"""
+The root query
+"""
+type Query {
+  user(id: ID!): User
+}
+
+"""
+A user
+"""
+type User {
+  id: ID!
+  name: String
+}
+`
+
+func TestCommands(t *testing.T) {
+	paths := writeFiles(t, map[string]string{
+		"schema.graphql":    testSchema,
+		"extend.graphql":    "extend type Query {\n  users: [User]\n}",
+		"invalid.graphql":   "type Query {\n  user: Missing\n}",
+		"style.graphql":     "\"Root\"\ntype Query {\n  User_name: String\n}",
+		"lint.json":         `{"rules": {"field-names-camel-case": "warning"}}`,
+		"query.graphql":     "query GetUser($id: ID!) {\n  user(id: $id) {\n    ...UserFields\n  }\n}",
+		"fragments.graphql": "fragment UserFields on User {\n  id\n  name\n}",
+		"bad.graphql":       "query {\n  user(id: \"1\") {\n    email\n  }\n}",
+	})
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"validate", paths["schema.graphql"]}, exitOK, "schema is valid"},
+		{[]string{"validate", "-json", paths["invalid.graphql"]}, exitFailed, `"valid": false`},
+		{[]string{"print", paths["schema.graphql"], paths["extend.graphql"]}, exitOK, "  user(id: ID!): User\n  users: [User]\n}"},
+		{[]string{"introspect", paths["schema.graphql"]}, exitOK, `"queryType": {`},
+		{[]string{"graph", paths["schema.graphql"]}, exitOK, `"Query" -> "User";`},
+		{[]string{"graph", "-json", paths["schema.graphql"]}, exitOK, `"unreachable": []`},
+		{[]string{"lint", paths["style.graphql"]}, exitFailed, paths["style.graphql"] + ":3:3: error: field Query.User_name should be camelCase (field-names-camel-case)"},
+		{[]string{"lint", "-json", "-config", paths["lint.json"], paths["style.graphql"]}, exitOK, `"severity": "warning"`},
+		{[]string{"check-ops", "-schema", paths["schema.graphql"], paths["query.graphql"], paths["fragments.graphql"]}, exitOK, "2 files checked, 0 errors"},
+		{[]string{"check-ops", "-json", "-schema", paths["schema.graphql"], paths["bad.graphql"]}, exitFailed, `"message": "Cannot query field \"email\" on type \"User\"."`},
+		{[]string{"check-ops", paths["query.graphql"]}, exitInvalid, ""},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, &stdout, &stderr); code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d: %s%s", test.args, test.code, code, stdout.String(), stderr.String())
+			return
+		}
+		if !strings.Contains(stdout.String(), test.expected) {
+			t.Errorf("%v: unexpected output %s", test.args, stdout.String())
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/printer"
+)
+
+// prints the merged SDL of the schema files
+func runPrint(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("print", stderr)
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: graphql-go-tools print <schema>...")
+		return exitInvalid
+	}
+
+	typeDefs, err := loadAllTypeDefs(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+
+	doc, err := (&tools.ExecutableSchema{TypeDefs: typeDefs}).ConcatenateTypeDefs()
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to parse schema: %v\n", err)
+		return exitInvalid
+	}
+
+	fmt.Fprint(stdout, printer.Print(mergeExtensions(doc)))
+	return exitOK
+}
+
+// replaces object types and their extensions with the merged objects,
+// extensions of undefined types are kept
+func mergeExtensions(doc *ast.Document) *ast.Document {
+	objects := map[string]bool{}
+	extensions := map[string][]*ast.ObjectDefinition{}
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.ObjectDefinition:
+			objects[d.Name.Value] = true
+		case *ast.TypeExtensionDefinition:
+			if d.Definition != nil {
+				name := d.Definition.Name.Value
+				extensions[name] = append(extensions[name], d.Definition)
+			}
+		}
+	}
+
+	merged := ast.NewDocument(nil)
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.ObjectDefinition:
+			def = tools.MergeExtensions(d, extensions[d.Name.Value]...)
+		case *ast.TypeExtensionDefinition:
+			if d.Definition != nil && objects[d.Definition.Name.Value] {
+				continue
+			}
+		}
+		merged.Definitions = append(merged.Definitions, def)
+	}
+	return merged
+}
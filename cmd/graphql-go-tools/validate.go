@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+// builds the schema and reports its errors
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("validate", stderr)
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: graphql-go-tools validate [-json] <schema>...")
+		return exitInvalid
+	}
+
+	typeDefs, err := loadAllTypeDefs(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+
+	problems := []problem{}
+	if _, err := tools.BuildSchema(typeDefs); err != nil {
+		problems = toProblems(err)
+	}
+
+	if *asJSON {
+		writeJSON(stdout, map[string]any{
+			"valid":  len(problems) == 0,
+			"errors": problems,
+		})
+	} else if len(problems) == 0 {
+		fmt.Fprintln(stdout, "schema is valid")
+	} else {
+		for _, p := range problems {
+			fmt.Fprintln(stdout, p)
+		}
+	}
+
+	if len(problems) > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,251 @@
+// Package dataloader batches and caches the loads of resolvers so a query
+// fetches each set of sibling keys with a single call
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Result is the value or error loaded for a key
+type Result[V any] struct {
+	Value V
+	Err   error
+}
+
+// BatchFunc loads a batch of keys, it must return one result per key in the
+// order of the keys
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) []Result[V]
+
+// Thunk waits for a load and returns its value. Calling it dispatches the
+// pending batch right away
+type Thunk[V any] func() (V, error)
+
+// Resolve adapts the thunk to a resolver result. The executor resolves the
+// sibling fields before calling it, so their keys end up in the same batch
+func (t Thunk[V]) Resolve() func() (any, error) {
+	return func() (any, error) {
+		return t()
+	}
+}
+
+// Option configures a Loader
+type Option func(*options)
+
+type options struct {
+	maxBatch int
+	wait     time.Duration
+	noCache  bool
+}
+
+// WithMaxBatch dispatches a batch once it has size keys
+func WithMaxBatch(size int) Option {
+	return func(o *options) {
+		o.maxBatch = size
+	}
+}
+
+// WithWait dispatches a batch after d even if none of its thunks have been
+// called
+func WithWait(d time.Duration) Option {
+	return func(o *options) {
+		o.wait = d
+	}
+}
+
+// WithoutCache loads every key again instead of reusing earlier loads
+func WithoutCache() Option {
+	return func(o *options) {
+		o.noCache = true
+	}
+}
+
+// Loader batches the keys loaded before one of their thunks is called, and
+// caches the results for the lifetime of the loader. Create a loader per
+// request, see Registry
+type Loader[K comparable, V any] struct {
+	fn      BatchFunc[K, V]
+	options options
+
+	mx      sync.Mutex
+	entries map[K]*entry[K, V]
+	batch   *batch[K, V]
+}
+
+type entry[K comparable, V any] struct {
+	batch *batch[K, V]
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	ctx     context.Context
+	keys    []K
+	entries []*entry[K, V]
+	once    sync.Once
+}
+
+// New creates a new loader
+func New[K comparable, V any](fn BatchFunc[K, V], opts ...Option) *Loader[K, V] {
+	l := &Loader[K, V]{
+		fn:      fn,
+		entries: make(map[K]*entry[K, V]),
+	}
+	for _, option := range opts {
+		option(&l.options)
+	}
+	return l
+}
+
+// Load adds a key to the pending batch and returns a thunk for its value
+func (l *Loader[K, V]) Load(ctx context.Context, key K) Thunk[V] {
+	l.mx.Lock()
+
+	if e, ok := l.entries[key]; ok {
+		l.mx.Unlock()
+		return l.thunk(e)
+	}
+
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{ctx: ctx}
+		l.batch = b
+		if l.options.wait > 0 {
+			time.AfterFunc(l.options.wait, func() { l.dispatch(b) })
+		}
+	}
+
+	e := &entry[K, V]{batch: b, done: make(chan struct{})}
+	if !l.options.noCache {
+		l.entries[key] = e
+	}
+	b.keys = append(b.keys, key)
+	b.entries = append(b.entries, e)
+	full := l.options.maxBatch > 0 && len(b.keys) >= l.options.maxBatch
+	if full {
+		l.batch = nil
+	}
+
+	l.mx.Unlock()
+
+	if full {
+		go l.dispatch(b)
+	}
+
+	return l.thunk(e)
+}
+
+// returns a thunk that dispatches the batch of an entry and waits for it
+func (l *Loader[K, V]) thunk(e *entry[K, V]) Thunk[V] {
+	return func() (V, error) {
+		if e.batch != nil {
+			l.dispatch(e.batch)
+		}
+		<-e.done
+		return e.value, e.err
+	}
+}
+
+// LoadMany loads several keys in the same batch, the thunk fails with the
+// first error
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) Thunk[[]V] {
+	thunks := make([]Thunk[V], len(keys))
+	for i, key := range keys {
+		thunks[i] = l.Load(ctx, key)
+	}
+
+	return func() ([]V, error) {
+		values := make([]V, len(thunks))
+		for i, thunk := range thunks {
+			value, err := thunk()
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+}
+
+// Prime caches the value of a key unless it has already been loaded
+func (l *Loader[K, V]) Prime(key K, value V) {
+	if l.options.noCache {
+		return
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if _, ok := l.entries[key]; !ok {
+		e := &entry[K, V]{done: make(chan struct{}), value: value}
+		close(e.done)
+		l.entries[key] = e
+	}
+}
+
+// Clear removes a key from the cache
+func (l *Loader[K, V]) Clear(key K) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	delete(l.entries, key)
+}
+
+// ClearAll empties the cache
+func (l *Loader[K, V]) ClearAll() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.entries = make(map[K]*entry[K, V])
+}
+
+// calls the batch function once per batch and delivers the results
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	b.once.Do(func() {
+		l.mx.Lock()
+		if l.batch == b {
+			l.batch = nil
+		}
+		l.mx.Unlock()
+
+		results := l.call(b)
+		for i, e := range b.entries {
+			e.value, e.err = results[i].Value, results[i].Err
+			close(e.done)
+		}
+
+		// failed loads are retried by later loads
+		l.mx.Lock()
+		for i, key := range b.keys {
+			if results[i].Err != nil && l.entries[key] == b.entries[i] {
+				delete(l.entries, key)
+			}
+		}
+		l.mx.Unlock()
+	})
+}
+
+// calls the batch function, reporting panics and missing results as errors
+// of every key
+func (l *Loader[K, V]) call(b *batch[K, V]) (results []Result[V]) {
+	fail := func(err error) []Result[V] {
+		results := make([]Result[V], len(b.keys))
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			results = fail(fmt.Errorf("dataloader: batch function panicked: %v", r))
+		}
+	}()
+
+	results = l.fn(b.ctx, b.keys)
+	if len(results) != len(b.keys) {
+		return fail(fmt.Errorf("dataloader: batch function returned %d results for %d keys", len(results), len(b.keys)))
+	}
+	return results
+}
@@ -0,0 +1,199 @@
+package dataloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql-go-tools/executor"
+)
+
+// records the batches of a loader of strings
+type batches struct {
+	mx   sync.Mutex
+	keys [][]int
+}
+
+func (b *batches) fn(ctx context.Context, keys []int) []Result[string] {
+	b.mx.Lock()
+	b.keys = append(b.keys, append([]int{}, keys...))
+	b.mx.Unlock()
+
+	results := make([]Result[string], len(keys))
+	for i, key := range keys {
+		if key < 0 {
+			results[i].Err = fmt.Errorf("invalid key %d", key)
+			continue
+		}
+		results[i].Value = fmt.Sprintf("value %d", key)
+	}
+	return results
+}
+
+func (b *batches) get() [][]int {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.keys
+}
+
+func TestLoader(t *testing.T) {
+	b := &batches{}
+	l := New(b.fn)
+	ctx := context.Background()
+
+	one, two, cached := l.Load(ctx, 1), l.Load(ctx, 2), l.Load(ctx, 1)
+	for i, thunk := range []Thunk[string]{one, two, cached} {
+		if _, err := thunk(); err != nil {
+			t.Errorf("failed to load thunk %d: %v", i, err)
+			return
+		}
+	}
+	if value, _ := cached(); value != "value 1" {
+		t.Errorf("expected value 1, got %q", value)
+		return
+	}
+
+	// cached keys are not loaded again, failed keys are retried
+	l.Load(ctx, 1)()
+	if _, err := l.Load(ctx, -1)(); err == nil {
+		t.Error("expected an error for an invalid key")
+		return
+	}
+	l.Load(ctx, -1)()
+
+	l.Prime(3, "primed")
+	if value, _ := l.Load(ctx, 3)(); value != "primed" {
+		t.Errorf("expected the primed value, got %q", value)
+		return
+	}
+
+	l.Clear(1)
+	values, err := l.LoadMany(ctx, []int{1, 2})()
+	if err != nil || !reflect.DeepEqual(values, []string{"value 1", "value 2"}) {
+		t.Errorf("unexpected values %v: %v", values, err)
+		return
+	}
+
+	expected := [][]int{{1, 2}, {-1}, {-1}, {1}}
+	if !reflect.DeepEqual(b.get(), expected) {
+		t.Errorf("expected batches %v, got %v", expected, b.get())
+		return
+	}
+}
+
+func TestLoaderOptions(t *testing.T) {
+	ctx := context.Background()
+
+	b := &batches{}
+	l := New(b.fn, WithMaxBatch(2), WithoutCache())
+	thunks := l.LoadMany(ctx, []int{1, 2, 3, 1})
+	if _, err := thunks(); err != nil {
+		t.Errorf("failed to load: %v", err)
+		return
+	}
+	if expected := [][]int{{1, 2}, {3, 1}}; !reflect.DeepEqual(b.get(), expected) {
+		t.Errorf("expected batches %v, got %v", expected, b.get())
+		return
+	}
+
+	// the wait window dispatches batches whose thunks are never called
+	b = &batches{}
+	l = New(b.fn, WithWait(10*time.Millisecond))
+	l.Load(ctx, 1)
+	l.Load(ctx, 2)
+	time.Sleep(50 * time.Millisecond)
+	if expected := [][]int{{1, 2}}; !reflect.DeepEqual(b.get(), expected) {
+		t.Errorf("expected batches %v, got %v", expected, b.get())
+		return
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	ctx := context.Background()
+
+	short := New(func(ctx context.Context, keys []int) []Result[string] {
+		return nil
+	})
+	if _, err := short.Load(ctx, 1)(); err == nil {
+		t.Error("expected an error for missing results")
+		return
+	}
+
+	panics := New(func(ctx context.Context, keys []int) []Result[string] {
+		panic(errors.New("boom"))
+	})
+	if _, err := panics.Load(ctx, 1)(); err == nil {
+		t.Error("expected an error for a panic")
+		return
+	}
+}
+
+func TestResolverBatching(t *testing.T) {
+	b := &batches{}
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type Post {
+	id: Int
+	author: String
+}
+
+type Query {
+	posts: [Post]
+}`,
+		Resolvers: tools.ResolverMap{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"posts": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return []any{
+								map[string]any{"id": 1},
+								map[string]any{"id": 2},
+								map[string]any{"id": 1},
+							}, nil
+						},
+					},
+				},
+			},
+			"Post": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"author": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							authors := For(p.Context, "authors", b.fn)
+							return authors.Load(p.Context, p.Source.(map[string]any)["id"].(int)).Resolve(), nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+
+	exec := executor.New(&executor.Config{
+		Schema:  &schema,
+		Plugins: []executor.Plugin{Plugin()},
+	})
+
+	for i := 0; i < 2; i++ {
+		result := exec.Execute(&executor.Request{Query: `{ posts { id author } }`})
+		data, _ := json.Marshal(result)
+		if expected := `{"data":{"posts":[{"author":"value 1","id":1},{"author":"value 2","id":2},{"author":"value 1","id":1}]}}`; string(data) != expected {
+			t.Errorf("unexpected result %s", data)
+			return
+		}
+	}
+
+	// one batch per request as every request gets its own registry
+	if expected := [][]int{{1, 2}, {1, 2}}; !reflect.DeepEqual(b.get(), expected) {
+		t.Errorf("expected batches %v, got %v", expected, b.get())
+		return
+	}
+}
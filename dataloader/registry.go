@@ -0,0 +1,79 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dagger/graphql-go-tools/executor"
+)
+
+// Registry holds the loaders of a request so every resolver of the request
+// shares their batches and caches
+type Registry struct {
+	mx      sync.Mutex
+	loaders map[string]any
+}
+
+type registryKey struct{}
+
+// NewRegistry creates a new registry
+func NewRegistry() *Registry {
+	return &Registry{
+		loaders: make(map[string]any),
+	}
+}
+
+// WithRegistry returns a context with a new registry. Use it in
+// server.Options.ContextFunc or before calling handler.ContextHandler
+func WithRegistry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, registryKey{}, NewRegistry())
+}
+
+// RegistryFrom returns the registry of a context or nil
+func RegistryFrom(ctx context.Context) *Registry {
+	registry, _ := ctx.Value(registryKey{}).(*Registry)
+	return registry
+}
+
+// For returns the loader registered under name in the registry of ctx,
+// creating it on first use. Without a registry a new loader is returned for
+// every call, which does not batch across resolvers
+func For[K comparable, V any](ctx context.Context, name string, fn BatchFunc[K, V], opts ...Option) *Loader[K, V] {
+	registry := RegistryFrom(ctx)
+	if registry == nil {
+		return New(fn, opts...)
+	}
+
+	registry.mx.Lock()
+	defer registry.mx.Unlock()
+
+	if loader, ok := registry.loaders[name]; ok {
+		typed, ok := loader.(*Loader[K, V])
+		if !ok {
+			panic(fmt.Sprintf("dataloader: loader %q is registered as %T", name, loader))
+		}
+		return typed
+	}
+
+	loader := New(fn, opts...)
+	registry.loaders[name] = loader
+	return loader
+}
+
+// Plugin attaches a new registry to every operation whose context does not
+// have one yet
+func Plugin() executor.Plugin {
+	return &plugin{}
+}
+
+type plugin struct {
+	executor.NoopPlugin
+}
+
+func (p *plugin) OnRequest(ctx context.Context, req *executor.Request) (context.Context, error) {
+	if RegistryFrom(ctx) == nil {
+		ctx = WithRegistry(ctx)
+	}
+	return ctx, nil
+}
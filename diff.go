@@ -0,0 +1,503 @@
+package tools
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dagger/graphql"
+)
+
+// ChangeSeverity classifies a schema change by its effect on existing clients
+type ChangeSeverity string
+
+// Change severities
+const (
+	// ChangeBreaking breaks existing operations
+	ChangeBreaking ChangeSeverity = "BREAKING"
+
+	// ChangeDangerous keeps operations valid but may change their results,
+	// for example a new enum value clients do not handle
+	ChangeDangerous ChangeSeverity = "DANGEROUS"
+
+	// ChangeSafe does not affect existing clients
+	ChangeSafe ChangeSeverity = "SAFE"
+)
+
+// ChangeType identifies the kind of a schema change
+type ChangeType string
+
+// Change types
+const (
+	TypeRemoved                 ChangeType = "TYPE_REMOVED"
+	TypeAdded                   ChangeType = "TYPE_ADDED"
+	TypeChangedKind             ChangeType = "TYPE_CHANGED_KIND"
+	TypeRemovedFromUnion        ChangeType = "TYPE_REMOVED_FROM_UNION"
+	TypeAddedToUnion            ChangeType = "TYPE_ADDED_TO_UNION"
+	ValueRemovedFromEnum        ChangeType = "VALUE_REMOVED_FROM_ENUM"
+	ValueAddedToEnum            ChangeType = "VALUE_ADDED_TO_ENUM"
+	InterfaceRemoved            ChangeType = "IMPLEMENTED_INTERFACE_REMOVED"
+	InterfaceAdded              ChangeType = "IMPLEMENTED_INTERFACE_ADDED"
+	FieldRemoved                ChangeType = "FIELD_REMOVED"
+	FieldAdded                  ChangeType = "FIELD_ADDED"
+	FieldTypeChanged            ChangeType = "FIELD_TYPE_CHANGED"
+	FieldDeprecationChanged     ChangeType = "FIELD_DEPRECATION_CHANGED"
+	RequiredInputFieldAdded     ChangeType = "REQUIRED_INPUT_FIELD_ADDED"
+	OptionalInputFieldAdded     ChangeType = "OPTIONAL_INPUT_FIELD_ADDED"
+	InputFieldDefaultChanged    ChangeType = "INPUT_FIELD_DEFAULT_VALUE_CHANGED"
+	ArgRemoved                  ChangeType = "ARG_REMOVED"
+	RequiredArgAdded            ChangeType = "REQUIRED_ARG_ADDED"
+	OptionalArgAdded            ChangeType = "OPTIONAL_ARG_ADDED"
+	ArgTypeChanged              ChangeType = "ARG_TYPE_CHANGED"
+	ArgDefaultChanged           ChangeType = "ARG_DEFAULT_VALUE_CHANGED"
+	EnumValueDeprecationChanged ChangeType = "ENUM_VALUE_DEPRECATION_CHANGED"
+	DirectiveRemoved            ChangeType = "DIRECTIVE_REMOVED"
+	DirectiveAdded              ChangeType = "DIRECTIVE_ADDED"
+	DirectiveLocationRemoved    ChangeType = "DIRECTIVE_LOCATION_REMOVED"
+	DirectiveLocationAdded      ChangeType = "DIRECTIVE_LOCATION_ADDED"
+	DescriptionChanged          ChangeType = "DESCRIPTION_CHANGED"
+)
+
+// Change is a difference between two schemas. Path addresses the changed
+// element as Type, Type.field, Type.field(arg:) or @directive
+type Change struct {
+	Type     ChangeType     `json:"type"`
+	Severity ChangeSeverity `json:"severity"`
+	Path     string         `json:"path"`
+	Message  string         `json:"message"`
+}
+
+// SchemaDiff is the list of changes between two schemas
+type SchemaDiff []Change
+
+// Breaking returns the breaking changes
+func (d SchemaDiff) Breaking() SchemaDiff {
+	return d.filter(ChangeBreaking)
+}
+
+// Dangerous returns the dangerous changes
+func (d SchemaDiff) Dangerous() SchemaDiff {
+	return d.filter(ChangeDangerous)
+}
+
+// HasBreaking reports whether any change is breaking
+func (d SchemaDiff) HasBreaking() bool {
+	return len(d.Breaking()) > 0
+}
+
+func (d SchemaDiff) filter(severity ChangeSeverity) SchemaDiff {
+	changes := SchemaDiff{}
+	for _, change := range d {
+		if change.Severity == severity {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// DiffSchemas compares two schemas. Each schema is a graphql.Schema, a
+// *graphql.Schema, an ExecutableSchema or TypeDefs as accepted by
+// BuildSchema. The changes are sorted by path
+func DiffSchemas(oldSchema, newSchema any) (SchemaDiff, error) {
+	o, err := toSchema(oldSchema)
+	if err != nil {
+		return nil, fmt.Errorf("old schema: %w", err)
+	}
+	n, err := toSchema(newSchema)
+	if err != nil {
+		return nil, fmt.Errorf("new schema: %w", err)
+	}
+
+	d := &differ{}
+	d.diffTypes(o.TypeMap(), n.TypeMap())
+	d.diffDirectives(o.Directives(), n.Directives())
+
+	sort.Slice(d.changes, func(i, j int) bool {
+		a, b := d.changes[i], d.changes[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Message < b.Message
+	})
+	return d.changes, nil
+}
+
+// builds a schema from a diff input
+func toSchema(schema any) (*graphql.Schema, error) {
+	switch s := schema.(type) {
+	case graphql.Schema:
+		return &s, nil
+	case *graphql.Schema:
+		return s, nil
+	case ExecutableSchema:
+		built, err := MakeExecutableSchema(s)
+		return &built, err
+	case *ExecutableSchema:
+		built, err := MakeExecutableSchema(*s)
+		return &built, err
+	}
+
+	built, err := BuildSchema(schema)
+	return &built, err
+}
+
+type differ struct {
+	changes SchemaDiff
+}
+
+func (d *differ) add(changeType ChangeType, severity ChangeSeverity, path, format string, a ...any) {
+	d.changes = append(d.changes, Change{
+		Type:     changeType,
+		Severity: severity,
+		Path:     path,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (d *differ) diffTypes(oldTypes, newTypes map[string]graphql.Type) {
+	for name, oldType := range oldTypes {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+
+		newType, ok := newTypes[name]
+		if !ok {
+			d.add(TypeRemoved, ChangeBreaking, name, "%s was removed", name)
+			continue
+		}
+
+		if typeKind(oldType) != typeKind(newType) {
+			d.add(TypeChangedKind, ChangeBreaking, name, "%s changed from %s to %s", name, typeKind(oldType), typeKind(newType))
+			continue
+		}
+
+		if oldType.Description() != newType.Description() {
+			d.add(DescriptionChanged, ChangeSafe, name, "description of %s changed", name)
+		}
+
+		switch o := oldType.(type) {
+		case *graphql.Object:
+			n := newType.(*graphql.Object)
+			d.diffInterfaces(name, o.Interfaces(), n.Interfaces())
+			d.diffFields(name, o.Fields(), n.Fields())
+		case *graphql.Interface:
+			d.diffFields(name, o.Fields(), newType.(*graphql.Interface).Fields())
+		case *graphql.Union:
+			d.diffUnion(name, o.Types(), newType.(*graphql.Union).Types())
+		case *graphql.Enum:
+			d.diffEnum(name, o.Values(), newType.(*graphql.Enum).Values())
+		case *graphql.InputObject:
+			d.diffInputFields(name, o.Fields(), newType.(*graphql.InputObject).Fields())
+		}
+	}
+
+	for name := range newTypes {
+		if _, ok := oldTypes[name]; !ok && !strings.HasPrefix(name, "__") {
+			d.add(TypeAdded, ChangeSafe, name, "%s was added", name)
+		}
+	}
+}
+
+func (d *differ) diffInterfaces(typeName string, oldInterfaces, newInterfaces []*graphql.Interface) {
+	oldNames, newNames := interfaceNames(oldInterfaces), interfaceNames(newInterfaces)
+	for name := range oldNames {
+		if !newNames[name] {
+			d.add(InterfaceRemoved, ChangeBreaking, typeName, "%s no longer implements %s", typeName, name)
+		}
+	}
+	for name := range newNames {
+		if !oldNames[name] {
+			d.add(InterfaceAdded, ChangeDangerous, typeName, "%s now implements %s", typeName, name)
+		}
+	}
+}
+
+func (d *differ) diffFields(typeName string, oldFields, newFields graphql.FieldDefinitionMap) {
+	for name, oldField := range oldFields {
+		path := typeName + "." + name
+		newField, ok := newFields[name]
+		if !ok {
+			d.add(FieldRemoved, ChangeBreaking, path, "%s was removed", path)
+			continue
+		}
+
+		if !safeOutputChange(oldField.Type, newField.Type) {
+			d.add(FieldTypeChanged, ChangeBreaking, path, "%s changed type from %s to %s", path, oldField.Type, newField.Type)
+		} else if oldField.Type.String() != newField.Type.String() {
+			d.add(FieldTypeChanged, ChangeSafe, path, "%s changed type from %s to %s", path, oldField.Type, newField.Type)
+		}
+
+		if oldField.DeprecationReason != newField.DeprecationReason {
+			d.add(FieldDeprecationChanged, ChangeSafe, path, "deprecation of %s changed", path)
+		}
+		if oldField.Description != newField.Description {
+			d.add(DescriptionChanged, ChangeSafe, path, "description of %s changed", path)
+		}
+
+		d.diffArgs(path, oldField.Args, newField.Args)
+	}
+
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			path := typeName + "." + name
+			d.add(FieldAdded, ChangeSafe, path, "%s was added", path)
+		}
+	}
+}
+
+func (d *differ) diffArgs(fieldPath string, oldArgs, newArgs []*graphql.Argument) {
+	newByName := map[string]*graphql.Argument{}
+	for _, arg := range newArgs {
+		newByName[arg.Name()] = arg
+	}
+	oldByName := map[string]*graphql.Argument{}
+
+	for _, oldArg := range oldArgs {
+		oldByName[oldArg.Name()] = oldArg
+		path := fmt.Sprintf("%s(%s:)", fieldPath, oldArg.Name())
+
+		newArg, ok := newByName[oldArg.Name()]
+		if !ok {
+			d.add(ArgRemoved, ChangeBreaking, path, "argument %s was removed", path)
+			continue
+		}
+
+		if !safeInputChange(oldArg.Type, newArg.Type) {
+			d.add(ArgTypeChanged, ChangeBreaking, path, "argument %s changed type from %s to %s", path, oldArg.Type, newArg.Type)
+		} else if oldArg.Type.String() != newArg.Type.String() {
+			d.add(ArgTypeChanged, ChangeSafe, path, "argument %s changed type from %s to %s", path, oldArg.Type, newArg.Type)
+		}
+
+		if !reflect.DeepEqual(oldArg.DefaultValue, newArg.DefaultValue) {
+			d.add(ArgDefaultChanged, ChangeDangerous, path, "default value of argument %s changed from %v to %v", path, oldArg.DefaultValue, newArg.DefaultValue)
+		}
+	}
+
+	for _, newArg := range newArgs {
+		if _, ok := oldByName[newArg.Name()]; ok {
+			continue
+		}
+		path := fmt.Sprintf("%s(%s:)", fieldPath, newArg.Name())
+		if isRequired(newArg.Type, newArg.DefaultValue) {
+			d.add(RequiredArgAdded, ChangeBreaking, path, "required argument %s was added", path)
+		} else {
+			d.add(OptionalArgAdded, ChangeDangerous, path, "optional argument %s was added", path)
+		}
+	}
+}
+
+func (d *differ) diffInputFields(typeName string, oldFields, newFields graphql.InputObjectFieldMap) {
+	for name, oldField := range oldFields {
+		path := typeName + "." + name
+		newField, ok := newFields[name]
+		if !ok {
+			d.add(FieldRemoved, ChangeBreaking, path, "%s was removed", path)
+			continue
+		}
+
+		if !safeInputChange(oldField.Type, newField.Type) {
+			d.add(FieldTypeChanged, ChangeBreaking, path, "%s changed type from %s to %s", path, oldField.Type, newField.Type)
+		} else if oldField.Type.String() != newField.Type.String() {
+			d.add(FieldTypeChanged, ChangeSafe, path, "%s changed type from %s to %s", path, oldField.Type, newField.Type)
+		}
+
+		if !reflect.DeepEqual(oldField.DefaultValue, newField.DefaultValue) {
+			d.add(InputFieldDefaultChanged, ChangeDangerous, path, "default value of %s changed from %v to %v", path, oldField.DefaultValue, newField.DefaultValue)
+		}
+	}
+
+	for name, newField := range newFields {
+		if _, ok := oldFields[name]; ok {
+			continue
+		}
+		path := typeName + "." + name
+		if isRequired(newField.Type, newField.DefaultValue) {
+			d.add(RequiredInputFieldAdded, ChangeBreaking, path, "required input field %s was added", path)
+		} else {
+			d.add(OptionalInputFieldAdded, ChangeDangerous, path, "optional input field %s was added", path)
+		}
+	}
+}
+
+func (d *differ) diffUnion(typeName string, oldTypes, newTypes []*graphql.Object) {
+	oldNames, newNames := objectNames(oldTypes), objectNames(newTypes)
+	for name := range oldNames {
+		if !newNames[name] {
+			d.add(TypeRemovedFromUnion, ChangeBreaking, typeName, "%s was removed from union %s", name, typeName)
+		}
+	}
+	for name := range newNames {
+		if !oldNames[name] {
+			d.add(TypeAddedToUnion, ChangeDangerous, typeName, "%s was added to union %s", name, typeName)
+		}
+	}
+}
+
+func (d *differ) diffEnum(typeName string, oldValues, newValues []*graphql.EnumValueDefinition) {
+	newByName := map[string]*graphql.EnumValueDefinition{}
+	for _, value := range newValues {
+		newByName[value.Name] = value
+	}
+	oldByName := map[string]*graphql.EnumValueDefinition{}
+
+	for _, oldValue := range oldValues {
+		oldByName[oldValue.Name] = oldValue
+		path := typeName + "." + oldValue.Name
+
+		newValue, ok := newByName[oldValue.Name]
+		if !ok {
+			d.add(ValueRemovedFromEnum, ChangeBreaking, path, "%s was removed from enum %s", oldValue.Name, typeName)
+			continue
+		}
+		if oldValue.DeprecationReason != newValue.DeprecationReason {
+			d.add(EnumValueDeprecationChanged, ChangeSafe, path, "deprecation of %s changed", path)
+		}
+	}
+
+	for _, newValue := range newValues {
+		if _, ok := oldByName[newValue.Name]; !ok {
+			d.add(ValueAddedToEnum, ChangeDangerous, typeName+"."+newValue.Name, "%s was added to enum %s", newValue.Name, typeName)
+		}
+	}
+}
+
+func (d *differ) diffDirectives(oldDirectives, newDirectives []*graphql.Directive) {
+	newByName := map[string]*graphql.Directive{}
+	for _, directive := range newDirectives {
+		newByName[directive.Name] = directive
+	}
+	oldByName := map[string]*graphql.Directive{}
+
+	for _, oldDirective := range oldDirectives {
+		oldByName[oldDirective.Name] = oldDirective
+		path := "@" + oldDirective.Name
+
+		newDirective, ok := newByName[oldDirective.Name]
+		if !ok {
+			d.add(DirectiveRemoved, ChangeBreaking, path, "%s was removed", path)
+			continue
+		}
+
+		d.diffArgs(path, oldDirective.Args, newDirective.Args)
+
+		oldLocations, newLocations := stringSet(oldDirective.Locations), stringSet(newDirective.Locations)
+		for location := range oldLocations {
+			if !newLocations[location] {
+				d.add(DirectiveLocationRemoved, ChangeBreaking, path, "%s can no longer be used on %s", path, location)
+			}
+		}
+		for location := range newLocations {
+			if !oldLocations[location] {
+				d.add(DirectiveLocationAdded, ChangeSafe, path, "%s can now be used on %s", path, location)
+			}
+		}
+	}
+
+	for _, newDirective := range newDirectives {
+		if _, ok := oldByName[newDirective.Name]; !ok {
+			d.add(DirectiveAdded, ChangeSafe, "@"+newDirective.Name, "@%s was added", newDirective.Name)
+		}
+	}
+}
+
+// reports whether clients selecting a field of the old type can read the
+// new type. the new type may only add non null wrappers
+func safeOutputChange(oldType, newType graphql.Type) bool {
+	switch o := oldType.(type) {
+	case *graphql.List:
+		switch n := newType.(type) {
+		case *graphql.List:
+			return safeOutputChange(o.OfType, n.OfType)
+		case *graphql.NonNull:
+			return safeOutputChange(oldType, n.OfType)
+		}
+		return false
+	case *graphql.NonNull:
+		if n, ok := newType.(*graphql.NonNull); ok {
+			return safeOutputChange(o.OfType, n.OfType)
+		}
+		return false
+	}
+
+	if n, ok := newType.(*graphql.NonNull); ok {
+		return safeOutputChange(oldType, n.OfType)
+	}
+	return sameNamedType(oldType, newType)
+}
+
+// reports whether values sent for the old input type are valid for the new
+// type. the new type may only remove non null wrappers
+func safeInputChange(oldType, newType graphql.Type) bool {
+	switch o := oldType.(type) {
+	case *graphql.List:
+		if n, ok := newType.(*graphql.List); ok {
+			return safeInputChange(o.OfType, n.OfType)
+		}
+		return false
+	case *graphql.NonNull:
+		if n, ok := newType.(*graphql.NonNull); ok {
+			return safeInputChange(o.OfType, n.OfType)
+		}
+		return safeInputChange(o.OfType, newType)
+	}
+	return sameNamedType(oldType, newType)
+}
+
+func sameNamedType(oldType, newType graphql.Type) bool {
+	switch newType.(type) {
+	case *graphql.List, *graphql.NonNull:
+		return false
+	}
+	return oldType.Name() == newType.Name()
+}
+
+func isRequired(t graphql.Type, defaultValue any) bool {
+	_, nonNull := t.(*graphql.NonNull)
+	return nonNull && defaultValue == nil
+}
+
+func typeKind(t graphql.Type) string {
+	switch t.(type) {
+	case *graphql.Scalar:
+		return "SCALAR"
+	case *graphql.Object:
+		return "OBJECT"
+	case *graphql.Interface:
+		return "INTERFACE"
+	case *graphql.Union:
+		return "UNION"
+	case *graphql.Enum:
+		return "ENUM"
+	case *graphql.InputObject:
+		return "INPUT_OBJECT"
+	}
+	return fmt.Sprintf("%T", t)
+}
+
+func interfaceNames(interfaces []*graphql.Interface) map[string]bool {
+	names := map[string]bool{}
+	for _, iface := range interfaces {
+		names[iface.Name()] = true
+	}
+	return names
+}
+
+func objectNames(objects []*graphql.Object) map[string]bool {
+	names := map[string]bool{}
+	for _, object := range objects {
+		names[object.Name()] = true
+	}
+	return names
+}
+
+func stringSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
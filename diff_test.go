@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	oldTypeDefs := `
+directive @auth(role: String) on FIELD_DEFINITION | OBJECT
+
+interface Node {
+	id: ID!
+}
+
+type User implements Node {
+	id: ID!
+	name: String
+	email: String!
+	friends(first: Int = 10): [User]
+}
+
+type Post {
+	id: ID!
+}
+
+union SearchResult = User | Post
+
+enum Role {
+	ADMIN
+	USER
+}
+
+input UserInput {
+	name: String!
+	role: Role
+}
+
+type Query {
+	user(id: ID!): User
+	search(term: String!): [SearchResult]
+	legacy: String
+}`
+
+	newTypeDefs := `
+directive @auth(role: String, scopes: [String]!) on FIELD_DEFINITION
+
+interface Node {
+	id: ID!
+}
+
+type User {
+	id: ID!
+	name: String!
+	email: String
+	friends(first: Int = 20, after: String): [User]
+}
+
+type Post {
+	id: ID!
+}
+
+type Comment {
+	id: ID!
+}
+
+union SearchResult = User | Comment
+
+enum Role {
+	ADMIN
+	GUEST
+}
+
+input UserInput {
+	name: String
+	role: Role
+	email: String!
+}
+
+type Query {
+	user(id: ID, filter: String!): User
+	search(term: Int!): [SearchResult]
+}`
+
+	diff, err := DiffSchemas(oldTypeDefs, newTypeDefs)
+	if err != nil {
+		t.Errorf("failed to diff schemas: %v", err)
+		return
+	}
+
+	lines := []string{}
+	for _, change := range diff {
+		lines = append(lines, fmt.Sprintf("%s %s %s", change.Severity, change.Type, change.Path))
+	}
+
+	expected := []string{
+		"BREAKING DIRECTIVE_LOCATION_REMOVED @auth",
+		"BREAKING REQUIRED_ARG_ADDED @auth(scopes:)",
+		"SAFE TYPE_ADDED Comment",
+		"BREAKING FIELD_REMOVED Query.legacy",
+		"BREAKING ARG_TYPE_CHANGED Query.search(term:)",
+		"BREAKING REQUIRED_ARG_ADDED Query.user(filter:)",
+		"SAFE ARG_TYPE_CHANGED Query.user(id:)",
+		"DANGEROUS VALUE_ADDED_TO_ENUM Role.GUEST",
+		"BREAKING VALUE_REMOVED_FROM_ENUM Role.USER",
+		"DANGEROUS TYPE_ADDED_TO_UNION SearchResult",
+		"BREAKING TYPE_REMOVED_FROM_UNION SearchResult",
+		"BREAKING IMPLEMENTED_INTERFACE_REMOVED User",
+		"BREAKING FIELD_TYPE_CHANGED User.email",
+		"DANGEROUS OPTIONAL_ARG_ADDED User.friends(after:)",
+		"DANGEROUS ARG_DEFAULT_VALUE_CHANGED User.friends(first:)",
+		"SAFE FIELD_TYPE_CHANGED User.name",
+		"BREAKING REQUIRED_INPUT_FIELD_ADDED UserInput.email",
+		"SAFE FIELD_TYPE_CHANGED UserInput.name",
+	}
+
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected changes:\n%s", strings.Join(lines, "\n"))
+		return
+	}
+
+	if !diff.HasBreaking() || len(diff.Breaking()) != 10 || len(diff.Dangerous()) != 4 {
+		t.Errorf("unexpected classification: %d breaking, %d dangerous", len(diff.Breaking()), len(diff.Dangerous()))
+		return
+	}
+}
+
+func TestDiffSchemasUnchanged(t *testing.T) {
+	typeDefs := `
+type Query {
+	hello(name: String = "world"): String
+}`
+
+	schema, err := BuildSchema(typeDefs)
+	if err != nil {
+		t.Errorf("failed to build schema: %v", err)
+		return
+	}
+
+	diff, err := DiffSchemas(schema, typeDefs)
+	if err != nil || len(diff) != 0 {
+		t.Errorf("expected no changes, got %v %v", diff, err)
+		return
+	}
+}
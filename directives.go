@@ -8,7 +8,9 @@ import (
 )
 
 const (
-	directiveHide = "hide"
+	directiveHide   = "hide"
+	directiveDefer  = "defer"
+	directiveStream = "stream"
 )
 
 // HideDirective hides a define field
@@ -19,6 +21,50 @@ var HideDirective = graphql.NewDirective(graphql.DirectiveConfig{
 	Args:        graphql.FieldConfigArgument{},
 })
 
+// DeferDirective marks a fragment for incremental delivery after the initial response
+var DeferDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        directiveDefer,
+	Description: "Directs the executor to deliver this fragment incrementally, after the initial response has been sent",
+	Locations: []string{
+		graphql.DirectiveLocationFragmentSpread,
+		graphql.DirectiveLocationInlineFragment,
+	},
+	Args: graphql.FieldConfigArgument{
+		&graphql.ArgumentConfig{
+			Name:         "if",
+			Type:         graphql.Boolean,
+			DefaultValue: true,
+		},
+		&graphql.ArgumentConfig{
+			Name: "label",
+			Type: graphql.String,
+		},
+	},
+})
+
+// StreamDirective marks a list field for incremental delivery of its items
+var StreamDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        directiveStream,
+	Description: "Directs the executor to deliver the items of this list incrementally, after the first initialCount items",
+	Locations:   []string{graphql.DirectiveLocationField},
+	Args: graphql.FieldConfigArgument{
+		&graphql.ArgumentConfig{
+			Name:         "if",
+			Type:         graphql.Boolean,
+			DefaultValue: true,
+		},
+		&graphql.ArgumentConfig{
+			Name: "label",
+			Type: graphql.String,
+		},
+		&graphql.ArgumentConfig{
+			Name:         "initialCount",
+			Type:         graphql.Int,
+			DefaultValue: 0,
+		},
+	},
+})
+
 // SchemaDirectiveVisitor defines a schema visitor.
 // This attempts to provide similar functionality to Apollo graphql-tools
 // https://www.apollographql.com/docs/graphql-tools/schema-directives/
@@ -133,11 +179,16 @@ type SchemaDirectiveVisitorMap map[string]*SchemaDirectiveVisitor
 // DirectiveMap a map of directives
 type DirectiveMap map[string]*graphql.Directive
 
-// converts the directive map to an array
+// converts the directive map to an array in the registry order
 func (c *registry) directiveArray() []*graphql.Directive {
-	a := make([]*graphql.Directive, 0)
-	for _, d := range c.directives {
-		a = append(a, d)
+	names := make(map[string]bool, len(c.directives))
+	for name := range c.directives {
+		names[name] = true
+	}
+
+	a := make([]*graphql.Directive, 0, len(names))
+	for _, name := range c.orderNames(names, builtinDirectiveNames, true) {
+		a = append(a, c.directives[name])
 	}
 	return a
 }
@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"errors"
+
+	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/google/uuid"
+)
+
+// Error codes sent in the code extension
+const (
+	CodeInternal        = "INTERNAL_SERVER_ERROR"
+	CodeBadUserInput    = "BAD_USER_INPUT"
+	CodeUnauthenticated = "UNAUTHENTICATED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeNotFound        = "NOT_FOUND"
+)
+
+// Error is an error returned by resolvers with a code and a message that is
+// safe to show to users. The cause is only logged
+type Error struct {
+	Code       string
+	Message    string
+	Cause      error
+	extensions map[string]any
+}
+
+// NewError creates a new error
+func NewError(code, message string, cause error) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+		Cause:   cause,
+	}
+}
+
+// NewUserError reports invalid input
+func NewUserError(message string) *Error {
+	return NewError(CodeBadUserInput, message, nil)
+}
+
+// NewAuthError reports a missing or invalid authentication
+func NewAuthError(message string) *Error {
+	return NewError(CodeUnauthenticated, message, nil)
+}
+
+// NewForbidden reports an authenticated user without access
+func NewForbidden(message string) *Error {
+	return NewError(CodeForbidden, message, nil)
+}
+
+// NewNotFound reports a missing resource
+func NewNotFound(message string) *Error {
+	return NewError(CodeNotFound, message, nil)
+}
+
+// NewInternalError wraps an unexpected error, its message is masked by
+// formatters that mask internal errors
+func NewInternalError(cause error) *Error {
+	return NewError(CodeInternal, cause.Error(), cause)
+}
+
+// Error returns the user safe message, the cause is available with
+// errors.Unwrap
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the cause
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithCause sets the internal cause of the error
+func (e *Error) WithCause(cause error) *Error {
+	e.Cause = cause
+	return e
+}
+
+// WithExtension adds an extension sent along with the code
+func (e *Error) WithExtension(key string, value any) *Error {
+	if e.extensions == nil {
+		e.extensions = map[string]any{}
+	}
+	e.extensions[key] = value
+	return e
+}
+
+// Extensions returns the extensions including the code, so errors are
+// formatted with their code even without an ErrorFormatter
+func (e *Error) Extensions() map[string]any {
+	extensions := make(map[string]any, len(e.extensions)+1)
+	for key, value := range e.extensions {
+		extensions[key] = value
+	}
+	extensions["code"] = e.Code
+	return extensions
+}
+
+// ErrorFormatterConfig configures NewErrorFormatter
+type ErrorFormatterConfig struct {
+	// Mask replaces the message of internal errors with MaskedMessage, enable
+	// it in production
+	Mask bool
+
+	// MaskedMessage defaults to "Internal server error"
+	MaskedMessage string
+
+	// Logger logs internal errors with their correlation ID
+	Logger logger.Logger
+}
+
+// NewErrorFormatter creates a formatter for server.Options.FormatErrorFunc
+// and handler.Config.FormatErrorFn. Errors of the parser, the validation and
+// the executor are formatted as is, *Error gets its code and extensions.
+// Every other error returned by a resolver is internal, it gets the
+// INTERNAL_SERVER_ERROR code and a correlationId extension that identifies
+// its log line
+func NewErrorFormatter(config ErrorFormatterConfig) func(err error) gqlerrors.FormattedError {
+	if config.MaskedMessage == "" {
+		config.MaskedMessage = "Internal server error"
+	}
+	if config.Logger == nil {
+		config.Logger = &logger.NoopLogger{}
+	}
+
+	return func(err error) gqlerrors.FormattedError {
+		formatted := gqlerrors.FormatError(err)
+
+		// resolver errors are located errors wrapping the returned error
+		original := err
+		if located, ok := err.(*gqlerrors.Error); ok {
+			original = located.OriginalError
+		}
+		if original == nil {
+			return formatted
+		}
+		if _, ok := original.(gqlerrors.FormattedError); ok {
+			return formatted
+		}
+
+		var toolsErr *Error
+		if errors.As(original, &toolsErr) && toolsErr.Code != CodeInternal {
+			formatted.Message = toolsErr.Message
+			formatted.Extensions = toolsErr.Extensions()
+			return formatted
+		}
+
+		correlationID := uuid.New().String()
+		config.Logger.Error("internal error", "correlationId", correlationID, "error", original, "path", formatted.Path)
+
+		if config.Mask {
+			formatted.Message = config.MaskedMessage
+		}
+		formatted.Extensions = map[string]any{
+			"code":          CodeInternal,
+			"correlationId": correlationID,
+		}
+		return formatted
+	}
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/server/logger"
+)
+
+// records the fields of error log lines
+type errorRecorder struct {
+	logger.NoopLogger
+	fields [][]any
+}
+
+func (r *errorRecorder) Error(msg string, fields ...any) {
+	r.fields = append(r.fields, fields)
+}
+
+func TestErrorFormatter(t *testing.T) {
+	schema, err := MakeExecutableSchema(ExecutableSchema{
+		TypeDefs: `
+type Query {
+	user: String
+	missing: String
+	internal: String
+	wrapped: String
+}`,
+		Resolvers: map[string]any{
+			"Query": &ObjectResolver{
+				Fields: FieldResolveMap{
+					"user": &FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return nil, NewUserError("invalid id").WithExtension("argument", "id")
+						},
+					},
+					"missing": &FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return nil, fmt.Errorf("loading user: %w", NewNotFound("user not found").WithCause(errors.New("no rows")))
+						},
+					},
+					"internal": &FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return nil, errors.New("connection refused")
+						},
+					},
+					"wrapped": &FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return nil, NewInternalError(errors.New("disk full"))
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to make schema: %v", err)
+		return
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+		logged   int
+	}{
+		{
+			`{ user }`,
+			`[{"message":"invalid id","locations":[{"line":1,"column":3}],"path":["user"],"extensions":{"argument":"id","code":"BAD_USER_INPUT"}}]`,
+			0,
+		},
+		{
+			`{ missing }`,
+			`[{"message":"user not found","locations":[{"line":1,"column":3}],"path":["missing"],"extensions":{"code":"NOT_FOUND"}}]`,
+			0,
+		},
+		{
+			`{ internal }`,
+			`[{"message":"Internal server error","locations":[{"line":1,"column":3}],"path":["internal"],"extensions":{"code":"INTERNAL_SERVER_ERROR","correlationId":"ID"}}]`,
+			1,
+		},
+		{
+			`{ wrapped }`,
+			`[{"message":"Internal server error","locations":[{"line":1,"column":3}],"path":["wrapped"],"extensions":{"code":"INTERNAL_SERVER_ERROR","correlationId":"ID"}}]`,
+			1,
+		},
+		{
+			`{ unknown }`,
+			`[{"message":"Cannot query field \"unknown\" on type \"Query\".","locations":[{"line":1,"column":3}]}]`,
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		recorder := &errorRecorder{}
+		format := NewErrorFormatter(ErrorFormatterConfig{Mask: true, Logger: recorder})
+
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: test.query})
+		for i, formattedError := range result.Errors {
+			err := formattedError.OriginalError()
+			if err == nil {
+				err = formattedError
+			}
+			result.Errors[i] = format(err)
+		}
+
+		correlationID := ""
+		if len(result.Errors) == 1 && result.Errors[0].Extensions != nil {
+			correlationID, _ = result.Errors[0].Extensions["correlationId"].(string)
+		}
+
+		data, _ := json.Marshal(result.Errors)
+		actual := string(data)
+		if correlationID != "" {
+			actual = strings.Replace(actual, correlationID, "ID", 1)
+		}
+		if actual != test.expected {
+			t.Errorf("unexpected errors for %s: %s", test.query, data)
+			return
+		}
+
+		if len(recorder.fields) != test.logged {
+			t.Errorf("expected %d log lines for %s, got %d", test.logged, test.query, len(recorder.fields))
+			return
+		}
+		if test.logged > 0 && recorder.fields[0][1] != correlationID {
+			t.Errorf("expected the log line to include the correlation ID, got %v", recorder.fields[0])
+			return
+		}
+	}
+}
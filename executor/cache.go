@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/language/ast"
+)
+
+// DocumentCache is an LRU cache of parsed and validated documents keyed by
+// the schema they were validated against and a hash of the query. Documents
+// of a replaced schema are evicted as they become the least recently used,
+// so operations still running on the old schema keep hitting the cache
+type DocumentCache struct {
+	mx        sync.Mutex
+	capacity  int
+	entries   map[cacheKey]*list.Element
+	order     *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// CacheStats are the metrics of a DocumentCache
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+}
+
+type cacheKey struct {
+	schema *graphql.Schema
+	query  [sha256.Size]byte
+}
+
+type cacheEntry struct {
+	key cacheKey
+	doc *ast.Document
+}
+
+// NewDocumentCache creates a cache holding up to capacity documents
+func NewDocumentCache(capacity int) *DocumentCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &DocumentCache{
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the cached document of a query validated against the schema
+func (c *DocumentCache) Get(schema *graphql.Schema, query string) (*ast.Document, bool) {
+	key := cacheKey{schema: schema, query: sha256.Sum256([]byte(query))}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.hits++
+		return elem.Value.(*cacheEntry).doc, true
+	}
+
+	c.misses++
+	return nil, false
+}
+
+// Add caches the document of a query that is valid for the schema
+func (c *DocumentCache) Add(schema *graphql.Schema, query string, doc *ast.Document) {
+	key := cacheKey{schema: schema, query: sha256.Sum256([]byte(query))}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		elem.Value.(*cacheEntry).doc = doc
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, doc: doc})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+// Purge removes every document
+func (c *DocumentCache) Purge() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.purge()
+}
+
+// Stats returns the metrics of the cache
+func (c *DocumentCache) Stats() CacheStats {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.order.Len(),
+		Capacity:  c.capacity,
+	}
+}
+
+func (c *DocumentCache) purge() {
+	c.entries = make(map[cacheKey]*list.Element)
+	c.order.Init()
+}
@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"testing"
+)
+
+func TestDocumentCache(t *testing.T) {
+	schema := testSchema(t)
+	cache := NewDocumentCache(2)
+	e := New(&Config{Schema: schema, Cache: cache})
+
+	for _, query := range []string{`{ hello }`, `{ hello }`, `{ fail }`, `{ nope }`, `{ nope }`, `query A { hello }`, `{ hello }`} {
+		e.Execute(&Request{Query: query})
+	}
+
+	// invalid documents are never cached and the least recently used are evicted
+	expected := CacheStats{Hits: 1, Misses: 6, Evictions: 2, Size: 2, Capacity: 2}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("unexpected stats %+v", stats)
+		return
+	}
+
+	// documents are cached per schema, replacing the schema does not evict
+	// the documents of operations still running on the old one
+	other := testSchema(t)
+	if _, ok := cache.Get(other, `{ hello }`); ok {
+		t.Error("expected a miss for another schema")
+		return
+	}
+	e.SetSchema(other)
+	e.Execute(&Request{Query: `{ hello }`})
+	if _, ok := cache.Get(schema, `{ hello }`); !ok {
+		t.Error("expected the document of the old schema to be kept")
+		return
+	}
+	if _, ok := cache.Get(other, `{ hello }`); !ok {
+		t.Error("expected the document of the new schema to be cached")
+		return
+	}
+}
@@ -0,0 +1,343 @@
+// Package executor runs GraphQL operations for every transport through a
+// single pipeline so plugins apply to HTTP, WebSocket and incremental delivery
+// alike
+package executor
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/incremental"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/parser"
+	"github.com/dagger/graphql/language/source"
+)
+
+// Transports
+const (
+	TransportHTTP      = "http"
+	TransportWebSocket = "websocket"
+)
+
+// Request is an operation received by a transport
+type Request struct {
+	Query         string
+	Variables     map[string]any
+	OperationName string
+	RootObject    map[string]any
+	Context       context.Context
+
+	// DocumentID identifies a registered document sent instead of the query
+	// text, plugins like trusted documents replace it with the query
+	DocumentID string
+
+	// Extensions are the extensions of the request, like persistedQuery
+	Extensions map[string]any
+
+	// Transport is the name of the transport the operation was received on
+	Transport string
+
+	// HTTPRequest is the HTTP request of the operation, or the upgrade
+	// request of a WebSocket connection
+	HTTPRequest *http.Request
+
+	// Schema is set by the executor to the schema the operation runs against
+	Schema *graphql.Schema
+
+	// Executed is set by the executor once execution starts, requests that
+	// fail to parse or validate or that a plugin rejects are not executed
+	Executed bool
+
+	// Cached is set by the executor when a ResultCache plugin served the
+	// result instead of executing the operation
+	Cached bool
+}
+
+// Params converts the request to graphql params
+func (req *Request) Params(schema graphql.Schema) graphql.Params {
+	return graphql.Params{
+		Schema:         schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		RootObject:     req.RootObject,
+		Context:        req.Context,
+	}
+}
+
+// Config configures an executor
+type Config struct {
+	Schema  *graphql.Schema
+	Plugins []Plugin
+
+	// FormatErrorFn replaces the formatting of every error in a result
+	FormatErrorFn func(err error) gqlerrors.FormattedError
+
+	// Cache skips parsing and validation of queries that have already been
+	// validated against the schema. Without a cache queries and mutations go
+	// through graphql.Do, so the Init, ParseDidStart and ValidationDidStart
+	// hooks of the graphql extensions of the schema run for them. With a cache
+	// the extensions only see the execution
+	Cache *DocumentCache
+}
+
+// Executor parses, validates and executes operations, running the plugins at
+// each stage
+type Executor struct {
+	schema        atomic.Pointer[schemaState]
+	plugins       []Plugin
+	formatErrorFn func(err error) gqlerrors.FormattedError
+	cache         *DocumentCache
+}
+
+// New creates a new executor
+func New(config *Config) *Executor {
+	e := &Executor{
+		plugins:       config.Plugins,
+		formatErrorFn: config.FormatErrorFn,
+		cache:         config.Cache,
+	}
+	e.SetSchema(config.Schema)
+	return e
+}
+
+// schemaState is a schema along with the state kept for it, operations keep
+// the state of the schema they started with
+type schemaState struct {
+	schema      *graphql.Schema
+	incremental *incremental.Executor
+}
+
+// Schema returns the schema operations are executed against
+func (e *Executor) Schema() *graphql.Schema {
+	return e.schema.Load().schema
+}
+
+// SetSchema replaces the schema of new operations, operations that already
+// started finish on the previous schema
+func (e *Executor) SetSchema(schema *graphql.Schema) {
+	e.schema.Store(&schemaState{
+		schema:      schema,
+		incremental: incremental.NewExecutor(schema),
+	})
+}
+
+// Execute executes a query or mutation, or serves its result from a
+// ResultCache plugin
+func (e *Executor) Execute(req *Request) *graphql.Result {
+	state := e.schema.Load()
+	ctx, doc, result := e.prepare(state, req)
+	if result != nil {
+		return result
+	}
+
+	for _, plugin := range e.plugins {
+		if cache, ok := plugin.(ResultCache); ok {
+			if result, ok := cache.Lookup(ctx, req); ok {
+				req.Cached = true
+				return e.finish(ctx, req, result)
+			}
+		}
+	}
+
+	req.Executed = true
+	if e.cache == nil {
+		return e.finish(ctx, req, graphql.Do(e.params(ctx, req)))
+	}
+	return e.finish(ctx, req, graphql.Execute(e.executeParams(ctx, req, doc)))
+}
+
+// Subscribe executes a subscription and delivers a result for each event. The
+// channel is closed once the subscription ends or the request context is done
+func (e *Executor) Subscribe(req *Request) chan *graphql.Result {
+	state := e.schema.Load()
+	ctx, doc, result := e.prepare(state, req)
+	if result != nil {
+		ch := make(chan *graphql.Result, 1)
+		ch <- result
+		close(ch)
+		return ch
+	}
+
+	req.Executed = true
+	source := graphql.ExecuteSubscription(e.executeParams(ctx, req, doc))
+	ch := make(chan *graphql.Result)
+	go func() {
+		defer close(ch)
+		for result := range source {
+			result = e.finish(ctx, req, result)
+
+			// keep draining the source once the request is done so the
+			// subscription can end
+			select {
+			case ch <- result:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Incremental executes an operation with @defer and @stream and delivers the
+// initial payload followed by the patches
+func (e *Executor) Incremental(req *Request) <-chan *incremental.Payload {
+	state := e.schema.Load()
+	ctx, doc, result := e.prepare(state, req)
+	if result != nil {
+		ch := make(chan *incremental.Payload, 1)
+		ch <- &incremental.Payload{Data: result.Data, Errors: result.Errors, Extensions: result.Extensions}
+		close(ch)
+		return ch
+	}
+
+	req.Executed = true
+	source := state.incremental.Execute(e.executeParams(ctx, req, doc))
+	ch := make(chan *incremental.Payload)
+	go func() {
+		defer close(ch)
+		for payload := range source {
+			result := e.finish(ctx, req, &graphql.Result{
+				Data:       payload.Data,
+				Errors:     payload.Errors,
+				Extensions: payload.Extensions,
+			})
+			payload.Data, payload.Errors, payload.Extensions = result.Data, result.Errors, result.Extensions
+
+			// keep draining the source once the request is done so the
+			// execution can end
+			select {
+			case ch <- payload:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return ch
+}
+
+// runs the stages before execution, a non nil result ends the request
+func (e *Executor) prepare(state *schemaState, req *Request) (context.Context, *ast.Document, *graphql.Result) {
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// the operation keeps the schema it started with when it is replaced
+	schema := state.schema
+	req.Schema = schema
+
+	var err error
+	for _, plugin := range e.plugins {
+		if ctx, err = plugin.OnRequest(ctx, req); err != nil {
+			return ctx, nil, e.reject(ctx, req, err)
+		}
+	}
+
+	// cached documents have already been parsed and validated, the plugins
+	// still run for every request
+	var doc *ast.Document
+	cached := false
+	if e.cache != nil {
+		doc, cached = e.cache.Get(schema, req.Query)
+	}
+
+	if !cached {
+		if doc, err = parser.Parse(parser.ParseParams{
+			Source: source.NewSource(&source.Source{
+				Body: []byte(req.Query),
+				Name: "GraphQL request",
+			}),
+		}); err != nil {
+			return ctx, nil, e.invalid(ctx, req, gqlerrors.FormatErrors(err))
+		}
+	}
+
+	for _, plugin := range e.plugins {
+		if err := plugin.OnParse(ctx, req, doc); err != nil {
+			return ctx, nil, e.reject(ctx, req, err)
+		}
+	}
+
+	validation := graphql.ValidationResult{IsValid: true}
+	if !cached {
+		if validation = graphql.ValidateDocument(schema, doc, nil); !validation.IsValid {
+			return ctx, nil, e.invalid(ctx, req, validation.Errors)
+		}
+		if e.cache != nil {
+			e.cache.Add(schema, req.Query, doc)
+		}
+	}
+
+	for _, plugin := range e.plugins {
+		if err := plugin.OnValidate(ctx, req, doc, &validation); err != nil {
+			return ctx, nil, e.reject(ctx, req, err)
+		}
+	}
+
+	for _, plugin := range e.plugins {
+		if ctx, err = plugin.OnExecute(ctx, req, doc); err != nil {
+			return ctx, nil, e.reject(ctx, req, err)
+		}
+	}
+
+	return ctx, doc, nil
+}
+
+// creates the result of a document that failed to parse or validate. without
+// a document cache the request goes through graphql.Do so that the graphql
+// extensions of the schema see the failure
+func (e *Executor) invalid(ctx context.Context, req *Request, errs []gqlerrors.FormattedError) *graphql.Result {
+	if e.cache == nil {
+		return e.finish(ctx, req, graphql.Do(e.params(ctx, req)))
+	}
+	return e.finish(ctx, req, &graphql.Result{Errors: errs})
+}
+
+// creates the result of a request that failed before execution
+func (e *Executor) reject(ctx context.Context, req *Request, err error) *graphql.Result {
+	return e.finish(ctx, req, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+}
+
+// formats the errors of a result and runs the OnError and OnResult hooks
+func (e *Executor) finish(ctx context.Context, req *Request, result *graphql.Result) *graphql.Result {
+	for i, formattedError := range result.Errors {
+		err := formattedError.OriginalError()
+		if err == nil {
+			err = formattedError
+		}
+
+		for _, plugin := range e.plugins {
+			plugin.OnError(ctx, req, err)
+		}
+
+		if e.formatErrorFn != nil {
+			result.Errors[i] = e.formatErrorFn(err)
+		}
+	}
+
+	for _, plugin := range e.plugins {
+		plugin.OnResult(ctx, req, result)
+	}
+
+	return result
+}
+
+func (e *Executor) params(ctx context.Context, req *Request) graphql.Params {
+	params := req.Params(*req.Schema)
+	params.Context = ctx
+	return params
+}
+
+func (e *Executor) executeParams(ctx context.Context, req *Request, doc *ast.Document) graphql.ExecuteParams {
+	return graphql.ExecuteParams{
+		Schema:        *req.Schema,
+		Root:          req.RootObject,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	}
+}
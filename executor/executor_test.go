@@ -0,0 +1,269 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/location"
+)
+
+func testSchema(t *testing.T) *graphql.Schema {
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type Query {
+	hello: String
+	fail: String
+}
+
+type Subscription {
+	count: Int
+}`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return p.Context.Value(userKey{}), nil
+						},
+					},
+					"fail": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return nil, errors.New("failed")
+						},
+					},
+				},
+			},
+			"Subscription": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"count": &tools.FieldResolve{
+						Subscribe: func(p graphql.ResolveParams) (any, error) {
+							ch := make(chan any, 2)
+							ch <- 1
+							ch <- 2
+							close(ch)
+							return ch, nil
+						},
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return p.Source, nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+	return &schema
+}
+
+type userKey struct{}
+
+// records the hooks it is called with and authenticates the user header
+type recorder struct {
+	NoopPlugin
+	calls []string
+}
+
+func (r *recorder) OnRequest(ctx context.Context, req *Request) (context.Context, error) {
+	r.calls = append(r.calls, "request")
+	if req.Variables["user"] == nil {
+		return ctx, errors.New("unauthenticated")
+	}
+	return context.WithValue(ctx, userKey{}, req.Variables["user"]), nil
+}
+
+func (r *recorder) OnParse(ctx context.Context, req *Request, doc *ast.Document) error {
+	r.calls = append(r.calls, "parse")
+	return nil
+}
+
+func (r *recorder) OnValidate(ctx context.Context, req *Request, doc *ast.Document, result *graphql.ValidationResult) error {
+	r.calls = append(r.calls, "validate")
+	return nil
+}
+
+func (r *recorder) OnExecute(ctx context.Context, req *Request, doc *ast.Document) (context.Context, error) {
+	r.calls = append(r.calls, "execute")
+	return ctx, nil
+}
+
+func (r *recorder) OnResult(ctx context.Context, req *Request, result *graphql.Result) {
+	r.calls = append(r.calls, "result")
+}
+
+func (r *recorder) OnError(ctx context.Context, req *Request, err error) {
+	r.calls = append(r.calls, "error: "+err.Error())
+}
+
+func TestExecutor(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		user     any
+		expected string
+		calls    string
+	}{
+		{"ok", `{ hello }`, "alice", `{"data":{"hello":"alice"}}`, "request,parse,validate,execute,result"},
+		{"rejected", `{ hello }`, nil, `{"data":null,"errors":[{"message":"UNAUTHENTICATED","locations":[]}]}`, "request,error: unauthenticated,result"},
+		{"syntax", `{ hello`, "alice", "", "request,error: Syntax Error GraphQL request (1:8) Expected Name, found EOF\n\n1: { hello\n          ^\n,result"},
+		{"invalid", `{ nope }`, "alice", "", `request,parse,error: Cannot query field "nope" on type "Query".,result`},
+		{"resolver", `{ fail }`, "alice", `{"data":{"fail":null},"errors":[{"message":"FAILED","locations":[],"path":["fail"]}]}`, "request,parse,validate,execute,error: failed,result"},
+	}
+
+	for _, test := range tests {
+		plugin := &recorder{}
+		e := New(&Config{
+			Schema:  testSchema(t),
+			Plugins: []Plugin{plugin},
+			FormatErrorFn: func(err error) gqlerrors.FormattedError {
+				return gqlerrors.FormattedError{Message: strings.ToUpper(err.Error()), Locations: []location.SourceLocation{}, Path: gqlerrors.FormatError(err).Path}
+			},
+		})
+
+		result := e.Execute(&Request{Query: test.query, Variables: map[string]any{"user": test.user}})
+		if j, _ := json.Marshal(result); test.expected != "" && string(j) != test.expected {
+			t.Errorf("%s: unexpected result %s", test.name, j)
+		}
+		if calls := strings.Join(plugin.calls, ","); calls != test.calls {
+			t.Errorf("%s: unexpected calls %q", test.name, calls)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	plugin := &recorder{}
+	e := New(&Config{Schema: testSchema(t), Plugins: []Plugin{plugin}})
+
+	results := []string{}
+	for result := range e.Subscribe(&Request{Query: `subscription { count }`, Variables: map[string]any{"user": "alice"}}) {
+		j, _ := json.Marshal(result)
+		results = append(results, string(j))
+	}
+
+	if strings.Join(results, ",") != `{"data":{"count":1}},{"data":{"count":2}}` {
+		t.Errorf("unexpected results %v", results)
+		return
+	}
+	if calls := strings.Join(plugin.calls, ","); calls != "request,parse,validate,execute,result,result" {
+		t.Errorf("unexpected calls %q", calls)
+		return
+	}
+}
+
+func TestIncrementalCanceled(t *testing.T) {
+	e := New(&Config{Schema: testSchema(t)})
+	before := runtime.NumGoroutine()
+
+	// the consumer stops reading once the request is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	e.Incremental(&Request{
+		Query:     `{ hello ... @defer { fail } }`,
+		Variables: map[string]any{"user": "alice"},
+		Context:   ctx,
+	})
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Errorf("expected the incremental delivery to end, %d goroutines left", runtime.NumGoroutine()-before)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// records the graphql extension hooks it is called with
+type extensionRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *extensionRecorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.calls {
+		if c == call {
+			return
+		}
+	}
+	r.calls = append(r.calls, call)
+}
+
+func (r *extensionRecorder) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	r.record("init")
+	return ctx
+}
+
+func (r *extensionRecorder) Name() string {
+	return "recorder"
+}
+
+func (r *extensionRecorder) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	r.record("parse")
+	return ctx, func(error) {}
+}
+
+func (r *extensionRecorder) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	r.record("validate")
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (r *extensionRecorder) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	r.record("execute")
+	return ctx, func(*graphql.Result) {}
+}
+
+func (r *extensionRecorder) ResolveFieldDidStart(ctx context.Context, _ *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	r.record("resolve")
+	return ctx, func(any, error) {}
+}
+
+func (r *extensionRecorder) HasResult() bool {
+	return false
+}
+
+func (r *extensionRecorder) GetResult(context.Context) any {
+	return nil
+}
+
+func TestExtensionHooks(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		cache bool
+		calls string
+	}{
+		{"executed", `{ hello }`, false, "init,parse,validate,execute,resolve"},
+		{"invalid", `{ nope }`, false, "init,parse,validate"},
+		{"syntax", `{ hello`, false, "init,parse"},
+		{"cached", `{ hello }`, true, "execute,resolve"},
+	}
+
+	for _, test := range tests {
+		ext := &extensionRecorder{}
+		schema := testSchema(t)
+		schema.AddExtensions(ext)
+
+		config := &Config{Schema: schema}
+		if test.cache {
+			config.Cache = NewDocumentCache(10)
+		}
+		New(config).Execute(&Request{Query: test.query})
+
+		if calls := strings.Join(ext.calls, ","); calls != test.calls {
+			t.Errorf("%s: expected hooks %q, got %q", test.name, test.calls, calls)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/language/ast"
+)
+
+// Plugin hooks into every stage of an operation, regardless of the transport
+// it was received on. Embed NoopPlugin to only implement some of the hooks
+type Plugin interface {
+	// OnRequest is called before the query is parsed. The returned context
+	// replaces the request context, an error rejects the request
+	OnRequest(ctx context.Context, req *Request) (context.Context, error)
+
+	// OnParse is called with the parsed document, an error rejects the request
+	OnParse(ctx context.Context, req *Request, doc *ast.Document) error
+
+	// OnValidate is called with the validation result of a valid document and
+	// can apply additional rules, an error rejects the request
+	OnValidate(ctx context.Context, req *Request, doc *ast.Document, result *graphql.ValidationResult) error
+
+	// OnExecute is called before the operation is executed. The returned
+	// context is passed to the resolvers, an error rejects the request
+	OnExecute(ctx context.Context, req *Request, doc *ast.Document) (context.Context, error)
+
+	// OnResult is called with every result before it is sent, subscriptions
+	// produce a result for each event
+	OnResult(ctx context.Context, req *Request, result *graphql.Result)
+
+	// OnError is called with every error reported to the client, including
+	// rejected requests, parse and validation errors and resolver errors
+	OnError(ctx context.Context, req *Request, err error)
+}
+
+// NoopPlugin implements Plugin with hooks that do nothing
+type NoopPlugin struct{}
+
+func (NoopPlugin) OnRequest(ctx context.Context, req *Request) (context.Context, error) {
+	return ctx, nil
+}
+
+func (NoopPlugin) OnParse(ctx context.Context, req *Request, doc *ast.Document) error {
+	return nil
+}
+
+func (NoopPlugin) OnValidate(ctx context.Context, req *Request, doc *ast.Document, result *graphql.ValidationResult) error {
+	return nil
+}
+
+func (NoopPlugin) OnExecute(ctx context.Context, req *Request, doc *ast.Document) (context.Context, error) {
+	return ctx, nil
+}
+
+func (NoopPlugin) OnResult(ctx context.Context, req *Request, result *graphql.Result) {}
+
+func (NoopPlugin) OnError(ctx context.Context, req *Request, err error) {}
+
+// ResultCache is implemented by plugins that serve the result of a query
+// without executing it. Lookup is called after the OnExecute hooks so that
+// requests rejected by any plugin are never served from a cache, the result
+// then goes through the OnError and OnResult hooks like an executed one
+type ResultCache interface {
+	Lookup(ctx context.Context, req *Request) (*graphql.Result, bool)
+}
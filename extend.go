@@ -0,0 +1,271 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/kinds"
+)
+
+// ExtendSchema makes a new schema from the types and directives of an
+// existing schema and the TypeDefs of config. Objects of the base schema can
+// be extended with extend type and have SchemaDirectives applied. Their field
+// resolvers are kept unless config.Resolvers replaces them. The base schema
+// is not modified and its graphql extensions are not carried over
+func ExtendSchema(base graphql.Schema, config ExecutableSchema) (graphql.Schema, error) {
+	return config.make(context.Background(), &base)
+}
+
+// an object of the base schema, its fields and interfaces are set once the
+// type definitions are resolved
+type importedObject struct {
+	base       *graphql.Object
+	object     *graphql.Object
+	fields     graphql.Fields
+	interfaces []*graphql.Interface
+}
+
+// adds the types and directives of a base schema to the registry. Objects,
+// interfaces and unions are copied so that their fields point to the types
+// of the registry, scalars, enums and inputs are shared
+func (c *registry) importSchema(base *graphql.Schema) error {
+	for _, def := range c.document.Definitions {
+		name, kind := graphNode(def)
+		if name == "" || kind == GraphKindDirective {
+			continue
+		}
+		if _, ok := base.TypeMap()[name]; ok {
+			return fmt.Errorf("type %q is already defined in the base schema, use extend type to add fields", name)
+		}
+	}
+
+	for _, directive := range base.Directives() {
+		if _, ok := c.directives[directive.Name]; !ok {
+			c.directives[directive.Name] = directive
+		}
+	}
+
+	for name, t := range base.TypeMap() {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		if _, ok := c.types[name]; ok {
+			continue
+		}
+
+		switch t := t.(type) {
+		case *graphql.Object:
+			imported := &importedObject{base: t}
+			imported.object = graphql.NewObject(graphql.ObjectConfig{
+				Name:        name,
+				Description: t.Description(),
+				IsTypeOf:    t.IsTypeOf,
+				Interfaces: graphql.InterfacesThunk(func() []*graphql.Interface {
+					return imported.interfaces
+				}),
+				Fields: graphql.FieldsThunk(func() graphql.Fields {
+					return imported.fields
+				}),
+			})
+			c.imports = append(c.imports, imported)
+			c.types[name] = imported.object
+
+		case *graphql.Interface:
+			iface := t
+			resolveType := c.importResolveType(t.ResolveType)
+			if r, ok := c.getResolver(name).(*InterfaceResolver); ok && r.ResolveType != nil {
+				resolveType = r.ResolveType
+			}
+			c.types[name] = graphql.NewInterface(graphql.InterfaceConfig{
+				Name:        name,
+				Description: t.Description(),
+				ResolveType: resolveType,
+				Fields: graphql.FieldsThunk(func() graphql.Fields {
+					return c.importFields(iface.Fields(), kinds.InterfaceDefinition, name)
+				}),
+			})
+
+		case *graphql.Union:
+			union := t
+			resolveType := c.importResolveType(t.ResolveType)
+			if r, ok := c.getResolver(name).(*UnionResolver); ok && r.ResolveType != nil {
+				resolveType = r.ResolveType
+			}
+			c.types[name] = graphql.NewUnion(graphql.UnionConfig{
+				Name:        name,
+				Description: t.Description(),
+				ResolveType: resolveType,
+				Types: graphql.UnionTypesThunk(func() []*graphql.Object {
+					objects := []*graphql.Object{}
+					for _, object := range union.Types() {
+						objects = append(objects, c.importType(object).(*graphql.Object))
+					}
+					return objects
+				}),
+			})
+
+		default:
+			c.types[name] = t
+		}
+	}
+
+	return nil
+}
+
+// sets the fields and interfaces of the imported objects and applies the
+// directives of their extensions
+func (c *registry) finishImports() error {
+	for _, imported := range c.imports {
+		name := imported.base.Name()
+		extensions := c.getExtensions(name, kinds.ObjectDefinition)
+
+		interfaces := []*graphql.Interface{}
+		for _, iface := range imported.base.Interfaces() {
+			interfaces = append(interfaces, c.importType(iface).(*graphql.Interface))
+		}
+		extInterfaces, err := c.buildInterfacesArrayFromAST(&ast.ObjectDefinition{}, extensions)
+		if err != nil {
+			return err
+		}
+
+		fields := c.importFields(imported.base.Fields(), kinds.ObjectDefinition, name)
+		extFields, err := c.buildFieldMapFromAST(nil, kinds.ObjectDefinition, name, extensions)
+		if err != nil {
+			return err
+		}
+		for fieldName, field := range extFields {
+			if _, ok := fields[fieldName]; ok {
+				return fmt.Errorf("field %s.%s is already defined in the base schema", name, fieldName)
+			}
+			fields[fieldName] = field
+		}
+
+		objectConfig := graphql.ObjectConfig{
+			Name:        name,
+			Description: imported.base.Description(),
+			IsTypeOf:    imported.base.IsTypeOf,
+			Interfaces:  append(interfaces, extInterfaces...),
+			Fields:      fields,
+		}
+
+		if r, ok := c.getResolver(name).(*ObjectResolver); ok && r.IsTypeOf != nil {
+			objectConfig.IsTypeOf = r.IsTypeOf
+		}
+
+		// update description from extensions if none
+		for _, extDef := range extensions {
+			if objectConfig.Description != "" {
+				break
+			}
+			objectConfig.Description = getDescription(extDef)
+		}
+
+		directiveDefs := []*ast.Directive{}
+		for _, extDef := range extensions {
+			directiveDefs = append(directiveDefs, extDef.Directives...)
+		}
+		if len(directiveDefs) > 0 {
+			if err := c.applyDirectives(applyDirectiveParams{
+				config:     &objectConfig,
+				directives: directiveDefs,
+				extensions: extensions,
+				node:       extensions[0],
+			}); err != nil {
+				return err
+			}
+		}
+
+		imported.interfaces, _ = objectConfig.Interfaces.([]*graphql.Interface)
+		imported.fields, _ = objectConfig.Fields.(graphql.Fields)
+		imported.object.PrivateDescription = objectConfig.Description
+		imported.object.IsTypeOf = objectConfig.IsTypeOf
+	}
+
+	return nil
+}
+
+// copies the fields of a base type, resolvers for the type in the registry
+// replace the resolvers of the base fields
+func (c *registry) importFields(defs graphql.FieldDefinitionMap, kind, typeName string) graphql.Fields {
+	fields := graphql.Fields{}
+	for name, def := range defs {
+		field := &graphql.Field{
+			Name:              name,
+			Description:       def.Description,
+			Type:              c.importType(def.Type).(graphql.Output),
+			Args:              graphql.FieldConfigArgument{},
+			Resolve:           def.Resolve,
+			Subscribe:         def.Subscribe,
+			DeprecationReason: def.DeprecationReason,
+		}
+
+		var fieldResolve *FieldResolve
+		switch r := c.getResolver(typeName).(type) {
+		case *ObjectResolver:
+			if kind == kinds.ObjectDefinition {
+				fieldResolve = r.Fields[name]
+			}
+		case *InterfaceResolver:
+			if kind == kinds.InterfaceDefinition {
+				fieldResolve = r.Fields[name]
+			}
+		}
+		if fieldResolve != nil {
+			if fieldResolve.Resolve != nil {
+				field.Resolve = fieldResolve.Resolve
+			}
+			if fieldResolve.Subscribe != nil {
+				field.Subscribe = fieldResolve.Subscribe
+			}
+		}
+
+		for _, arg := range def.Args {
+			field.Args = append(field.Args, &graphql.ArgumentConfig{
+				Name:         arg.Name(),
+				Type:         c.importType(arg.Type).(graphql.Input),
+				DefaultValue: arg.DefaultValue,
+				Description:  arg.Description(),
+			})
+		}
+
+		fields[name] = field
+	}
+	return fields
+}
+
+// replaces a named type of the base schema with the type of the registry
+func (c *registry) importType(t graphql.Type) graphql.Type {
+	switch t := t.(type) {
+	case *graphql.List:
+		return graphql.NewList(c.importType(t.OfType))
+	case *graphql.NonNull:
+		return graphql.NewNonNull(c.importType(t.OfType))
+	}
+
+	if imported, ok := c.types[t.Name()]; ok {
+		return imported
+	}
+	return t
+}
+
+// wraps a resolve type function of the base schema so that it returns the
+// objects of the registry
+func (c *registry) importResolveType(resolveType graphql.ResolveTypeFn) graphql.ResolveTypeFn {
+	if resolveType == nil {
+		return nil
+	}
+
+	return func(p graphql.ResolveTypeParams) *graphql.Object {
+		object := resolveType(p)
+		if object == nil {
+			return nil
+		}
+		if imported, ok := c.types[object.Name()].(*graphql.Object); ok {
+			return imported
+		}
+		return object
+	}
+}
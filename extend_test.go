@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dagger/graphql"
+)
+
+// builds a code-first schema with an interface, a deprecated field and
+// field resolvers
+func newBaseSchema(t *testing.T) graphql.Schema {
+	users := map[string]map[string]any{
+		"1": {"id": "1", "name": "alice"},
+	}
+
+	var user *graphql.Object
+	node := graphql.NewInterface(graphql.InterfaceConfig{
+		Name: "Node",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		},
+		ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+			return user
+		},
+	})
+	user = graphql.NewObject(graphql.ObjectConfig{
+		Name:       "User",
+		Interfaces: []*graphql.Interface{node},
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name": &graphql.Field{Type: graphql.String},
+			"login": &graphql.Field{
+				Type:              graphql.String,
+				DeprecationReason: "use name",
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Types: []graphql.Type{user},
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "RootQuery",
+			Fields: graphql.Fields{
+				"node": &graphql.Field{
+					Type: node,
+					Args: graphql.FieldConfigArgument{
+						{Name: "id", Type: graphql.NewNonNull(graphql.ID)},
+					},
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return users[p.Args["id"].(string)], nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("failed to make base schema: %v", err)
+	}
+	return schema
+}
+
+func TestExtendSchema(t *testing.T) {
+	base := newBaseSchema(t)
+
+	schema, err := ExtendSchema(base, ExecutableSchema{
+		TypeDefs: `
+directive @upper on FIELD_DEFINITION
+
+type Post {
+	title: String
+	author: User
+}
+
+extend type RootQuery {
+	posts: [Post]
+}
+
+extend type User {
+	posts: [Post] @upper
+}`,
+		Resolvers: map[string]any{
+			"RootQuery": &ObjectResolver{
+				Fields: FieldResolveMap{
+					"posts": &FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return []map[string]any{
+								{"title": "hello", "author": map[string]any{"id": "1", "name": "alice"}},
+							}, nil
+						},
+					},
+				},
+			},
+			"User": &ObjectResolver{
+				Fields: FieldResolveMap{
+					"posts": &FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return []map[string]any{{"title": "by " + p.Source.(map[string]any)["name"].(string)}}, nil
+						},
+					},
+				},
+			},
+		},
+		SchemaDirectives: SchemaDirectiveVisitorMap{
+			"upper": &SchemaDirectiveVisitor{
+				VisitFieldDefinition: func(v VisitFieldDefinitionParams) error {
+					resolve := v.Config.Resolve
+					v.Config.Resolve = func(p graphql.ResolveParams) (any, error) {
+						result, err := resolve(p)
+						if err != nil {
+							return result, err
+						}
+						for _, post := range result.([]map[string]any) {
+							post["title"] = strings.ToUpper(post["title"].(string))
+						}
+						return result, nil
+					}
+					return nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	r := graphql.Do(graphql.Params{
+		Schema: schema,
+		RequestString: `{
+	node(id: "1") {
+		id
+		... on User {
+			name
+			posts { title }
+		}
+	}
+	posts {
+		title
+		author { name }
+	}
+}`,
+	})
+	if r.HasErrors() {
+		t.Errorf("failed to execute: %v", r.Errors)
+		return
+	}
+
+	b, _ := json.Marshal(r.Data)
+	expected := `{"node":{"id":"1","name":"alice","posts":[{"title":"BY ALICE"}]},"posts":[{"author":{"name":"alice"},"title":"hello"}]}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+		return
+	}
+
+	if schema.Type("User").(*graphql.Object).Fields()["login"].DeprecationReason != "use name" {
+		t.Error("expected the deprecation reason of User.login to be kept")
+		return
+	}
+
+	// the base schema is not modified
+	if _, ok := base.QueryType().Fields()["posts"]; ok {
+		t.Error("expected the base query type to be unchanged")
+		return
+	}
+}
+
+func TestExtendSchemaConflict(t *testing.T) {
+	_, err := ExtendSchema(newBaseSchema(t), ExecutableSchema{
+		TypeDefs: "type User {\n\tid: ID!\n}",
+	})
+	if err == nil || !strings.Contains(err.Error(), `type "User" is already defined in the base schema`) {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+}
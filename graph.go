@@ -0,0 +1,508 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dagger/graphql/language/ast"
+)
+
+// node kinds of a dependency graph
+const (
+	GraphKindScalar    = "scalar"
+	GraphKindEnum      = "enum"
+	GraphKindInput     = "input"
+	GraphKindObject    = "object"
+	GraphKindInterface = "interface"
+	GraphKindUnion     = "union"
+	GraphKindDirective = "directive"
+)
+
+// DependencyGraph the dependencies between the types and directives defined
+// in a document. Directives are named with an @ prefix. References to types
+// that are not defined in the document, such as the built-in scalars, are
+// left out
+type DependencyGraph struct {
+	names           []string
+	index           map[string]int
+	kinds           map[string]string
+	dependencies    map[string][]string
+	dependents      map[string][]string
+	implementations map[string][]string
+	roots           []string
+}
+
+// Cycle a strongly connected component of a dependency graph
+type Cycle struct {
+	Types []string `json:"types"` // members in declaration order
+	Path  []string `json:"path"`  // closed path through every member
+}
+
+// NewDependencyGraph builds the dependency graph of a document, type
+// extensions add their dependencies to the extended type
+func NewDependencyGraph(document *ast.Document) (*DependencyGraph, error) {
+	g := &DependencyGraph{
+		index:           map[string]int{},
+		kinds:           map[string]string{},
+		dependencies:    map[string][]string{},
+		dependents:      map[string][]string{},
+		implementations: map[string][]string{},
+	}
+
+	// register the nodes first so that edges can be limited to them
+	for _, def := range document.Definitions {
+		name, kind := graphNode(def)
+		if name == "" {
+			continue
+		}
+		if _, ok := g.index[name]; ok {
+			continue
+		}
+		g.index[name] = len(g.names)
+		g.names = append(g.names, name)
+		g.kinds[name] = kind
+	}
+
+	edges := map[string]map[string]bool{}
+	add := func(from, to string) {
+		if _, ok := g.index[to]; !ok {
+			return
+		}
+		if edges[from] == nil {
+			edges[from] = map[string]bool{}
+		}
+		edges[from][to] = true
+	}
+	addType := func(from string, t ast.Type) error {
+		name, err := identifyRootType(t)
+		if err != nil {
+			return err
+		}
+		add(from, name)
+		return nil
+	}
+	addDirectives := func(from string, directives []*ast.Directive) {
+		for _, d := range directives {
+			add(from, "@"+d.Name.Value)
+		}
+	}
+	addArgs := func(from string, args []*ast.InputValueDefinition) error {
+		for _, arg := range args {
+			if err := addType(from, arg.Type); err != nil {
+				return err
+			}
+			addDirectives(from, arg.Directives)
+		}
+		return nil
+	}
+	addFields := func(from string, fields []*ast.FieldDefinition) error {
+		for _, field := range fields {
+			if err := addArgs(from, field.Arguments); err != nil {
+				return err
+			}
+			if err := addType(from, field.Type); err != nil {
+				return err
+			}
+			addDirectives(from, field.Directives)
+		}
+		return nil
+	}
+	addObject := func(def *ast.ObjectDefinition) error {
+		name := def.Name.Value
+		if _, ok := g.index[name]; !ok {
+			return nil
+		}
+		for _, iface := range def.Interfaces {
+			add(name, iface.Name.Value)
+			if _, ok := g.index[iface.Name.Value]; ok {
+				g.implementations[iface.Name.Value] = append(g.implementations[iface.Name.Value], name)
+			}
+		}
+		addDirectives(name, def.Directives)
+		return addFields(name, def.Fields)
+	}
+
+	for _, def := range document.Definitions {
+		switch d := def.(type) {
+		case *ast.ScalarDefinition:
+			addDirectives(d.Name.Value, d.Directives)
+		case *ast.EnumDefinition:
+			addDirectives(d.Name.Value, d.Directives)
+			for _, value := range d.Values {
+				addDirectives(d.Name.Value, value.Directives)
+			}
+		case *ast.InputObjectDefinition:
+			addDirectives(d.Name.Value, d.Directives)
+			if err := addArgs(d.Name.Value, d.Fields); err != nil {
+				return nil, err
+			}
+		case *ast.ObjectDefinition:
+			if err := addObject(d); err != nil {
+				return nil, err
+			}
+		case *ast.TypeExtensionDefinition:
+			if d.Definition != nil {
+				if err := addObject(d.Definition); err != nil {
+					return nil, err
+				}
+			}
+		case *ast.InterfaceDefinition:
+			addDirectives(d.Name.Value, d.Directives)
+			if err := addFields(d.Name.Value, d.Fields); err != nil {
+				return nil, err
+			}
+		case *ast.UnionDefinition:
+			addDirectives(d.Name.Value, d.Directives)
+			for _, t := range d.Types {
+				add(d.Name.Value, t.Name.Value)
+			}
+		case *ast.DirectiveDefinition:
+			if err := addArgs("@"+d.Name.Value, d.Arguments); err != nil {
+				return nil, err
+			}
+		case *ast.SchemaDefinition:
+			for _, op := range d.OperationTypes {
+				g.roots = append(g.roots, op.Type.Name.Value)
+			}
+		}
+	}
+
+	for from, tos := range edges {
+		for to := range tos {
+			g.dependencies[from] = append(g.dependencies[from], to)
+			g.dependents[to] = append(g.dependents[to], from)
+		}
+	}
+	for _, m := range []map[string][]string{g.dependencies, g.dependents, g.implementations} {
+		for _, names := range m {
+			g.sort(names)
+		}
+	}
+
+	if g.roots == nil {
+		for _, name := range []string{DefaultRootQueryName, DefaultRootMutationName, DefaultRootSubscriptionName} {
+			if _, ok := g.index[name]; ok {
+				g.roots = append(g.roots, name)
+			}
+		}
+	}
+
+	return g, nil
+}
+
+// returns the name and kind of a definition that is a node of the graph
+func graphNode(def ast.Node) (string, string) {
+	switch d := def.(type) {
+	case *ast.ScalarDefinition:
+		return d.Name.Value, GraphKindScalar
+	case *ast.EnumDefinition:
+		return d.Name.Value, GraphKindEnum
+	case *ast.InputObjectDefinition:
+		return d.Name.Value, GraphKindInput
+	case *ast.ObjectDefinition:
+		return d.Name.Value, GraphKindObject
+	case *ast.InterfaceDefinition:
+		return d.Name.Value, GraphKindInterface
+	case *ast.UnionDefinition:
+		return d.Name.Value, GraphKindUnion
+	case *ast.DirectiveDefinition:
+		return "@" + d.Name.Value, GraphKindDirective
+	}
+	return "", ""
+}
+
+// sorts names in declaration order
+func (g *DependencyGraph) sort(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		return g.index[names[i]] < g.index[names[j]]
+	})
+}
+
+// Names returns the types and directives in declaration order
+func (g *DependencyGraph) Names() []string {
+	return append([]string{}, g.names...)
+}
+
+// Kind returns the kind of a type or directive, empty if it is not defined
+func (g *DependencyGraph) Kind(name string) string {
+	return g.kinds[name]
+}
+
+// Dependencies returns the types and directives a type or directive uses
+func (g *DependencyGraph) Dependencies(name string) []string {
+	return append([]string{}, g.dependencies[name]...)
+}
+
+// Dependents returns the types and directives that use a type or directive
+func (g *DependencyGraph) Dependents(name string) []string {
+	return append([]string{}, g.dependents[name]...)
+}
+
+// Roots returns the operation types of the schema definition or the default
+// root types
+func (g *DependencyGraph) Roots() []string {
+	return append([]string{}, g.roots...)
+}
+
+// Cycles returns the strongly connected components with more than one member
+// and the types that depend on themselves
+func (g *DependencyGraph) Cycles() []Cycle {
+	cycles := []Cycle{}
+	for _, component := range g.components() {
+		if len(component) == 1 && !g.dependsOn(component[0], component[0]) {
+			continue
+		}
+		cycles = append(cycles, Cycle{
+			Types: component,
+			Path:  g.cyclePath(component),
+		})
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return g.index[cycles[i].Types[0]] < g.index[cycles[j].Types[0]]
+	})
+	return cycles
+}
+
+// Unreachable returns the types that cannot be reached from the roots or the
+// directives, interfaces reach their implementations
+func (g *DependencyGraph) Unreachable() []string {
+	reached := map[string]bool{}
+	queue := []string{}
+	visit := func(name string) {
+		if _, ok := g.index[name]; ok && !reached[name] {
+			reached[name] = true
+			queue = append(queue, name)
+		}
+	}
+
+	for _, name := range g.roots {
+		visit(name)
+	}
+	for _, name := range g.names {
+		if g.kinds[name] == GraphKindDirective {
+			visit(name)
+		}
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.dependencies[name] {
+			visit(dep)
+		}
+		for _, impl := range g.implementations[name] {
+			visit(impl)
+		}
+	}
+
+	unreachable := []string{}
+	for _, name := range g.names {
+		if !reached[name] {
+			unreachable = append(unreachable, name)
+		}
+	}
+	return unreachable
+}
+
+func (g *DependencyGraph) dependsOn(from, to string) bool {
+	for _, dep := range g.dependencies[from] {
+		if dep == to {
+			return true
+		}
+	}
+	return false
+}
+
+// finds the strongly connected components with tarjan's algorithm, the
+// components are in reverse topological order and their members in
+// declaration order
+func (g *DependencyGraph) components() [][]string {
+	var (
+		index      = 0
+		indexes    = map[string]int{}
+		lowlinks   = map[string]int{}
+		onStack    = map[string]bool{}
+		stack      = []string{}
+		components = [][]string{}
+		connect    func(name string)
+	)
+
+	connect = func(name string) {
+		indexes[name] = index
+		lowlinks[name] = index
+		index++
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, dep := range g.dependencies[name] {
+			if _, ok := indexes[dep]; !ok {
+				connect(dep)
+				if lowlinks[dep] < lowlinks[name] {
+					lowlinks[name] = lowlinks[dep]
+				}
+			} else if onStack[dep] && indexes[dep] < lowlinks[name] {
+				lowlinks[name] = indexes[dep]
+			}
+		}
+
+		if lowlinks[name] == indexes[name] {
+			component := []string{}
+			for {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[member] = false
+				component = append(component, member)
+				if member == name {
+					break
+				}
+			}
+			g.sort(component)
+			components = append(components, component)
+		}
+	}
+
+	for _, name := range g.names {
+		if _, ok := indexes[name]; !ok {
+			connect(name)
+		}
+	}
+	return components
+}
+
+// builds a closed path that starts at the first member of a component and
+// visits every member by following the shortest paths inside the component
+func (g *DependencyGraph) cyclePath(component []string) []string {
+	if len(component) == 1 {
+		return []string{component[0], component[0]}
+	}
+
+	members := map[string]bool{}
+	for _, name := range component {
+		members[name] = true
+	}
+
+	targets := append(append([]string{}, component[1:]...), component[0])
+	path := []string{component[0]}
+	visited := map[string]bool{component[0]: true}
+	for _, target := range targets {
+		if visited[target] && target != component[0] {
+			continue
+		}
+		steps := g.shortestPath(path[len(path)-1], target, members)
+		for _, step := range steps {
+			visited[step] = true
+		}
+		path = append(path, steps...)
+	}
+	return path
+}
+
+// returns the steps after from on the shortest path to to inside members
+func (g *DependencyGraph) shortestPath(from, to string, members map[string]bool) []string {
+	previous := map[string]string{}
+	queue := []string{from}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.dependencies[name] {
+			if _, ok := previous[dep]; ok || !members[dep] {
+				continue
+			}
+			previous[dep] = name
+			if dep == to {
+				steps := []string{to}
+				for step := name; step != from; step = previous[step] {
+					steps = append([]string{step}, steps...)
+				}
+				return steps
+			}
+			queue = append(queue, dep)
+		}
+	}
+	return nil
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, edges inside a
+// cycle are red and unreachable types are dashed
+func (g *DependencyGraph) WriteDOT(w io.Writer) error {
+	cyclic := map[string]int{}
+	for i, cycle := range g.Cycles() {
+		for _, name := range cycle.Types {
+			cyclic[name] = i + 1
+		}
+	}
+	unreachable := map[string]bool{}
+	for _, name := range g.Unreachable() {
+		unreachable[name] = true
+	}
+
+	shapes := map[string]string{
+		GraphKindScalar:    "plain",
+		GraphKindEnum:      "octagon",
+		GraphKindInput:     "parallelogram",
+		GraphKindObject:    "box",
+		GraphKindInterface: "ellipse",
+		GraphKindUnion:     "diamond",
+		GraphKindDirective: "note",
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph schema {")
+	fmt.Fprintln(b, "\trankdir=LR;")
+	for _, name := range g.names {
+		attrs := "shape=" + shapes[g.kinds[name]]
+		if unreachable[name] {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(b, "\t%q [%s];\n", name, attrs)
+	}
+	for _, name := range g.names {
+		for _, dep := range g.dependencies[name] {
+			if cyclic[name] != 0 && cyclic[name] == cyclic[dep] {
+				fmt.Fprintf(b, "\t%q -> %q [color=red];\n", name, dep)
+			} else {
+				fmt.Fprintf(b, "\t%q -> %q;\n", name, dep)
+			}
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// graphNodeJSON a node of the JSON encoding of a dependency graph
+type graphNodeJSON struct {
+	Name         string   `json:"name"`
+	Kind         string   `json:"kind"`
+	Dependencies []string `json:"dependencies"`
+	Dependents   []string `json:"dependents"`
+}
+
+// MarshalJSON encodes the nodes with their edges, the roots, the cycles and
+// the unreachable types
+func (g *DependencyGraph) MarshalJSON() ([]byte, error) {
+	nodes := make([]graphNodeJSON, 0, len(g.names))
+	for _, name := range g.names {
+		nodes = append(nodes, graphNodeJSON{
+			Name:         name,
+			Kind:         g.kinds[name],
+			Dependencies: g.Dependencies(name),
+			Dependents:   g.Dependents(name),
+		})
+	}
+
+	return json.Marshal(struct {
+		Nodes       []graphNodeJSON `json:"nodes"`
+		Roots       []string        `json:"roots"`
+		Cycles      []Cycle         `json:"cycles"`
+		Unreachable []string        `json:"unreachable"`
+	}{
+		Nodes:       nodes,
+		Roots:       g.Roots(),
+		Cycles:      g.Cycles(),
+		Unreachable: g.Unreachable(),
+	})
+}
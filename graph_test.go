@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var graphTypeDefs = `
+directive @auth(role: Role) on FIELD_DEFINITION
+
+enum Role {
+	ADMIN
+	USER
+}
+
+type Query {
+	node(id: ID!): Node
+	users(filter: UserFilter): [User] @auth(role: ADMIN)
+}
+
+interface Node {
+	id: ID!
+}
+
+type User implements Node {
+	id: ID!
+	team: Team
+}
+
+type Team implements Node {
+	id: ID!
+	owner: Owner
+}
+
+type Owner {
+	user: User
+}
+
+input UserFilter {
+	and: [UserFilter]
+	name: String
+}
+
+type Orphan {
+	name: String
+}
+
+extend type Orphan {
+	query: Query
+}
+`
+
+func TestDependencyGraph(t *testing.T) {
+	document, err := (&ExecutableSchema{TypeDefs: graphTypeDefs}).ConcatenateTypeDefs()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	graph, err := NewDependencyGraph(document)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name     string
+		actual   any
+		expected any
+	}{
+		{"names", graph.Names(), []string{"@auth", "Role", "Query", "Node", "User", "Team", "Owner", "UserFilter", "Orphan"}},
+		{"kind", graph.Kind("@auth"), GraphKindDirective},
+		{"dependencies", graph.Dependencies("Query"), []string{"@auth", "Node", "User", "UserFilter"}},
+		{"extension dependencies", graph.Dependencies("Orphan"), []string{"Query"}},
+		{"dependents", graph.Dependents("User"), []string{"Query", "Owner"}},
+		{"roots", graph.Roots(), []string{"Query"}},
+		{"unreachable", graph.Unreachable(), []string{"Orphan"}},
+		{"cycles", graph.Cycles(), []Cycle{
+			{Types: []string{"User", "Team", "Owner"}, Path: []string{"User", "Team", "Owner", "User"}},
+			{Types: []string{"UserFilter"}, Path: []string{"UserFilter", "UserFilter"}},
+		}},
+	}
+
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, test.actual)
+		}
+	}
+}
+
+func TestDependencyGraphExport(t *testing.T) {
+	document, err := (&ExecutableSchema{TypeDefs: graphTypeDefs}).ConcatenateTypeDefs()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	graph, err := NewDependencyGraph(document)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var dot bytes.Buffer
+	if err := graph.WriteDOT(&dot); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, expected := range []string{
+		"digraph schema {",
+		`"Orphan" [shape=box, style=dashed];`,
+		`"User" -> "Team" [color=red];`,
+		`"Query" -> "User";`,
+	} {
+		if !strings.Contains(dot.String(), expected) {
+			t.Errorf("expected DOT output to contain %s, got %s", expected, dot.String())
+			return
+		}
+	}
+
+	b, err := json.Marshal(graph)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var decoded struct {
+		Nodes []struct {
+			Name       string   `json:"name"`
+			Dependents []string `json:"dependents"`
+		} `json:"nodes"`
+		Cycles      []Cycle  `json:"cycles"`
+		Unreachable []string `json:"unreachable"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(decoded.Nodes) != 9 || decoded.Nodes[0].Name != "@auth" || len(decoded.Cycles) != 2 {
+		t.Errorf("unexpected JSON output %s", b)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dagger/graphql-go-tools/server/logger"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
@@ -46,6 +47,8 @@ type StartMessagePayload struct {
 	Query         string         `json:"query"`
 	Variables     map[string]any `json:"variables"`
 	OperationName string         `json:"operationName"`
+	DocumentID    string         `json:"documentId,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
 }
 
 // DataMessagePayload defines the result data of an operation.
@@ -98,7 +101,9 @@ type ConnectionEventHandlers struct {
 // ConnectionConfig defines the configuration parameters of a
 // GraphQL WebSocket connection.
 type ConnectionConfig struct {
-	Logger        Logger
+	// Logger is a logger.Logger or a printf style Logger of earlier
+	// versions, see logger.From
+	Logger        any
 	Authenticate  AuthenticateFunc
 	EventHandlers ConnectionEventHandlers
 }
@@ -131,7 +136,7 @@ type connection struct {
 	id         string
 	ws         *websocket.Conn
 	config     ConnectionConfig
-	logger     Logger
+	logger     logger.Logger
 	outgoing   chan OperationMessage
 	closeMutex *sync.Mutex
 	closed     bool
@@ -153,14 +158,14 @@ func NewConnection(ws *websocket.Conn, config ConnectionConfig) Connection {
 	conn.ws = ws
 	conn.context = context.Background()
 	conn.config = config
-	conn.logger = config.Logger
+	conn.logger = logger.From(config.Logger).With(logger.ConnectionIDKey, conn.id)
 	conn.closed = false
 	conn.closeMutex = &sync.Mutex{}
 	conn.outgoing = make(chan OperationMessage)
 
 	go conn.writeLoop()
 	go conn.readLoop()
-	conn.logger.Infof("Created connection")
+	conn.logger.Info("created connection")
 
 	return conn
 }
@@ -226,7 +231,7 @@ func (conn *connection) close() {
 		conn.config.EventHandlers.Close(conn)
 	}
 
-	conn.logger.Infof("closed connection")
+	conn.logger.Info("closed connection")
 }
 
 func (conn *connection) writeLoop() {
@@ -243,14 +248,14 @@ func (conn *connection) writeLoop() {
 			return
 		}
 
-		conn.logger.Debugf("send message: %s", msg.String())
+		conn.logger.Debug("send message", "message", msg.String())
 		conn.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
 
 		// Send the message to the client; if this times out, the WebSocket
 		// connection will be corrupt, hence we need to close the write loop
 		// and the connection immediately
 		if err := conn.ws.WriteJSON(msg); err != nil {
-			conn.logger.Warnf("sending message failed: %s", err)
+			conn.logger.Warn("sending message failed", "error", err)
 			return
 		}
 	}
@@ -273,18 +278,18 @@ func (conn *connection) readLoop() {
 		// see https://github.com/gorilla/websocket/blob/master/conn.go#L924 for
 		// more information on why this is necessary
 		if err != nil {
-			conn.logger.Warnf("force closing connection: %s", err)
+			conn.logger.Warn("force closing connection", "error", err)
 			conn.close()
 			return
 		}
 
-		conn.logger.Debugf("received message (%s): %s", msg.ID, msg.Type)
+		conn.logger.Debug("received message", logger.OperationIDKey, msg.ID, "type", msg.Type)
 
 		switch msg.Type {
 		case gqlConnectionAuth:
 			data := map[string]any{}
 			if err := json.Unmarshal(rawPayload, &data); err != nil {
-				conn.logger.Debugf("Invalid %s data: %v", msg.Type, err)
+				conn.logger.Debug("invalid message payload", "type", msg.Type, "error", err)
 				conn.SendError(errors.New("invalid GQL_CONNECTION_AUTH payload"))
 			} else {
 				if conn.config.Authenticate != nil {
@@ -303,7 +308,7 @@ func (conn *connection) readLoop() {
 		case gqlConnectionInit:
 			data := map[string]any{}
 			if err := json.Unmarshal(rawPayload, &data); err != nil {
-				conn.logger.Debugf("Invalid %s data: %v", msg.Type, err)
+				conn.logger.Debug("invalid message payload", "type", msg.Type, "error", err)
 				conn.SendError(errors.New("invalid GQL_CONNECTION_INIT payload"))
 			} else {
 				if conn.config.Authenticate != nil {
@@ -344,7 +349,7 @@ func (conn *connection) readLoop() {
 		// When the GraphQL WS connection is terminated by the client,
 		// close the connection and close the read loop
 		case gqlConnectionTerminate:
-			conn.logger.Debugf("connection terminated by client")
+			conn.logger.Debug("connection terminated by client")
 			conn.close()
 			return
 
@@ -352,7 +357,7 @@ func (conn *connection) readLoop() {
 		// a bug in our implementation; make this very obvious by logging
 		// an error
 		default:
-			conn.logger.Errorf("unhandled message: %s", msg.String())
+			conn.logger.Error("unhandled message", logger.OperationIDKey, msg.ID, "type", msg.Type)
 		}
 	}
 }
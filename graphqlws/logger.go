@@ -1,15 +1,9 @@
 package graphqlws
 
-type Logger interface {
-	Infof(format string, data ...any)
-	Debugf(format string, data ...any)
-	Errorf(format string, data ...any)
-	Warnf(format string, data ...any)
-}
+import "github.com/dagger/graphql-go-tools/server/logger"
 
-type noopLogger struct{}
-
-func (n *noopLogger) Infof(format string, data ...any)  {}
-func (n *noopLogger) Debugf(format string, data ...any) {}
-func (n *noopLogger) Errorf(format string, data ...any) {}
-func (n *noopLogger) Warnf(format string, data ...any)  {}
+// Logger is the printf style logger of earlier versions. It is still accepted
+// by the configurations, which also take a structured logger.Logger
+//
+// Deprecated: implement logger.Logger instead
+type Logger = logger.PrintfLogger
@@ -0,0 +1,14 @@
+package graphqlws
+
+import (
+	"net/http"
+
+	serverws "github.com/dagger/graphql-go-tools/server/graphqlws"
+)
+
+// OriginChecker creates a websocket origin check that allows the listed
+// origins. It shares the implementation of the server package, see
+// OriginChecker in server/graphqlws for the accepted origin patterns
+func OriginChecker(allowedOrigins []string) func(r *http.Request) bool {
+	return serverws.OriginChecker(allowedOrigins)
+}
@@ -3,8 +3,13 @@ package graphqlws
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql-go-tools/trusted"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -13,41 +18,90 @@ var ConnKey any = "conn"
 
 // HandlerConfig config
 type HandlerConfig struct {
-	Logger       Logger
+	// Logger is a logger.Logger or a printf style Logger of earlier
+	// versions, see logger.From
+	Logger       any
 	Authenticate AuthenticateFunc
 	Schema       graphql.Schema
 	RootValue    map[string]any
+
+	// AllowedOrigins lists the origins allowed to open a connection, see
+	// OriginChecker. When neither AllowedOrigins nor CheckOrigin are set only
+	// same origin connections are allowed, earlier versions accepted every
+	// origin. Set it to []string{"*"} to keep accepting them
+	AllowedOrigins []string
+
+	// CheckOrigin is a custom origin check that takes precedence over AllowedOrigins
+	CheckOrigin func(r *http.Request) bool
+
+	// ReadBufferSize and WriteBufferSize set the I/O buffer sizes in bytes
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// EnableCompression negotiates per message compression with the client
+	EnableCompression bool
+
+	// HandshakeTimeout limits the time spent on the upgrade handshake
+	HandshakeTimeout time.Duration
+
+	// Subprotocols lists the supported subprotocols in order of preference,
+	// defaults to graphql-ws
+	Subprotocols []string
+
+	// Plugins hook into the execution of every operation
+	Plugins []executor.Plugin
 }
 
 // NewHandler creates a new handler
 func NewHandler(config HandlerConfig) http.Handler {
 	var upgrader = websocket.Upgrader{
-		CheckOrigin:  func(r *http.Request) bool { return true },
-		Subprotocols: []string{"graphql-ws"},
+		CheckOrigin:       config.CheckOrigin,
+		ReadBufferSize:    config.ReadBufferSize,
+		WriteBufferSize:   config.WriteBufferSize,
+		EnableCompression: config.EnableCompression,
+		HandshakeTimeout:  config.HandshakeTimeout,
+		Subprotocols:      config.Subprotocols,
+	}
+
+	if upgrader.CheckOrigin == nil && len(config.AllowedOrigins) > 0 {
+		upgrader.CheckOrigin = OriginChecker(config.AllowedOrigins)
+	}
+
+	if len(upgrader.Subprotocols) == 0 {
+		upgrader.Subprotocols = []string{"graphql-ws"}
 	}
 
 	mgr := &ChanMgr{
 		conns: make(map[string]map[string]*ResultChan),
 	}
 
-	if config.Logger == nil {
-		config.Logger = &noopLogger{}
-	}
+	handlerLogger := logger.From(config.Logger)
+
+	exec := executor.New(&executor.Config{
+		Schema:  &config.Schema,
+		Plugins: config.Plugins,
+	})
 
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get("X-Request-ID")
+			if requestID == "" {
+				requestID = uuid.New().String()
+			}
+			log := handlerLogger.With(logger.RequestIDKey, requestID)
+
 			// Establish a WebSocket connection
 			var ws, err = upgrader.Upgrade(w, r, nil)
 
 			// Bail out if the WebSocket connection could not be established
 			if err != nil {
-				config.Logger.Warnf("Failed to establish WebSocket connection", err)
+				log.Warn("failed to establish WebSocket connection", "error", err)
 				return
 			}
 
 			// Close the connection early if it doesn't implement the graphql-ws protocol
 			if ws.Subprotocol() != "graphql-ws" {
-				config.Logger.Warnf("Connection does not implement the GraphQL WS protocol")
+				log.Warn("connection does not implement the GraphQL WS protocol", "subprotocol", ws.Subprotocol())
 				ws.Close()
 				return
 			}
@@ -55,10 +109,10 @@ func NewHandler(config HandlerConfig) http.Handler {
 			// Establish a GraphQL WebSocket connection
 			NewConnection(ws, ConnectionConfig{
 				Authenticate: config.Authenticate,
-				Logger:       config.Logger,
+				Logger:       log,
 				EventHandlers: ConnectionEventHandlers{
 					Close: func(conn Connection) {
-						config.Logger.Debugf("closing websocket: %s", conn.ID)
+						log.Debug("closing websocket", logger.ConnectionIDKey, conn.ID())
 						mgr.DelConn(conn.ID())
 					},
 					StartOperation: func(
@@ -66,16 +120,25 @@ func NewHandler(config HandlerConfig) http.Handler {
 						opID string,
 						data *StartMessagePayload,
 					) []error {
-						config.Logger.Debugf("start operations %s on connection %s", opID, conn.ID())
+						log := log.With(
+							logger.ConnectionIDKey, conn.ID(),
+							logger.OperationIDKey, opID,
+							logger.OperationNameKey, data.OperationName,
+						)
+						log.Debug("start operation")
 
 						ctx := context.WithValue(context.Background(), ConnKey, conn)
-						resultChannel := graphql.Subscribe(graphql.Params{
-							Schema:         config.Schema,
-							RequestString:  data.Query,
-							VariableValues: data.Variables,
-							OperationName:  data.OperationName,
-							Context:        ctx,
-							RootObject:     config.RootValue,
+						ctx = logger.WithContext(ctx, log)
+						resultChannel := exec.Subscribe(&executor.Request{
+							Query:         data.Query,
+							Variables:     data.Variables,
+							OperationName: data.OperationName,
+							DocumentID:    trusted.DocumentID(data.DocumentID, data.Extensions),
+							Extensions:    data.Extensions,
+							RootObject:    config.RootValue,
+							Context:       ctx,
+							Transport:     executor.TransportWebSocket,
+							HTTPRequest:   r,
 						})
 
 						mgr.Add(conn.ID(), opID, resultChannel)
@@ -95,7 +158,7 @@ func NewHandler(config HandlerConfig) http.Handler {
 
 									if res.HasErrors() {
 										for _, err := range res.Errors {
-											config.Logger.Debugf("subscription_error: %v", err)
+											log.Debug("subscription error", "error", err)
 											errs = append(errs, err.OriginalError())
 										}
 									}
@@ -111,7 +174,7 @@ func NewHandler(config HandlerConfig) http.Handler {
 						return nil
 					},
 					StopOperation: func(conn Connection, opID string) {
-						config.Logger.Debugf("stop operation %s on connection %s", opID, conn.ID())
+						log.Debug("stop operation", logger.ConnectionIDKey, conn.ID(), logger.OperationIDKey, opID)
 						mgr.Del(conn.ID(), opID)
 					},
 				},
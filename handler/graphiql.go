@@ -39,7 +39,7 @@ type graphiqlData struct {
 }
 
 // renderGraphiQL renders the GraphiQL GUI
-func renderGraphiQL(config *GraphiQLConfig, w http.ResponseWriter, r *http.Request, params graphql.Params) {
+func renderGraphiQL(config *GraphiQLConfig, w http.ResponseWriter, r *http.Request, params graphql.Params, result *graphql.Result) {
 	t := template.New("GraphiQL")
 	t, err := t.Parse(graphiqlTemplate)
 	if err != nil {
@@ -63,7 +63,7 @@ func renderGraphiQL(config *GraphiQLConfig, w http.ResponseWriter, r *http.Reque
 	if params.RequestString == "" {
 		resString = ""
 	} else {
-		result, err := json.MarshalIndent(graphql.Do(params), "", "  ")
+		result, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -1,22 +1,33 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
+	"time"
 
 	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/cachecontrol"
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql-go-tools/incremental"
+	"github.com/dagger/graphql-go-tools/internal/graphqlhttp"
+	"github.com/dagger/graphql-go-tools/trusted"
 	"github.com/dagger/graphql/gqlerrors"
 )
 
 // Constants
 const (
-	ContentTypeJSON           = "application/json"
-	ContentTypeGraphQL        = "application/graphql"
-	ContentTypeFormURLEncoded = "application/x-www-form-urlencoded"
+	ContentTypeJSON           = graphqlhttp.ContentTypeJSON
+	ContentTypeGraphQL        = graphqlhttp.ContentTypeGraphQL
+	ContentTypeFormURLEncoded = graphqlhttp.ContentTypeFormURLEncoded
+
+	// ContentTypeGraphQLResponse is the response media type defined by the
+	// GraphQL over HTTP specification
+	ContentTypeGraphQLResponse = graphqlhttp.ContentTypeGraphQLResponse
 )
 
 // ResultCallbackFn result callback
@@ -31,6 +42,11 @@ type Handler struct {
 	rootObjectFn     RootObjectFn
 	resultCallbackFn ResultCallbackFn
 	formatErrorFn    func(err error) gqlerrors.FormattedError
+	specCompliant    bool
+	limits           graphqlhttp.Limits
+	plugins          []executor.Plugin
+	cache            *executor.DocumentCache
+	cacheControl     *cachecontrol.CacheControl
 }
 
 // RequestOptions options
@@ -38,71 +54,111 @@ type RequestOptions struct {
 	Query         string         `json:"query" url:"query" schema:"query"`
 	Variables     map[string]any `json:"variables" url:"variables" schema:"variables"`
 	OperationName string         `json:"operationName" url:"operationName" schema:"operationName"`
+	DocumentID    string         `json:"documentId" url:"documentId" schema:"documentId"`
+	Extensions    map[string]any `json:"extensions" url:"extensions" schema:"extensions"`
 }
 
 // a workaround for getting`variables` as a JSON string
 type requestOptionsCompatibility struct {
-	Query         string `json:"query" url:"query" schema:"query"`
-	Variables     string `json:"variables" url:"variables" schema:"variables"`
-	OperationName string `json:"operationName" url:"operationName" schema:"operationName"`
+	Query         string         `json:"query" url:"query" schema:"query"`
+	Variables     string         `json:"variables" url:"variables" schema:"variables"`
+	OperationName string         `json:"operationName" url:"operationName" schema:"operationName"`
+	DocumentID    string         `json:"documentId" url:"documentId" schema:"documentId"`
+	Extensions    map[string]any `json:"extensions" url:"extensions" schema:"extensions"`
+}
+
+// RequestError is a malformed or unsupported request along with the HTTP
+// status code it is reported with
+type RequestError = graphqlhttp.RequestError
+
+func newRequestError(statusCode int, format string, a ...any) *RequestError {
+	return graphqlhttp.NewRequestError(statusCode, format, a...)
 }
 
-func getFromForm(values url.Values) *RequestOptions {
+func getFromForm(values url.Values) (*RequestOptions, error) {
 	query := values.Get("query")
-	if query != "" {
+	documentID := values.Get("documentId")
+	extensionsStr := values.Get("extensions")
+	if query != "" || documentID != "" || extensionsStr != "" {
 		// get variables map
 		variables := make(map[string]any, len(values))
-		variablesStr := values.Get("variables")
-		json.Unmarshal([]byte(variablesStr), &variables)
+		if variablesStr := values.Get("variables"); variablesStr != "" {
+			if err := json.Unmarshal([]byte(variablesStr), &variables); err != nil {
+				return nil, newRequestError(http.StatusBadRequest, "variables must be a JSON object: %v", err)
+			}
+		}
+
+		var extensions map[string]any
+		if extensionsStr != "" {
+			if err := json.Unmarshal([]byte(extensionsStr), &extensions); err != nil {
+				return nil, newRequestError(http.StatusBadRequest, "extensions must be a JSON object: %v", err)
+			}
+		}
 
 		return &RequestOptions{
 			Query:         query,
 			Variables:     variables,
 			OperationName: values.Get("operationName"),
-		}
+			DocumentID:    documentID,
+			Extensions:    extensions,
+		}, nil
 	}
 
-	return nil
+	return nil, nil
+}
+
+// reports a body read error, keeping the status of a RequestError
+func bodyError(message string, err error) error {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr
+	}
+	return newRequestError(http.StatusBadRequest, "%s: %v", message, err)
 }
 
 // NewRequestOptions Parses a http.Request into GraphQL request options struct
 func NewRequestOptions(r *http.Request) *RequestOptions {
-	if reqOpt := getFromForm(r.URL.Query()); reqOpt != nil {
-		return reqOpt
+	opts, err := ParseRequestOptions(r)
+	if err != nil {
+		return &RequestOptions{}
+	}
+	return opts
+}
+
+// ParseRequestOptions Parses a http.Request into GraphQL request options struct
+// and reports a malformed request as a *RequestError
+func ParseRequestOptions(r *http.Request) (*RequestOptions, error) {
+	if reqOpt, err := getFromForm(r.URL.Query()); reqOpt != nil || err != nil {
+		return reqOpt, err
 	}
 
 	if r.Method != http.MethodPost {
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 	}
 
 	if r.Body == nil {
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 	}
 
-	// TODO: improve Content-Type handling
-	contentTypeStr := r.Header.Get("Content-Type")
-	contentTypeTokens := strings.Split(contentTypeStr, ";")
-	contentType := contentTypeTokens[0]
-
-	switch contentType {
+	switch graphqlhttp.GetMediaType(r.Header.Get("Content-Type")) {
 	case ContentTypeGraphQL:
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			return &RequestOptions{}
+			return nil, bodyError("failed to read request body", err)
 		}
 		return &RequestOptions{
 			Query: string(body),
-		}
+		}, nil
 	case ContentTypeFormURLEncoded:
 		if err := r.ParseForm(); err != nil {
-			return &RequestOptions{}
+			return nil, bodyError("invalid form body", err)
 		}
 
-		if reqOpt := getFromForm(r.PostForm); reqOpt != nil {
-			return reqOpt
+		if reqOpt, err := getFromForm(r.PostForm); reqOpt != nil || err != nil {
+			return reqOpt, err
 		}
 
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 
 	case ContentTypeJSON:
 		fallthrough
@@ -110,84 +166,216 @@ func NewRequestOptions(r *http.Request) *RequestOptions {
 		var opts RequestOptions
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			return &opts
+			return nil, bodyError("failed to read request body", err)
 		}
-		err = json.Unmarshal(body, &opts)
-		if err != nil {
+		if len(bytes.TrimSpace(body)) == 0 {
+			return &opts, nil
+		}
+		if err := json.Unmarshal(body, &opts); err != nil {
 			// Probably `variables` was sent as a string instead of an object.
 			// So, we try to be polite and try to parse that as a JSON string
 			var optsCompatible requestOptionsCompatibility
-			json.Unmarshal(body, &optsCompatible)
-			json.Unmarshal([]byte(optsCompatible.Variables), &opts.Variables)
+			if json.Unmarshal(body, &optsCompatible) != nil {
+				return nil, newRequestError(http.StatusBadRequest, "invalid JSON body: %v", err)
+			}
+			opts = RequestOptions{
+				Query:         optsCompatible.Query,
+				OperationName: optsCompatible.OperationName,
+				DocumentID:    optsCompatible.DocumentID,
+				Extensions:    optsCompatible.Extensions,
+			}
+			if optsCompatible.Variables != "" {
+				if err := json.Unmarshal([]byte(optsCompatible.Variables), &opts.Variables); err != nil {
+					return nil, newRequestError(http.StatusBadRequest, "variables must be a JSON object: %v", err)
+				}
+			}
 		}
-		return &opts
+		return &opts, nil
 	}
 }
 
 // ContextHandler provides an entrypoint into executing graphQL queries with a
 // user-provided context.
 func (h *Handler) ContextHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	// requests for the graphiql or playground UI are exempt from the spec checks
+	renderUI := (h.graphiqlConfig != nil || h.playgroundConfig != nil) && graphqlhttp.AcceptsHTML(r)
+	specCompliant := h.specCompliant && !renderUI
+	if specCompliant {
+		if err := graphqlhttp.CheckSpecMethod(r); err != nil {
+			h.writeRequestError(w, r, ContentTypeJSON, err)
+			return
+		}
+	}
+
 	// get query
-	opts := NewRequestOptions(r)
+	opts, err := graphqlhttp.Parse(h.limits, w, r, ParseRequestOptions)
+	if err == nil {
+		err = h.limits.Check(opts.Query, opts.Variables)
+	}
+	if err != nil {
+		h.writeRequestError(w, r, ContentTypeJSON, err)
+		return
+	}
+
+	// apply the execution deadline, resolvers observe it through the context
+	ctx, cancel := h.limits.WithDeadline(ctx)
+	defer cancel()
+
+	// collect the cache policy of the response
+	var policy *cachecontrol.Policy
+	if h.cacheControl != nil {
+		ctx, policy = cachecontrol.WithPolicy(ctx)
+	}
+
+	// reject mutations requested with GET once the document is known
+	var check *graphqlhttp.MethodCheck
+	if specCompliant {
+		ctx, check = graphqlhttp.WithMethodCheck(ctx)
+	}
 
 	// execute graphql query
-	params := graphql.Params{
-		Schema:         *h.Schema,
-		RequestString:  opts.Query,
-		VariableValues: opts.Variables,
-		OperationName:  opts.OperationName,
-		Context:        ctx,
+	req := &executor.Request{
+		Query:         opts.Query,
+		Variables:     opts.Variables,
+		OperationName: opts.OperationName,
+		DocumentID:    trusted.DocumentID(opts.DocumentID, opts.Extensions),
+		Extensions:    opts.Extensions,
+		Context:       ctx,
+		Transport:     executor.TransportHTTP,
+		HTTPRequest:   r,
 	}
 	if h.rootObjectFn != nil {
-		params.RootObject = h.rootObjectFn(ctx, r)
+		req.RootObject = h.rootObjectFn(ctx, r)
 	}
-	result := graphql.Do(params)
+	params := req.Params(*h.Schema)
+	exec := h.executor()
 
-	if formatErrorFn := h.formatErrorFn; formatErrorFn != nil && len(result.Errors) > 0 {
-		formatted := make([]gqlerrors.FormattedError, len(result.Errors))
-		for i, formattedError := range result.Errors {
-			formatted[i] = formatErrorFn(formattedError.OriginalError())
+	mediaType := ContentTypeJSON
+	if specCompliant {
+		if mediaType, err = graphqlhttp.CheckSpecRequest(r, req.Query, req.DocumentID); err != nil {
+			h.writeRequestError(w, r, mediaType, err)
+			return
 		}
-		result.Errors = formatted
 	}
 
-	if h.graphiqlConfig != nil {
-		acceptHeader := r.Header.Get("Accept")
-		_, raw := r.URL.Query()["raw"]
-		if !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
-			renderGraphiQL(h.graphiqlConfig, w, r, params)
-			return
-		}
+	// responses of queries are stored for the cachecontrol plugin, which
+	// serves them once the other plugins accepted the request
+	cached := !renderUI && h.cacheControl != nil
+
+	// stream @defer and @stream results to clients that accept them
+	if incremental.Accepts(r) {
+		h.incrementalHandler(w, exec, req, check, mediaType)
+		return
 	}
 
-	if h.playgroundConfig != nil && h.graphiqlConfig == nil {
-		acceptHeader := r.Header.Get("Accept")
-		_, raw := r.URL.Query()["raw"]
-		if !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
+	result := exec.Execute(req)
+
+	if err := check.Err(); err != nil {
+		h.writeRequestError(w, r, mediaType, err)
+		return
+	}
+
+	if renderUI {
+		if h.graphiqlConfig != nil {
+			renderGraphiQL(h.graphiqlConfig, w, r, params, result)
+		} else {
 			renderPlayground(h.playgroundConfig, w, r)
-			return
 		}
+		return
+	}
+
+	statusCode := graphqlhttp.ResultStatus(mediaType, req.Executed || req.Cached)
+	if timedOut, timeoutStatus := h.limits.CheckDeadline(ctx, result); timeoutStatus != 0 {
+		result, statusCode = timedOut, timeoutStatus
+	}
+
+	if header := policy.Header(); header != "" {
+		w.Header().Set("Cache-Control", header)
+	}
+
+	buff := h.writeResult(w, mediaType, statusCode, result)
+	if cached && statusCode == http.StatusOK && !req.Cached {
+		h.cacheControl.Store(req, policy, buff)
+	}
+
+	if h.resultCallbackFn != nil {
+		h.resultCallbackFn(ctx, &params, result, buff)
 	}
+}
 
+// writes a result with the response media type and returns the response body
+func (h *Handler) writeResult(w http.ResponseWriter, mediaType string, statusCode int, result any) []byte {
 	// use proper JSON Header
-	w.Header().Add("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
 
 	var buff []byte
 	if h.pretty {
-		w.WriteHeader(http.StatusOK)
 		buff, _ = json.MarshalIndent(result, "", "\t")
-
-		w.Write(buff)
 	} else {
-		w.WriteHeader(http.StatusOK)
 		buff, _ = json.Marshal(result)
+	}
+
+	w.WriteHeader(statusCode)
+	w.Write(buff)
+	return buff
+}
 
-		w.Write(buff)
+// writes a malformed or unsupported request error as a GraphQL response
+func (h *Handler) writeRequestError(w http.ResponseWriter, r *http.Request, mediaType string, err error) {
+	statusCode := http.StatusBadRequest
+	if reqErr, ok := err.(*RequestError); ok {
+		statusCode = reqErr.StatusCode
 	}
 
-	if h.resultCallbackFn != nil {
-		h.resultCallbackFn(ctx, &params, result, buff)
+	if statusCode == http.StatusMethodNotAllowed {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Allow", http.MethodPost)
+		} else {
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		}
+	}
+
+	h.writeResult(w, mediaType, statusCode, map[string]any{
+		"errors": gqlerrors.FormatErrors(err),
+	})
+}
+
+// writes the incremental payloads of an operation as a multipart/mixed response
+func (h *Handler) incrementalHandler(w http.ResponseWriter, exec *executor.Executor, req *executor.Request, check *graphqlhttp.MethodCheck, mediaType string) {
+	mw := incremental.NewMultipartWriter(w, h.pretty)
+
+	var err error
+	for payload := range exec.Incremental(req) {
+		// a rejected request is reported before any payload is written
+		if rejected := check.Err(); rejected != nil && err == nil {
+			h.writeRequestError(w, req.HTTPRequest, mediaType, rejected)
+			err = rejected
+		}
+
+		// keep draining the payloads after a failed write so the executor can finish
+		if err == nil {
+			err = mw.WritePayload(payload)
+		}
 	}
+
+	if err == nil {
+		mw.Close()
+	}
+}
+
+// creates the executor for the current schema
+func (h *Handler) executor() *executor.Executor {
+	return executor.New(&executor.Config{
+		Schema:        h.Schema,
+		Plugins:       h.plugins,
+		FormatErrorFn: h.formatErrorFn,
+		Cache:         h.cache,
+	})
+}
+
+// DocumentCache returns the document cache, or nil when it is disabled
+func (h *Handler) DocumentCache() *executor.DocumentCache {
+	return h.cache
 }
 
 // ServeHTTP provides an entrypoint into executing graphQL queries.
@@ -207,6 +395,45 @@ type Config struct {
 	RootObjectFn     RootObjectFn
 	ResultCallbackFn ResultCallbackFn
 	FormatErrorFn    func(err error) gqlerrors.FormattedError
+
+	// Plugins hook into the execution of every operation
+	Plugins []executor.Plugin
+
+	// DocumentCacheSize caches up to this many parsed and validated
+	// documents, zero disables the cache
+	DocumentCacheSize int
+
+	// CacheControl sets the Cache-Control header of responses from the
+	// @cacheControl hints of the resolved fields, and caches whole responses
+	// when it has a store
+	CacheControl *cachecontrol.CacheControl
+
+	// TrustedDocuments only runs registered documents, sent by ID or as
+	// their exact text, or logs the other operations in log only mode
+	TrustedDocuments *trusted.TrustedDocuments
+
+	// SpecCompliant follows the GraphQL over HTTP specification for media
+	// types, status codes and GET requests
+	SpecCompliant bool
+
+	// MaxBodyBytes limits the size of the request body
+	MaxBodyBytes int64
+
+	// MaxQueryLength limits the number of characters in the query
+	MaxQueryLength int
+
+	// MaxVariablesBytes limits the JSON encoded size of the variables
+	MaxVariablesBytes int
+
+	// MaxTokens limits the number of tokens the query is lexed into before it
+	// is parsed
+	MaxTokens int
+
+	// ParseTimeout limits the time spent receiving and parsing the request
+	ParseTimeout time.Duration
+
+	// ExecutionTimeout sets a deadline on the context of each operation
+	ExecutionTimeout time.Duration
 }
 
 // NewConfig returns a new default config
@@ -229,6 +456,25 @@ func New(p *Config) *Handler {
 		panic("undefined GraphQL schema")
 	}
 
+	var cache *executor.DocumentCache
+	if p.DocumentCacheSize > 0 {
+		cache = executor.NewDocumentCache(p.DocumentCacheSize)
+	}
+
+	plugins := p.Plugins
+	if p.CacheControl != nil {
+		plugins = append(plugins[:len(plugins):len(plugins)], p.CacheControl.Plugin())
+	}
+	if p.SpecCompliant {
+		// checks mutations requested with GET once documents sent by ID
+		// are resolved
+		plugins = append([]executor.Plugin{graphqlhttp.MethodPlugin()}, plugins...)
+	}
+	if p.TrustedDocuments != nil {
+		// documents sent by ID are replaced before other plugins see them
+		plugins = append([]executor.Plugin{p.TrustedDocuments.Plugin()}, plugins...)
+	}
+
 	return &Handler{
 		Schema:           p.Schema,
 		pretty:           p.Pretty,
@@ -237,5 +483,17 @@ func New(p *Config) *Handler {
 		rootObjectFn:     p.RootObjectFn,
 		resultCallbackFn: p.ResultCallbackFn,
 		formatErrorFn:    p.FormatErrorFn,
+		specCompliant:    p.SpecCompliant,
+		plugins:          plugins,
+		cache:            cache,
+		cacheControl:     p.CacheControl,
+		limits: graphqlhttp.Limits{
+			MaxBodyBytes:      p.MaxBodyBytes,
+			MaxQueryLength:    p.MaxQueryLength,
+			MaxVariablesBytes: p.MaxVariablesBytes,
+			MaxTokens:         p.MaxTokens,
+			ParseTimeout:      p.ParseTimeout,
+			ExecutionTimeout:  p.ExecutionTimeout,
+		},
 	}
 }
@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql-go-tools/cachecontrol"
+	"github.com/dagger/graphql-go-tools/internal/graphqlhttp"
+	"github.com/dagger/graphql-go-tools/trusted"
+)
+
+func testHandler(t *testing.T, config *Config) *Handler {
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type Query {
+	hello: String
+	slow: String
+	required: String!
+}
+
+type Mutation {
+	setHello(value: String): String
+}`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return "world", nil
+						},
+					},
+					"required": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return nil, errors.New("required failed")
+						},
+					},
+					"slow": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							select {
+							case <-p.Context.Done():
+								return nil, p.Context.Err()
+							case <-time.After(time.Second):
+								return "done", nil
+							}
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+
+	config.Schema = &schema
+	return New(config)
+}
+
+func TestSpecCompliant(t *testing.T) {
+	h := testHandler(t, &Config{SpecCompliant: true})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		accept      string
+		body        string
+		status      int
+		mediaType   string
+	}{
+		{"post json", http.MethodPost, "/", ContentTypeJSON, "", `{"query":"{ hello }"}`, http.StatusOK, ContentTypeJSON},
+		{"graphql response", http.MethodPost, "/", ContentTypeJSON, ContentTypeGraphQLResponse, `{"query":"{ hello }"}`, http.StatusOK, ContentTypeGraphQLResponse},
+		{"validation error", http.MethodPost, "/", ContentTypeJSON, ContentTypeGraphQLResponse, `{"query":"{ nope }"}`, http.StatusBadRequest, ContentTypeGraphQLResponse},
+		{"field error", http.MethodPost, "/", ContentTypeJSON, ContentTypeGraphQLResponse, `{"query":"{ required }"}`, http.StatusOK, ContentTypeGraphQLResponse},
+		{"validation error json", http.MethodPost, "/", ContentTypeJSON, ContentTypeJSON, `{"query":"{ nope }"}`, http.StatusOK, ContentTypeJSON},
+		{"malformed body", http.MethodPost, "/", ContentTypeJSON, "", `{"query":`, http.StatusBadRequest, ContentTypeJSON},
+		{"missing query", http.MethodPost, "/", ContentTypeJSON, "", `{}`, http.StatusBadRequest, ContentTypeJSON},
+		{"unsupported media type", http.MethodPost, "/", "text/plain", "", `{ hello }`, http.StatusUnsupportedMediaType, ContentTypeJSON},
+		{"not acceptable", http.MethodPost, "/", ContentTypeJSON, "application/xml", `{"query":"{ hello }"}`, http.StatusNotAcceptable, ContentTypeJSON},
+		{"method not allowed", http.MethodPut, "/", ContentTypeJSON, "", `{"query":"{ hello }"}`, http.StatusMethodNotAllowed, ContentTypeJSON},
+		{"get query", http.MethodGet, "/?query=%7B+hello+%7D", "", "", "", http.StatusOK, ContentTypeJSON},
+		{"get mutation", http.MethodGet, "/?query=mutation+%7B+setHello+%7D", "", "", "", http.StatusMethodNotAllowed, ContentTypeJSON},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d: %s", test.name, test.status, w.Code, w.Body.String())
+		}
+		if mediaType := graphqlhttp.GetMediaType(w.Header().Get("Content-Type")); mediaType != test.mediaType {
+			t.Errorf("%s: expected media type %q, got %q", test.name, test.mediaType, mediaType)
+		}
+	}
+}
+
+func TestMalformedBody(t *testing.T) {
+	h := testHandler(t, &Config{})
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"query":`))
+	r.Header.Set("Content-Type", ContentTypeJSON)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid JSON body") {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+		return
+	}
+}
+
+func TestLimits(t *testing.T) {
+	h := testHandler(t, &Config{
+		MaxBodyBytes:      64,
+		MaxQueryLength:    32,
+		MaxVariablesBytes: 16,
+		MaxTokens:         5,
+		ExecutionTimeout:  10 * time.Millisecond,
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		error  string
+	}{
+		{"body", `{"query":"{ hello }","variables":{"a":"` + strings.Repeat("a", 64) + `"}}`, http.StatusRequestEntityTooLarge, "request body exceeds"},
+		{"query", `{"query":"{ hello hello hello hello hello hello }"}`, http.StatusRequestEntityTooLarge, "query exceeds the maximum length"},
+		{"variables", `{"query":"{ hello }","variables":{"a":"aaaaaaaaaaaaa"}}`, http.StatusRequestEntityTooLarge, "variables exceed"},
+		{"tokens", `{"query":"{ hello hello hello hello }"}`, http.StatusBadRequest, "maximum of 5 tokens"},
+		{"timeout", `{"query":"{ slow }"}`, http.StatusServiceUnavailable, "execution timeout"},
+		{"ok", `{"query":"{ hello }"}`, http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", ContentTypeJSON)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status || !strings.Contains(w.Body.String(), test.error) {
+			t.Errorf("%s: unexpected response %d: %s", test.name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	cc := cachecontrol.New(cachecontrol.Config{Store: cachecontrol.NewMemoryStore()})
+	calls := 0
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: []string{cachecontrol.TypeDefs, `
+type Query {
+	hello: String @cacheControl(maxAge: 60)
+	uncached: String
+}`},
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							calls++
+							return "world", nil
+						},
+					},
+					"uncached": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							calls++
+							return "world", nil
+						},
+					},
+				},
+			},
+		},
+		SchemaDirectives: tools.SchemaDirectiveVisitorMap{
+			"cacheControl": cc.Directive(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+	h := New(&Config{Schema: &schema, CacheControl: cc})
+
+	tests := []struct {
+		query  string
+		header string
+		calls  int
+	}{
+		{"{ hello }", "max-age=60, public", 1},
+		{"{ hello }", "max-age=60, public", 1},
+		{"{ uncached }", "no-store", 2},
+		{"{ uncached }", "no-store", 3},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/?query="+url.QueryEscape(test.query), nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != test.header || calls != test.calls {
+			t.Errorf("%s: unexpected response %d %q after %d calls: %s", test.query, w.Code, w.Header().Get("Cache-Control"), calls, w.Body.String())
+			return
+		}
+	}
+}
+
+func TestTrustedDocuments(t *testing.T) {
+	cc := cachecontrol.New(cachecontrol.Config{Store: cachecontrol.NewMemoryStore()})
+	calls := 0
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: []string{cachecontrol.TypeDefs, `
+type Query {
+	hello: String @cacheControl(maxAge: 60)
+	secret: String
+}`},
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							calls++
+							return "world", nil
+						},
+					},
+				},
+			},
+		},
+		SchemaDirectives: tools.SchemaDirectiveVisitorMap{
+			"cacheControl": cc.Directive(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+
+	store := trusted.NewStore()
+	store.Add("hello", "query Hello { hello }")
+	h := New(&Config{
+		Schema:           &schema,
+		CacheControl:     cc,
+		TrustedDocuments: trusted.New(trusted.Config{Store: store}),
+		SpecCompliant:    true,
+	})
+
+	tests := []struct {
+		method   string
+		target   string
+		body     string
+		expected string
+		calls    int
+	}{
+		{http.MethodGet, "/?documentId=hello", "", `{"data":{"hello":"world"}}`, 1},
+		{http.MethodGet, "/?extensions=" + url.QueryEscape(`{"persistedQuery":{"version":1,"sha256Hash":"hello"}}`), "", `{"data":{"hello":"world"}}`, 1},
+		{http.MethodPost, "/", `{"documentId":"hello"}`, `{"data":{"hello":"world"}}`, 2},
+		{http.MethodPost, "/", `{"query":"{ secret }"}`, `"code":"UNTRUSTED_DOCUMENT"`, 2},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		r.Header.Set("Content-Type", ContentTypeJSON)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if !strings.Contains(w.Body.String(), test.expected) || calls != test.calls {
+			t.Errorf("%s %s %s: unexpected response %d after %d calls: %s", test.method, test.target, test.body, w.Code, calls, w.Body.String())
+			return
+		}
+	}
+}
+
+func TestTrustedMutationGet(t *testing.T) {
+	calls := 0
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type Query {
+	hello: String
+}
+
+type Mutation {
+	setHello(value: String): String
+}`,
+		Resolvers: map[string]any{
+			"Mutation": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"setHello": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							calls++
+							return p.Args["value"], nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+
+	store := trusted.NewStore()
+	store.Add("setHello", `mutation SetHello { setHello(value: "world") }`)
+	h := New(&Config{
+		Schema:           &schema,
+		TrustedDocuments: trusted.New(trusted.Config{Store: store}),
+		SpecCompliant:    true,
+	})
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		status int
+		calls  int
+	}{
+		{http.MethodGet, "/?documentId=setHello", "", http.StatusMethodNotAllowed, 0},
+		{http.MethodGet, "/?extensions=" + url.QueryEscape(`{"persistedQuery":{"version":1,"sha256Hash":"setHello"}}`), "", http.StatusMethodNotAllowed, 0},
+		{http.MethodPost, "/", `{"documentId":"setHello"}`, http.StatusOK, 1},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		r.Header.Set("Content-Type", ContentTypeJSON)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status || calls != test.calls {
+			t.Errorf("%s %s %s: unexpected response %d after %d calls: %s", test.method, test.target, test.body, w.Code, calls, w.Body.String())
+			return
+		}
+		if test.status == http.StatusMethodNotAllowed && w.Header().Get("Allow") != http.MethodPost {
+			t.Errorf("%s %s: expected Allow POST, got %q", test.method, test.target, w.Header().Get("Allow"))
+			return
+		}
+	}
+}
@@ -177,7 +177,7 @@ func ReadSourceFiles(p string, recursive ...bool) (string, error) {
 			if err != nil {
 				return "", err
 			}
-			if err := readFunc(abs, info, nil); err != nil {
+			if err := readFunc(filepath.Join(abs, file.Name()), info, nil); err != nil {
 				return "", err
 			}
 		}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"query.graphql":       "type Query { hello: String }",
+		"user.gql":            "type User { name: String }",
+		"notes.txt":           "type Ignored { name: String }",
+		"nested/post.graphql": "type Post { title: String }",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	tests := []struct {
+		name      string
+		recursive bool
+		expected  []string
+		excluded  []string
+	}{
+		{"non-recursive", false, []string{"Query", "User"}, []string{"Ignored", "Post"}},
+		{"recursive", true, []string{"Query", "User", "Post"}, []string{"Ignored"}},
+	}
+
+	for _, test := range tests {
+		typeDefs, err := ReadSourceFiles(dir, test.recursive)
+		if err != nil {
+			t.Errorf("%s: failed to read source files: %v", test.name, err)
+			return
+		}
+		for _, name := range test.expected {
+			if !strings.Contains(typeDefs, "type "+name+" ") {
+				t.Errorf("%s: expected type %s in %q", test.name, name, typeDefs)
+				return
+			}
+		}
+		for _, name := range test.excluded {
+			if strings.Contains(typeDefs, "type "+name+" ") {
+				t.Errorf("%s: unexpected type %s in %q", test.name, name, typeDefs)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package incremental implements incremental delivery of @defer and @stream
+// results following the GraphQL incremental delivery specification
+// https://github.com/graphql/graphql-wg/blob/main/rfcs/DeferStream.md
+package incremental
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/kinds"
+	"github.com/dagger/graphql/language/parser"
+	"github.com/dagger/graphql/language/source"
+)
+
+// Payload is a single part of an incremental response. The first payload
+// carries the initial response, every following payload is a patch that
+// identifies its location in the initial response with Path
+type Payload struct {
+	Data       any                        `json:"data,omitempty"`
+	Items      []any                      `json:"items,omitempty"`
+	Errors     []gqlerrors.FormattedError `json:"errors,omitempty"`
+	Path       []any                      `json:"path,omitempty"`
+	Label      string                     `json:"label,omitempty"`
+	Extensions map[string]any             `json:"extensions,omitempty"`
+	HasNext    bool                       `json:"hasNext"`
+}
+
+// Do executes the operation and delivers the result over the returned channel.
+// Operations without an active @defer or @stream produce a single payload with
+// the fully resolved result. Otherwise the initial payload omits deferred
+// fragments and streamed items and is delivered as soon as it is resolved.
+// Patches follow as the deferred fragments resolve, the operation is executed
+// once. Streamed lists are resolved with the initial payload and their items
+// past the initial count are delivered in a single patch. The channel is
+// closed after the last payload or when the params context is done.
+//
+// Fragments deferred below the root need the extension created by
+// NewExtension in the schema. Do does not reuse the schemas deferred
+// fragments are executed on, use an Executor to execute many operations
+func Do(p graphql.Params) <-chan *Payload {
+	return NewExecutor(&p.Schema).Do(p)
+}
+
+// Execute is like Do for a document that has already been parsed and validated
+func Execute(p graphql.ExecuteParams) <-chan *Payload {
+	return NewExecutor(&p.Schema).Execute(p)
+}
+
+// Executor executes the incremental operations of a schema. It caches the
+// schemas that deferred fragments are executed on, which use the object the
+// fragment is deferred on as their query type
+type Executor struct {
+	schema *graphql.Schema
+	mu     sync.Mutex
+	roots  map[string]*graphql.Schema
+}
+
+// NewExecutor creates an executor for the schema
+func NewExecutor(schema *graphql.Schema) *Executor {
+	return &Executor{
+		schema: schema,
+		roots:  map[string]*graphql.Schema{},
+	}
+}
+
+// Do is like the package level Do, the params must use the schema of the
+// executor
+func (e *Executor) Do(p graphql.Params) <-chan *Payload {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{
+			Body: []byte(p.RequestString),
+			Name: "GraphQL request",
+		}),
+	})
+	if err != nil {
+		return deliver(p.Context, func(send func(*Payload) bool) {
+			send(&Payload{Errors: gqlerrors.FormatErrors(err)})
+		})
+	}
+
+	if result := graphql.ValidateDocument(&p.Schema, doc, nil); !result.IsValid {
+		return deliver(p.Context, func(send func(*Payload) bool) {
+			send(&Payload{Errors: result.Errors})
+		})
+	}
+
+	return e.Execute(graphql.ExecuteParams{
+		Schema:        p.Schema,
+		Root:          p.RootObject,
+		AST:           doc,
+		OperationName: p.OperationName,
+		Args:          p.VariableValues,
+		Context:       p.Context,
+	})
+}
+
+// Execute is like the package level Execute, the params must use the schema
+// of the executor
+func (e *Executor) Execute(p graphql.ExecuteParams) <-chan *Payload {
+	return deliver(p.Context, func(send func(*Payload) bool) {
+		e.execute(p, send)
+	})
+}
+
+// gets a schema with the same types as the executor schema that has the
+// object as its query type. graphql extensions of the schema are not carried
+// over
+func (e *Executor) rootSchema(object *graphql.Object) (*graphql.Schema, error) {
+	if object == e.schema.QueryType() {
+		return e.schema, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s, ok := e.roots[object.Name()]; ok {
+		return s, nil
+	}
+
+	types := []graphql.Type{}
+	for name, t := range e.schema.TypeMap() {
+		if !strings.HasPrefix(name, "__") {
+			types = append(types, t)
+		}
+	}
+	s, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:      object,
+		Types:      types,
+		Directives: e.schema.Directives(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.roots[object.Name()] = &s
+	return &s, nil
+}
+
+// delivers the payloads sent by fn over a channel until ctx is done, send
+// returns false once ctx is done
+func deliver(ctx context.Context, fn func(send func(*Payload) bool)) <-chan *Payload {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ch := make(chan *Payload)
+	go func() {
+		defer close(ch)
+		fn(func(payload *Payload) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case ch <- payload:
+				return true
+			}
+		})
+	}()
+
+	return ch
+}
+
+// executes the operation and sends the payloads as they are resolved. the
+// initial document is executed once while recording the sources of the
+// deferred fragments, which are then executed concurrently on them
+func (e *Executor) execute(p graphql.ExecuteParams, send func(*Payload) bool) {
+	pl := newPlanner(p.AST, p.Args)
+	initialDoc := pl.plan(p.OperationName)
+	if initialDoc == nil || (len(pl.deferred) == 0 && len(pl.streams) == 0) {
+		send(resultPayload(graphql.Execute(p), false))
+		return
+	}
+
+	sources := newSources(pl.deferred)
+	initialParams := p
+	initialParams.AST = initialDoc
+	initialParams.Context = sources.withContext(p.Context)
+	initial := graphql.Execute(initialParams)
+
+	objects := pl.deferredObjects(&p.Schema, initial.Data)
+	removeTypenameAlias(initial.Data)
+
+	// the channel is buffered so that deferred fragments can finish after the
+	// client is gone
+	deferred := make(chan *Payload, len(objects))
+	for _, o := range objects {
+		go func(o deferredObject) {
+			deferred <- e.executeDeferred(p, pl, sources, o)
+		}(o)
+	}
+
+	patches := pl.streamPatches(initial)
+	pending := len(patches) + len(objects)
+	if !send(resultPayload(initial, pending > 0)) {
+		return
+	}
+
+	for _, patch := range patches {
+		pending--
+		patch.HasNext = pending > 0
+		if !send(patch) {
+			return
+		}
+	}
+
+	for range objects {
+		patch := <-deferred
+		pending--
+		patch.HasNext = pending > 0
+		if !send(patch) {
+			return
+		}
+	}
+}
+
+// executes a deferred fragment on the object it is deferred on
+func (e *Executor) executeDeferred(p graphql.ExecuteParams, pl *planner, sources *sources, o deferredObject) *Payload {
+	patch := &Payload{
+		Path:  o.path,
+		Label: o.fragment.label,
+	}
+
+	object, ok := p.Schema.Type(o.typename).(*graphql.Object)
+	if !ok {
+		patch.Errors = gqlerrors.FormatErrors(fmt.Errorf("unknown object type %q", o.typename))
+		return patch
+	}
+
+	root := p.Root
+	if len(o.path) > 0 {
+		source, err := sources.lookup(o.path)
+		if err != nil {
+			patch.Errors = gqlerrors.FormatErrors(err)
+			return patch
+		}
+		root = source
+	}
+
+	schema, err := e.rootSchema(object)
+	if err != nil {
+		patch.Errors = gqlerrors.FormatErrors(err)
+		return patch
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:  *schema,
+		Root:    root,
+		AST:     pl.fragmentDocument(o.fragment),
+		Args:    p.Args,
+		Context: p.Context,
+	})
+	patch.Data = result.Data
+	patch.Errors = prefixErrors(result.Errors, o.path)
+	patch.Extensions = result.Extensions
+	return patch
+}
+
+// converts an execution result to a payload
+func resultPayload(result *graphql.Result, hasNext bool) *Payload {
+	return &Payload{
+		Data:       result.Data,
+		Errors:     result.Errors,
+		Extensions: result.Extensions,
+		HasNext:    hasNext,
+	}
+}
+
+// finds the operation that will be executed
+func getOperation(doc *ast.Document, operationName string) *ast.OperationDefinition {
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		if def.GetKind() != kinds.OperationDefinition {
+			continue
+		}
+		op := def.(*ast.OperationDefinition)
+		if operationName == "" {
+			if operation != nil {
+				return nil
+			}
+			operation = op
+		} else if op.Name != nil && op.Name.Value == operationName {
+			return op
+		}
+	}
+	return operation
+}
@@ -0,0 +1,376 @@
+package incremental
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+func testSchema(t *testing.T) graphql.Schema {
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		Extensions: []graphql.Extension{NewExtension()},
+		TypeDefs: `
+interface Named {
+	name: String
+}
+
+type User implements Named {
+	name: String
+	email: String
+}
+
+type Query {
+	user: User
+	named: Named
+	numbers: [Int]
+}`,
+		Resolvers: map[string]any{
+			"Named": &tools.InterfaceResolver{
+				ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+					return p.Info.Schema.Type("User").(*graphql.Object)
+				},
+			},
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"user": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return map[string]any{"name": "foo", "email": "foo@bar.com"}, nil
+						},
+					},
+					"named": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return map[string]any{"name": "bar", "email": "bar@baz.com"}, nil
+						},
+					},
+					"numbers": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return []int{1, 2, 3, 4}, nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+	return schema
+}
+
+func collect(p graphql.Params) []string {
+	payloads := []string{}
+	for payload := range Do(p) {
+		j, _ := json.Marshal(payload)
+		payloads = append(payloads, string(j))
+	}
+	return payloads
+}
+
+func TestDefer(t *testing.T) {
+	payloads := collect(graphql.Params{
+		Schema: testSchema(t),
+		RequestString: `query {
+			user {
+				name
+				... @defer(label: "details") {
+					email
+				}
+			}
+			named {
+				name
+				...UserFragment @defer
+			}
+		}
+
+		fragment UserFragment on User {
+			email
+		}`,
+	})
+
+	// deferred fragments are delivered in the order they resolve
+	expected := []string{
+		`{"data":{"named":{"name":"bar"},"user":{"name":"foo"}},"hasNext":true}`,
+		`{"data":{"email":"bar@baz.com"},"path":["named"],"hasNext":false}`,
+		`{"data":{"email":"foo@bar.com"},"path":["user"],"label":"details","hasNext":false}`,
+	}
+
+	if len(payloads) != len(expected) || !strings.Contains(payloads[1], `"hasNext":true`) {
+		t.Errorf("unexpected payloads\n%s", strings.Join(payloads, "\n"))
+		return
+	}
+	payloads[1] = strings.Replace(payloads[1], `"hasNext":true`, `"hasNext":false`, 1)
+	sort.Strings(payloads[1:])
+
+	if strings.Join(payloads, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected payloads\n%s", strings.Join(payloads, "\n"))
+		return
+	}
+}
+
+func TestDeferExecutesOnce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		Extensions: []graphql.Extension{NewExtension()},
+		TypeDefs: `
+type User {
+	name: String
+	slow: String
+}
+
+type Query {
+	users: [User]
+}`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"users": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							atomic.AddInt32(&calls, 1)
+							return []map[string]any{{"name": "foo"}, {"name": "bar"}}, nil
+						},
+					},
+				},
+			},
+			"User": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"slow": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							<-release
+							return "slow " + p.Source.(map[string]any)["name"].(string), nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ch := Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ users { name ... on User @defer { slow } } }`,
+	})
+
+	// the initial payload does not wait for the deferred fragments
+	initial, _ := json.Marshal(<-ch)
+	if string(initial) != `{"data":{"users":[{"name":"foo"},{"name":"bar"}]},"hasNext":true}` {
+		t.Errorf("unexpected initial payload %s", initial)
+		return
+	}
+	close(release)
+
+	patches := []string{}
+	for payload := range ch {
+		payload.HasNext = false
+		j, _ := json.Marshal(payload)
+		patches = append(patches, string(j))
+	}
+	sort.Strings(patches)
+
+	expected := []string{
+		`{"data":{"slow":"slow foo"},"path":["users",0],"hasNext":false}`,
+		`{"data":{"slow":"slow bar"},"path":["users",1],"hasNext":false}`,
+	}
+	sort.Strings(expected)
+	if strings.Join(patches, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected patches\n%s", strings.Join(patches, "\n"))
+		return
+	}
+
+	if calls != 1 {
+		t.Errorf("expected users to be resolved once, got %d", calls)
+	}
+}
+
+func TestStream(t *testing.T) {
+	payloads := collect(graphql.Params{
+		Schema:         testSchema(t),
+		RequestString:  `query ($count: Int) { numbers @stream(initialCount: $count) }`,
+		VariableValues: map[string]any{"count": 1},
+	})
+
+	expected := []string{
+		`{"data":{"numbers":[1]},"hasNext":true}`,
+		`{"items":[2,3,4],"path":["numbers",1],"hasNext":false}`,
+	}
+
+	if strings.Join(payloads, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected payloads\n%s", strings.Join(payloads, "\n"))
+		return
+	}
+}
+
+// streamed lists are resolved with the initial payload, the items past the
+// initial count are then delivered in a single patch
+func TestStreamResolvesList(t *testing.T) {
+	release := make(chan struct{})
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type Item {
+	id: Int
+	slow: Int
+}
+
+type Query {
+	items: [Item]
+}`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"items": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return []map[string]any{{"id": 1}, {"id": 2}, {"id": 3}}, nil
+						},
+					},
+				},
+			},
+			"Item": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"slow": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							<-release
+							return p.Source.(map[string]any)["id"], nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ch := Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ items @stream(initialCount: 1) { id slow } }`,
+	})
+
+	// the initial payload waits for the streamed items
+	select {
+	case payload := <-ch:
+		t.Errorf("expected the initial payload to wait for the list, got %v", payload)
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	payloads := []string{}
+	for payload := range ch {
+		j, _ := json.Marshal(payload)
+		payloads = append(payloads, string(j))
+	}
+
+	expected := []string{
+		`{"data":{"items":[{"id":1,"slow":1}]},"hasNext":true}`,
+		`{"items":[{"id":2,"slow":2},{"id":3,"slow":3}],"path":["items",1],"hasNext":false}`,
+	}
+	if strings.Join(payloads, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected payloads\n%s", strings.Join(payloads, "\n"))
+		return
+	}
+}
+
+func TestDeferWithoutExtension(t *testing.T) {
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type User {
+	name: String
+	email: String
+}
+
+type Query {
+	user: User
+	version: String
+}`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"user": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return map[string]any{"name": "foo", "email": "foo@bar.com"}, nil
+						},
+					},
+					"version": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return "1", nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// fragments deferred on the root do not need the extension
+	payloads := collect(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ user { name } ... @defer { version } }`,
+	})
+	expected := []string{
+		`{"data":{"user":{"name":"foo"}},"hasNext":true}`,
+		`{"data":{"version":"1"},"hasNext":false}`,
+	}
+	if strings.Join(payloads, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected payloads\n%s", strings.Join(payloads, "\n"))
+		return
+	}
+
+	payloads = collect(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ user { name ... @defer { email } } }`,
+	})
+	if len(payloads) != 2 || !strings.Contains(payloads[1], "incremental.NewExtension") {
+		t.Errorf("expected the patch to report the missing extension\n%s", strings.Join(payloads, "\n"))
+		return
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	payloads := collect(graphql.Params{
+		Schema:        testSchema(t),
+		RequestString: `query { user { name ... @defer(if: false) { email } } }`,
+	})
+
+	expected := `{"data":{"user":{"email":"foo@bar.com","name":"foo"}},"hasNext":false}`
+	if len(payloads) != 1 || payloads[0] != expected {
+		t.Errorf("unexpected payloads\n%s", strings.Join(payloads, "\n"))
+		return
+	}
+}
+
+func TestMultipartWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	mw := NewMultipartWriter(w, false)
+	mw.WritePayload(&Payload{Data: map[string]any{"a": 1}, HasNext: true})
+	mw.WritePayload(&Payload{Data: map[string]any{"b": 2}, Path: []any{"a"}})
+	mw.Close()
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, ContentTypeMultipartMixed) {
+		t.Errorf("unexpected content type %q", ct)
+		return
+	}
+
+	expected := "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+		`{"data":{"a":1},"hasNext":true}` +
+		"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+		`{"data":{"b":2},"path":["a"],"hasNext":false}` +
+		"\r\n-----\r\n"
+	if w.Body.String() != expected {
+		t.Errorf("unexpected body %q", w.Body.String())
+		return
+	}
+}
@@ -0,0 +1,92 @@
+package incremental
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Constants
+const (
+	ContentTypeMultipartMixed = "multipart/mixed"
+
+	// the boundary recommended by the incremental delivery over HTTP specification
+	boundary = "-"
+)
+
+// Accepts determines if the request accepts an incremental multipart/mixed response
+func Accepts(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType := strings.TrimSpace(strings.Split(mediaRange, ";")[0])
+			if strings.EqualFold(mediaType, ContentTypeMultipartMixed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MultipartWriter writes payloads as parts of a multipart/mixed response,
+// flushing each part as soon as it has been written
+type MultipartWriter struct {
+	w       http.ResponseWriter
+	pretty  bool
+	started bool
+}
+
+// NewMultipartWriter creates a new multipart writer
+func NewMultipartWriter(w http.ResponseWriter, pretty bool) *MultipartWriter {
+	return &MultipartWriter{
+		w:      w,
+		pretty: pretty,
+	}
+}
+
+// WritePayload writes a payload as the next part of the response
+func (m *MultipartWriter) WritePayload(payload *Payload) error {
+	if !m.started {
+		m.started = true
+		m.w.Header().Set("Content-Type", ContentTypeMultipartMixed+`; boundary="`+boundary+`"; deferSpec=20220824`)
+		m.w.WriteHeader(http.StatusOK)
+	}
+
+	var buff []byte
+	var err error
+	if m.pretty {
+		buff, err = json.MarshalIndent(payload, "", "\t")
+	} else {
+		buff, err = json.Marshal(payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	part := "\r\n--" + boundary + "\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"
+	if _, err := m.w.Write(append([]byte(part), buff...)); err != nil {
+		return err
+	}
+
+	m.flush()
+	return nil
+}
+
+// Close writes the terminating boundary
+func (m *MultipartWriter) Close() error {
+	if !m.started {
+		return nil
+	}
+
+	if _, err := m.w.Write([]byte("\r\n--" + boundary + "--\r\n")); err != nil {
+		return err
+	}
+
+	m.flush()
+	return nil
+}
+
+func (m *MultipartWriter) flush() {
+	if flusher, ok := m.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,419 @@
+package incremental
+
+import (
+	"strings"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/kinds"
+)
+
+// alias used to identify the runtime type of objects that hold deferred
+// fragments. field names starting with __ are reserved so this cannot collide
+// with a user defined response key
+const typenameAlias = "__incrementalTypename"
+
+// a fragment marked with @defer
+type deferredFragment struct {
+	path          []string
+	label         string
+	typeCondition string
+	selectionSet  *ast.SelectionSet
+}
+
+// an object of the initial response a deferred fragment is resolved on
+type deferredObject struct {
+	fragment *deferredFragment
+	path     []any
+	typename string
+}
+
+// a list field marked with @stream
+type streamedField struct {
+	path         []string
+	label        string
+	initialCount int
+}
+
+// planner removes the deferred fragments from an operation and records where
+// each patch will be located
+type planner struct {
+	doc       *ast.Document
+	operation *ast.OperationDefinition
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	deferred  []*deferredFragment
+	streams   []*streamedField
+}
+
+func newPlanner(doc *ast.Document, variables map[string]any) *planner {
+	pl := &planner{
+		doc:       doc,
+		fragments: map[string]*ast.FragmentDefinition{},
+		variables: variables,
+	}
+
+	for _, def := range doc.Definitions {
+		if def.GetKind() == kinds.FragmentDefinition {
+			fragment := def.(*ast.FragmentDefinition)
+			pl.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	return pl
+}
+
+// plan builds the initial document. only queries are planned since the
+// deferred fragments of mutations would run after the initial payload
+func (pl *planner) plan(operationName string) *ast.Document {
+	op := getOperation(pl.doc, operationName)
+	if op == nil || op.Operation != ast.OperationTypeQuery {
+		return nil
+	}
+
+	initialOp := *op
+	initialOp.SelectionSet = pl.split(op.SelectionSet, []string{})
+	pl.operation = op
+
+	return ast.NewDocument(&ast.Document{Definitions: []ast.Node{&initialOp}})
+}
+
+// removes the deferred fragments from a selection set and inlines fragment
+// spreads. objects holding deferred fragments select their typename so that
+// the fragments can be resolved on their runtime type
+func (pl *planner) split(set *ast.SelectionSet, path []string) *ast.SelectionSet {
+	initial := ast.NewSelectionSet(&ast.SelectionSet{Loc: set.Loc})
+	needsTypename := false
+
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			field := *s
+			key := responseKey(s)
+			if s.SelectionSet != nil {
+				field.SelectionSet = pl.split(s.SelectionSet, appendKey(path, key))
+			}
+
+			if args, ok := pl.directiveArgs(s.Directives, tools.StreamDirective); ok {
+				initialCount := intArg(args["initialCount"])
+				label, _ := args["label"].(string)
+				pl.streams = append(pl.streams, &streamedField{
+					path:         appendKey(path, key),
+					label:        label,
+					initialCount: initialCount,
+				})
+			}
+
+			initial.Selections = append(initial.Selections, &field)
+
+		case *ast.InlineFragment, *ast.FragmentSpread:
+			fragment := pl.inlineFragment(s)
+			if fragment == nil {
+				continue
+			}
+
+			if args, ok := pl.directiveArgs(fragment.Directives, tools.DeferDirective); ok {
+				label, _ := args["label"].(string)
+				deferred := &deferredFragment{
+					path:         path,
+					label:        label,
+					selectionSet: pl.inline(fragment.SelectionSet),
+				}
+				if fragment.TypeCondition != nil {
+					deferred.typeCondition = fragment.TypeCondition.Name.Value
+				}
+				pl.deferred = append(pl.deferred, deferred)
+				needsTypename = true
+				continue
+			}
+
+			fragment.SelectionSet = pl.split(fragment.SelectionSet, path)
+			initial.Selections = append(initial.Selections, fragment)
+		}
+	}
+
+	if needsTypename {
+		initial.Selections = append(initial.Selections, ast.NewField(&ast.Field{
+			Alias: ast.NewName(&ast.Name{Value: typenameAlias}),
+			Name:  ast.NewName(&ast.Name{Value: "__typename"}),
+		}))
+	}
+
+	return initial
+}
+
+// builds the document of a deferred fragment, it is executed with the object
+// the fragment is deferred on as the root
+func (pl *planner) fragmentDocument(fragment *deferredFragment) *ast.Document {
+	return ast.NewDocument(&ast.Document{Definitions: []ast.Node{
+		ast.NewOperationDefinition(&ast.OperationDefinition{
+			Operation:           ast.OperationTypeQuery,
+			VariableDefinitions: pl.operation.VariableDefinitions,
+			SelectionSet:        fragment.selectionSet,
+		}),
+	}})
+}
+
+// inlines all fragment spreads of a selection set
+func (pl *planner) inline(set *ast.SelectionSet) *ast.SelectionSet {
+	if set == nil {
+		return nil
+	}
+
+	inlined := ast.NewSelectionSet(&ast.SelectionSet{Loc: set.Loc})
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			field := *s
+			field.SelectionSet = pl.inline(s.SelectionSet)
+			inlined.Selections = append(inlined.Selections, &field)
+		case *ast.InlineFragment, *ast.FragmentSpread:
+			if fragment := pl.inlineFragment(s); fragment != nil {
+				fragment.SelectionSet = pl.inline(fragment.SelectionSet)
+				inlined.Selections = append(inlined.Selections, fragment)
+			}
+		}
+	}
+
+	return inlined
+}
+
+// returns a copy of an inline fragment or converts a fragment spread to one
+func (pl *planner) inlineFragment(sel ast.Selection) *ast.InlineFragment {
+	switch s := sel.(type) {
+	case *ast.InlineFragment:
+		fragment := *s
+		return &fragment
+	case *ast.FragmentSpread:
+		def, ok := pl.fragments[s.Name.Value]
+		if !ok {
+			return nil
+		}
+		return ast.NewInlineFragment(&ast.InlineFragment{
+			Loc:           s.Loc,
+			TypeCondition: def.TypeCondition,
+			Directives:    s.Directives,
+			SelectionSet:  def.SelectionSet,
+		})
+	}
+	return nil
+}
+
+// gets the argument values of an enabled incremental directive
+func (pl *planner) directiveArgs(directives []*ast.Directive, directive *graphql.Directive) (map[string]any, bool) {
+	for _, d := range directives {
+		if d.Name == nil || d.Name.Value != directive.Name {
+			continue
+		}
+		args, err := tools.GetArgumentValues(directive.Args, d.Arguments, pl.variables)
+		if err != nil {
+			return nil, false
+		}
+		if enabled, ok := args["if"].(bool); ok && !enabled {
+			return nil, false
+		}
+		return args, true
+	}
+	return nil, false
+}
+
+// gets the value of an Int argument, literals are int64 and JSON variables
+// are float64
+func intArg(value any) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// finds the objects of the initial data the deferred fragments apply to. this
+// includes the items of streamed lists since their patches are delivered
+// before the deferred fragments
+func (pl *planner) deferredObjects(schema *graphql.Schema, data any) []deferredObject {
+	objects := []deferredObject{}
+	for _, fragment := range pl.deferred {
+		pl.locate(data, fragment.path, []any{}, false, func(value any, path []any) {
+			obj, ok := value.(map[string]any)
+			if !ok {
+				return
+			}
+			typename, _ := obj[typenameAlias].(string)
+			if typename == "" {
+				return
+			}
+			if fragment.typeCondition != "" && !isPossibleType(schema, fragment.typeCondition, typename) {
+				return
+			}
+			objects = append(objects, deferredObject{fragment: fragment, path: path, typename: typename})
+		})
+	}
+	return objects
+}
+
+// moves the items of streamed lists past their initial count out of the
+// initial result and into patches, along with their errors
+func (pl *planner) streamPatches(initial *graphql.Result) []*Payload {
+	patches := []*Payload{}
+	for _, stream := range pl.streams {
+		parentPath, key := stream.path[:len(stream.path)-1], stream.path[len(stream.path)-1]
+		pl.locate(initial.Data, parentPath, []any{}, true, func(value any, path []any) {
+			obj, ok := value.(map[string]any)
+			if !ok {
+				return
+			}
+			list, ok := obj[key].([]any)
+			if !ok || len(list) <= stream.initialCount {
+				return
+			}
+			obj[key] = list[:stream.initialCount]
+
+			listPath := appendPath(path, key)
+			var errs []gqlerrors.FormattedError
+			errs, initial.Errors = splitErrors(initial.Errors, listPath, func(next any) bool {
+				index, ok := next.(int)
+				return ok && index >= stream.initialCount
+			})
+			patches = append(patches, &Payload{
+				Items:  list[stream.initialCount:],
+				Path:   appendPath(listPath, stream.initialCount),
+				Label:  stream.label,
+				Errors: errs,
+			})
+		})
+	}
+	return patches
+}
+
+// locate calls fn with every value found at the response key path. lists are
+// expanded into their items, skipStreamed skips items delivered by a stream
+func (pl *planner) locate(data any, keys []string, path []any, skipStreamed bool, fn func(value any, path []any)) {
+	switch value := data.(type) {
+	case []any:
+		initialCount, streamed := pl.streamedCount(path)
+		for i, item := range value {
+			if skipStreamed && streamed && i >= initialCount {
+				break
+			}
+			pl.locate(item, keys, appendPath(path, i), skipStreamed, fn)
+		}
+	case map[string]any:
+		if len(keys) == 0 {
+			fn(value, path)
+			return
+		}
+		if next, ok := value[keys[0]]; ok && next != nil {
+			pl.locate(next, keys[1:], appendPath(path, keys[0]), skipStreamed, fn)
+		}
+	}
+}
+
+// gets the initial count if the list at the path is streamed
+func (pl *planner) streamedCount(path []any) (int, bool) {
+	keys := []string{}
+	for _, p := range path {
+		if key, ok := p.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	joined := strings.Join(keys, ".")
+
+	for _, stream := range pl.streams {
+		if strings.Join(stream.path, ".") == joined {
+			return stream.initialCount, true
+		}
+	}
+	return 0, false
+}
+
+// splits the errors located below the path from the rest of the errors
+func splitErrors(errs []gqlerrors.FormattedError, path []any, match func(next any) bool) (matched, rest []gqlerrors.FormattedError) {
+	for _, err := range errs {
+		if isBelow(err.Path, path) && match(err.Path[len(path)]) {
+			matched = append(matched, err)
+		} else {
+			rest = append(rest, err)
+		}
+	}
+	return matched, rest
+}
+
+// determines if an error path is located below the path
+func isBelow(errPath, path []any) bool {
+	if len(errPath) <= len(path) {
+		return false
+	}
+	for i, p := range path {
+		if errPath[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixes the paths of errors that happened in a deferred fragment with the
+// path of the object it was resolved on
+func prefixErrors(errs []gqlerrors.FormattedError, path []any) []gqlerrors.FormattedError {
+	for i := range errs {
+		if len(errs[i].Path) > 0 {
+			errs[i].Path = append(append([]any{}, path...), errs[i].Path...)
+		}
+	}
+	return errs
+}
+
+// determines if typename satisfies a fragment type condition
+func isPossibleType(schema *graphql.Schema, typeCondition, typename string) bool {
+	if typeCondition == typename {
+		return true
+	}
+	object, ok := schema.Type(typename).(*graphql.Object)
+	if !ok {
+		return false
+	}
+	abstract, ok := schema.Type(typeCondition).(graphql.Abstract)
+	return ok && schema.IsPossibleType(abstract, object)
+}
+
+// removes the typename alias from all objects
+func removeTypenameAlias(data any) {
+	switch value := data.(type) {
+	case []any:
+		for _, item := range value {
+			removeTypenameAlias(item)
+		}
+	case map[string]any:
+		delete(value, typenameAlias)
+		for _, v := range value {
+			removeTypenameAlias(v)
+		}
+	}
+}
+
+// gets the key a field is written to in the response
+func responseKey(field *ast.Field) string {
+	if field.Alias != nil && field.Alias.Value != "" {
+		return field.Alias.Value
+	}
+	return field.Name.Value
+}
+
+// appends to a copy of the response path
+func appendPath(path []any, elem any) []any {
+	p := make([]any, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, elem)
+}
+
+// appends to a copy of the response key path
+func appendKey(path []string, key string) []string {
+	p := make([]string, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, key)
+}
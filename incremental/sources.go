@@ -0,0 +1,173 @@
+package incremental
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/gqlerrors"
+)
+
+// errNoExtension is reported for fragments deferred below the root of a
+// schema that was built without the extension
+var errNoExtension = errors.New("deferring fragments below the root requires incremental.NewExtension() in the schema extensions")
+
+// sources records the values returned by the resolvers of the fields deferred
+// fragments are located on, so that the fragments can be resolved later
+// without executing the operation again. it is carried by the context of the
+// initial execution and filled by the extension
+type sources struct {
+	paths     map[string]bool
+	installed bool
+	mu        sync.Mutex
+	values    map[string]any
+}
+
+type sourcesContextKey struct{}
+
+func newSources(deferred []*deferredFragment) *sources {
+	s := &sources{
+		paths:  map[string]bool{},
+		values: map[string]any{},
+	}
+	for _, fragment := range deferred {
+		if len(fragment.path) > 0 {
+			s.paths[strings.Join(fragment.path, ".")] = true
+		}
+	}
+	return s
+}
+
+// withContext returns a context the extension records the sources in
+func (s *sources) withContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, sourcesContextKey{}, s)
+}
+
+// lookup gets the source of the object at a response path
+func (s *sources) lookup(path []any) (any, error) {
+	if !s.installed {
+		return nil, errNoExtension
+	}
+
+	// the value is recorded for the field, list indexes that follow are
+	// resolved from the recorded list
+	field := len(path)
+	for field > 0 {
+		if _, ok := path[field-1].(string); ok {
+			break
+		}
+		field--
+	}
+
+	s.mu.Lock()
+	value, ok := s.values[pathKey(path[:field])]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no source recorded for %s", pathKey(path))
+	}
+
+	for _, index := range path[field:] {
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("expected a list source at %s", pathKey(path))
+		}
+		value = v.Index(index.(int)).Interface()
+	}
+
+	if reflect.ValueOf(value).Kind() == reflect.Func {
+		return nil, fmt.Errorf("cannot defer a fragment on %s, its resolver returns a thunk", pathKey(path))
+	}
+	return value, nil
+}
+
+// NewExtension creates the graphql extension that lets fragments be deferred
+// below the root of the schema. Add it to the extensions of the schema, it
+// only records values for incremental executions
+func NewExtension() graphql.Extension {
+	return &extension{}
+}
+
+type extension struct{}
+
+func (e *extension) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	return ctx
+}
+
+func (e *extension) Name() string {
+	return "incremental"
+}
+
+func (e *extension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (e *extension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (e *extension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	if s := sourcesFrom(ctx); s != nil {
+		s.installed = true
+	}
+	return ctx, func(*graphql.Result) {}
+}
+
+func (e *extension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	s := sourcesFrom(ctx)
+	if s == nil {
+		return ctx, func(any, error) {}
+	}
+
+	path := info.Path.AsArray()
+	keys := []string{}
+	for _, p := range path {
+		if key, ok := p.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	if !s.paths[strings.Join(keys, ".")] {
+		return ctx, func(any, error) {}
+	}
+
+	return ctx, func(value any, err error) {
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.values[pathKey(path)] = value
+		s.mu.Unlock()
+	}
+}
+
+func (e *extension) HasResult() bool {
+	return false
+}
+
+func (e *extension) GetResult(context.Context) any {
+	return nil
+}
+
+// gets the sources of an incremental execution
+func sourcesFrom(ctx context.Context) *sources {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(sourcesContextKey{}).(*sources)
+	return s
+}
+
+// joins a response path into a map key
+func pathKey(path []any) string {
+	keys := make([]string, len(path))
+	for i, p := range path {
+		keys[i] = fmt.Sprint(p)
+	}
+	return strings.Join(keys, ".")
+}
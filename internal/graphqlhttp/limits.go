@@ -0,0 +1,184 @@
+package graphqlhttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/dagger/graphql/language/lexer"
+	"github.com/dagger/graphql/language/source"
+)
+
+// Limits are request limits, a zero value disables the limit
+type Limits struct {
+	MaxBodyBytes      int64
+	MaxQueryLength    int
+	MaxVariablesBytes int
+	MaxTokens         int
+	ParseTimeout      time.Duration
+	ExecutionTimeout  time.Duration
+}
+
+// implemented by the response writers of net/http since go 1.20
+type readDeadliner interface {
+	SetReadDeadline(deadline time.Time) error
+}
+
+// Parse parses a request with parse within the body size limit and parse
+// timeout. the request is parsed on the calling goroutine, the read deadline
+// of the connection interrupts a client that stops sending the body
+func Parse[T any](l Limits, w http.ResponseWriter, r *http.Request, parse func(*http.Request) (T, error)) (T, error) {
+	if l.MaxBodyBytes > 0 && r.Body != nil {
+		r.Body = &limitedBody{ReadCloser: r.Body, remaining: l.MaxBodyBytes, limit: l.MaxBodyBytes}
+	}
+
+	if l.ParseTimeout <= 0 {
+		return parse(r)
+	}
+
+	deadline := time.Now().Add(l.ParseTimeout)
+	rc, supported := w.(readDeadliner)
+	supported = supported && rc.SetReadDeadline(deadline) == nil
+	// response writers that do not support read deadlines only stop reading
+	// between reads
+	if r.Body != nil {
+		r.Body = &deadlineBody{ReadCloser: r.Body, deadline: deadline, timeout: l.ParseTimeout}
+	}
+
+	opts, err := parse(r)
+	if time.Now().After(deadline) {
+		// the deadline is kept so that the server does not wait for the rest
+		// of the body before responding
+		if err == nil {
+			err = parseTimeoutError(l.ParseTimeout)
+		}
+		return opts, err
+	}
+
+	if supported {
+		rc.SetReadDeadline(time.Time{})
+	}
+	return opts, err
+}
+
+// Check checks the query and variables of a parsed request against the limits
+func (l Limits) Check(query string, variables map[string]any) error {
+	if l.MaxQueryLength > 0 && len(query) > l.MaxQueryLength {
+		return NewRequestError(http.StatusRequestEntityTooLarge, "query exceeds the maximum length of %d characters", l.MaxQueryLength)
+	}
+
+	if l.MaxVariablesBytes > 0 && len(variables) > 0 {
+		encoded, err := json.Marshal(variables)
+		if err != nil {
+			return NewRequestError(http.StatusBadRequest, "invalid variables: %v", err)
+		}
+		if len(encoded) > l.MaxVariablesBytes {
+			return NewRequestError(http.StatusRequestEntityTooLarge, "variables exceed the maximum size of %d bytes", l.MaxVariablesBytes)
+		}
+	}
+
+	if l.MaxTokens > 0 && countTokens(query, l.MaxTokens) > l.MaxTokens {
+		return NewRequestError(http.StatusBadRequest, "query exceeds the maximum of %d tokens", l.MaxTokens)
+	}
+
+	return nil
+}
+
+// WithDeadline adds the execution deadline to the context
+func (l Limits) WithDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.ExecutionTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, l.ExecutionTimeout)
+}
+
+// CheckDeadline replaces the result of an operation that ran past its
+// deadline and returns the status code it is reported with
+func (l Limits) CheckDeadline(ctx context.Context, result *graphql.Result) (*graphql.Result, int) {
+	if l.ExecutionTimeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		return &graphql.Result{
+			Errors: gqlerrors.FormatErrors(fmt.Errorf("operation exceeded the execution timeout of %s", l.ExecutionTimeout)),
+		}, http.StatusServiceUnavailable
+	}
+	return result, 0
+}
+
+// counts the tokens of a query, stopping once max has been exceeded. syntax
+// errors end the count and are left for the parser to report
+func countTokens(query string, max int) int {
+	lex := lexer.Lex(source.NewSource(&source.Source{
+		Body: []byte(query),
+		Name: "GraphQL request",
+	}))
+
+	count := 0
+	for count <= max {
+		token, err := lex(0)
+		if err != nil || token.Kind == lexer.EOF {
+			break
+		}
+		count++
+	}
+
+	return count
+}
+
+func parseTimeoutError(timeout time.Duration) error {
+	return NewRequestError(http.StatusRequestTimeout, "the request was not received within %s", timeout)
+}
+
+// limitedBody reports a RequestError once more than limit bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+
+	n = int(b.remaining)
+	b.remaining = -1
+	return n, b.tooLarge()
+}
+
+func (b *limitedBody) tooLarge() error {
+	return NewRequestError(http.StatusRequestEntityTooLarge, "request body exceeds the maximum size of %d bytes", b.limit)
+}
+
+// deadlineBody reports a RequestError for reads past the deadline, including
+// reads the connection deadline interrupted
+type deadlineBody struct {
+	io.ReadCloser
+	deadline time.Time
+	timeout  time.Duration
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	if time.Now().After(b.deadline) {
+		return 0, parseTimeoutError(b.timeout)
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && time.Now().After(b.deadline) {
+		return n, parseTimeoutError(b.timeout)
+	}
+	return n, err
+}
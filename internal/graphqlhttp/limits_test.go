@@ -0,0 +1,102 @@
+package graphqlhttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	l := Limits{
+		MaxQueryLength:    32,
+		MaxVariablesBytes: 16,
+		MaxTokens:         5,
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		status    int
+	}{
+		{"query", "{ hello hello hello hello hello hello }", nil, http.StatusRequestEntityTooLarge},
+		{"variables", "{ hello }", map[string]any{"a": "aaaaaaaaaaaaa"}, http.StatusRequestEntityTooLarge},
+		{"tokens", "{ hello hello hello hello }", nil, http.StatusBadRequest},
+		{"ok", "{ hello }", map[string]any{"a": 1}, 0},
+	}
+
+	for _, test := range tests {
+		status := 0
+		var reqErr *RequestError
+		if err := l.Check(test.query, test.variables); errors.As(err, &reqErr) {
+			status = reqErr.StatusCode
+		}
+		if status != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, status)
+		}
+	}
+}
+
+// a handler that reads the whole body within the limits
+func limitsHandler(l Limits) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := Parse(l, w, r, func(r *http.Request) ([]byte, error) {
+			return io.ReadAll(r.Body)
+		})
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			w.WriteHeader(reqErr.StatusCode)
+			return
+		}
+		w.Write(body)
+	})
+}
+
+func TestParseBodyLimit(t *testing.T) {
+	h := limitsHandler(Limits{MaxBodyBytes: 8})
+
+	for body, status := range map[string]int{
+		"12345678":  http.StatusOK,
+		"123456789": http.StatusRequestEntityTooLarge,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if w.Code != status {
+			t.Errorf("%q: expected status %d, got %d", body, status, w.Code)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	srv := httptest.NewServer(limitsHandler(Limits{ParseTimeout: 50 * time.Millisecond}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	// announce a body that never arrives
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 100\r\n\r\n{\"query\":")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Errorf("expected a response once the parse timeout fired: %v", err)
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("expected status %d, got %d", http.StatusRequestTimeout, res.StatusCode)
+	}
+}
@@ -0,0 +1,214 @@
+// Package graphqlhttp holds the HTTP request handling shared by the handler
+// and server packages
+package graphqlhttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql-go-tools/incremental"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/kinds"
+)
+
+// Media types
+const (
+	ContentTypeJSON           = "application/json"
+	ContentTypeGraphQL        = "application/graphql"
+	ContentTypeFormURLEncoded = "application/x-www-form-urlencoded"
+
+	// ContentTypeGraphQLResponse is the response media type defined by the
+	// GraphQL over HTTP specification
+	// https://graphql.github.io/graphql-over-http/draft/
+	ContentTypeGraphQLResponse = "application/graphql-response+json"
+)
+
+// RequestError is a malformed or unsupported request along with the HTTP
+// status code it is reported with
+type RequestError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// NewRequestError creates a RequestError with a formatted message
+func NewRequestError(statusCode int, format string, a ...any) *RequestError {
+	return &RequestError{
+		StatusCode: statusCode,
+		Message:    fmt.Sprintf(format, a...),
+	}
+}
+
+// GetMediaType gets the media type of a Content-Type or Accept value without
+// parameters
+func GetMediaType(value string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(value, ";")[0]))
+}
+
+// CheckSpecMethod checks the method and Content-Type of a request against the
+// GraphQL over HTTP specification before the body is parsed
+func CheckSpecMethod(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		switch GetMediaType(r.Header.Get("Content-Type")) {
+		case ContentTypeJSON, ContentTypeGraphQL, ContentTypeFormURLEncoded:
+		default:
+			return NewRequestError(http.StatusUnsupportedMediaType, "unsupported Content-Type %q, use %q", r.Header.Get("Content-Type"), ContentTypeJSON)
+		}
+	default:
+		return NewRequestError(http.StatusMethodNotAllowed, "method %s is not allowed, use GET or POST", r.Method)
+	}
+	return nil
+}
+
+// CheckSpecRequest checks a parsed request against the GraphQL over HTTP
+// specification and returns the negotiated response media type. documentID
+// is the ID of a trusted document sent instead of the query. Mutations
+// requested with GET are rejected by the MethodPlugin
+func CheckSpecRequest(r *http.Request, query, documentID string) (string, error) {
+	mediaType, ok := NegotiateMediaType(r)
+	if !ok {
+		if !incremental.Accepts(r) {
+			return ContentTypeJSON, NewRequestError(http.StatusNotAcceptable, "the Accept header must allow %q or %q", ContentTypeGraphQLResponse, ContentTypeJSON)
+		}
+		mediaType = ContentTypeJSON
+	}
+
+	// trusted documents may be sent by ID alone
+	if query == "" && documentID == "" {
+		return mediaType, NewRequestError(http.StatusBadRequest, "the query parameter is required")
+	}
+
+	return mediaType, nil
+}
+
+// MethodCheck records the rejection of a mutation requested with GET by the
+// MethodPlugin
+type MethodCheck struct {
+	err *RequestError
+}
+
+type methodCheckContextKey struct{}
+
+// WithMethodCheck returns a context that enables the MethodPlugin for a
+// request, along with the MethodCheck its rejection is recorded in
+func WithMethodCheck(ctx context.Context) (context.Context, *MethodCheck) {
+	check := &MethodCheck{}
+	return context.WithValue(ctx, methodCheckContextKey{}, check), check
+}
+
+// Err gets the error a request was rejected with, or nil
+func (c *MethodCheck) Err() error {
+	if c == nil || c.err == nil {
+		return nil
+	}
+	return c.err
+}
+
+// MethodPlugin rejects mutations requested with GET. The operation type is
+// checked once the document is parsed, so that documents sent by ID are
+// checked too. Only requests with a context from WithMethodCheck are checked
+func MethodPlugin() executor.Plugin {
+	return &methodPlugin{}
+}
+
+type methodPlugin struct {
+	executor.NoopPlugin
+}
+
+func (p *methodPlugin) OnParse(ctx context.Context, req *executor.Request, doc *ast.Document) error {
+	check, ok := ctx.Value(methodCheckContextKey{}).(*MethodCheck)
+	if !ok || req.HTTPRequest == nil || req.HTTPRequest.Method != http.MethodGet {
+		return nil
+	}
+
+	if getOperationType(doc, req.OperationName) == ast.OperationTypeMutation {
+		check.err = NewRequestError(http.StatusMethodNotAllowed, "mutations cannot be executed with GET, use POST")
+		return check.err
+	}
+	return nil
+}
+
+// NegotiateMediaType negotiates the response media type from the Accept
+// header, a missing Accept header is treated as application/json for
+// compatibility with legacy clients
+func NegotiateMediaType(r *http.Request) (string, bool) {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return ContentTypeJSON, true
+	}
+
+	mediaType, quality := "", 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		q := 1.0
+		for _, param := range params[1:] {
+			if key, value, ok := strings.Cut(param, "="); ok && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		var candidate string
+		switch GetMediaType(params[0]) {
+		case ContentTypeGraphQLResponse:
+			candidate = ContentTypeGraphQLResponse
+		case ContentTypeJSON, "application/*", "*/*":
+			candidate = ContentTypeJSON
+		default:
+			continue
+		}
+
+		if q > quality {
+			mediaType, quality = candidate, q
+		}
+	}
+
+	return mediaType, mediaType != ""
+}
+
+// ResultStatus gets the status code of a result. with
+// application/graphql-response+json a request that failed to parse or
+// validate, or that was rejected before execution, is reported as a bad
+// request. once execution started the status is 200 even if the data is null.
+// application/json always uses 200
+func ResultStatus(mediaType string, executed bool) int {
+	if mediaType == ContentTypeGraphQLResponse && !executed {
+		return http.StatusBadRequest
+	}
+	return http.StatusOK
+}
+
+// gets the type of the operation that will be executed or an empty string
+// when it cannot be determined
+func getOperationType(doc *ast.Document, operationName string) string {
+	operationType := ""
+	for _, def := range doc.Definitions {
+		if def.GetKind() != kinds.OperationDefinition {
+			continue
+		}
+		op := def.(*ast.OperationDefinition)
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			operationType = op.Operation
+		}
+	}
+
+	return operationType
+}
+
+// AcceptsHTML determines if the request comes from a browser that should be
+// served a UI
+func AcceptsHTML(r *http.Request) bool {
+	acceptHeader := r.Header.Get("Accept")
+	_, raw := r.URL.Query()["raw"]
+	return !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html")
+}
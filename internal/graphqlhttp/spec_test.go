@@ -0,0 +1,156 @@
+package graphqlhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/executor"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		accept    string
+		mediaType string
+		ok        bool
+	}{
+		{"", ContentTypeJSON, true},
+		{"application/json", ContentTypeJSON, true},
+		{"application/graphql-response+json", ContentTypeGraphQLResponse, true},
+		{"application/json;q=0.5, application/graphql-response+json", ContentTypeGraphQLResponse, true},
+		{"application/graphql-response+json;q=0.1, */*", ContentTypeJSON, true},
+		{"application/xml", "", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		mediaType, ok := NegotiateMediaType(r)
+		if mediaType != test.mediaType || ok != test.ok {
+			t.Errorf("%q: expected %q %v, got %q %v", test.accept, test.mediaType, test.ok, mediaType, ok)
+		}
+	}
+}
+
+func TestCheckSpec(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		query       string
+		documentID  string
+		status      int
+	}{
+		{"post", http.MethodPost, ContentTypeJSON, "{ hello }", "", 0},
+		{"get query", http.MethodGet, "", "{ hello }", "", 0},
+		{"document id", http.MethodPost, ContentTypeJSON, "", "abc", 0},
+		{"missing query", http.MethodPost, ContentTypeJSON, "", "", http.StatusBadRequest},
+		{"unsupported media type", http.MethodPost, "text/plain", "{ hello }", "", http.StatusUnsupportedMediaType},
+		{"method not allowed", http.MethodPut, ContentTypeJSON, "{ hello }", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/", nil)
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+
+		err := CheckSpecMethod(r)
+		if err == nil {
+			_, err = CheckSpecRequest(r, test.query, test.documentID)
+		}
+
+		status := 0
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			status = reqErr.StatusCode
+		}
+		if status != test.status {
+			t.Errorf("%s: expected status %d, got %d: %v", test.name, test.status, status, err)
+		}
+	}
+}
+
+func TestResultStatus(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		executed  bool
+		status    int
+	}{
+		{ContentTypeGraphQLResponse, true, http.StatusOK},
+		{ContentTypeGraphQLResponse, false, http.StatusBadRequest},
+		{ContentTypeJSON, false, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if status := ResultStatus(test.mediaType, test.executed); status != test.status {
+			t.Errorf("%s executed %v: expected status %d, got %d", test.mediaType, test.executed, test.status, status)
+		}
+	}
+}
+
+func TestMethodPlugin(t *testing.T) {
+	calls := 0
+	field := &graphql.Field{
+		Type: graphql.String,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			calls++
+			return "world", nil
+		},
+	}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{"hello": field}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{"setHello": field}}),
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+	exec := executor.New(&executor.Config{
+		Schema:  &schema,
+		Plugins: []executor.Plugin{MethodPlugin()},
+	})
+
+	tests := []struct {
+		name          string
+		method        string
+		query         string
+		operationName string
+		checked       bool
+		rejected      bool
+	}{
+		{"get query", http.MethodGet, "{ hello }", "", true, false},
+		{"get mutation", http.MethodGet, "mutation { setHello }", "", true, true},
+		{"post mutation", http.MethodPost, "mutation { setHello }", "", true, false},
+		{"named mutation", http.MethodGet, "query Q { hello } mutation M { setHello }", "M", true, true},
+		{"named query", http.MethodGet, "query Q { hello } mutation M { setHello }", "Q", true, false},
+		{"unchecked", http.MethodGet, "mutation { setHello }", "", false, false},
+	}
+
+	for _, test := range tests {
+		calls = 0
+		ctx := context.Background()
+		var check *MethodCheck
+		if test.checked {
+			ctx, check = WithMethodCheck(ctx)
+		}
+
+		result := exec.Execute(&executor.Request{
+			Query:         test.query,
+			OperationName: test.operationName,
+			Context:       ctx,
+			Transport:     executor.TransportHTTP,
+			HTTPRequest:   httptest.NewRequest(test.method, "/", nil),
+		})
+
+		var reqErr *RequestError
+		rejected := errors.As(check.Err(), &reqErr) && reqErr.StatusCode == http.StatusMethodNotAllowed
+		if rejected != test.rejected || (calls == 0) != test.rejected {
+			t.Errorf("%s: expected rejected %v, got %v after %d calls: %v", test.name, test.rejected, rejected, calls, result.Errors)
+		}
+	}
+}
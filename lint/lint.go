@@ -0,0 +1,398 @@
+// Package lint checks the style of type definitions with configurable rules
+// and reports file and line diagnostics
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/location"
+	"github.com/dagger/graphql/language/parser"
+	"github.com/dagger/graphql/language/source"
+)
+
+// Severity is the severity of a diagnostic
+type Severity string
+
+// Severities
+const (
+	SeverityOff     Severity = "off"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Rule checks a document and reports its diagnostics with Context.Report
+type Rule struct {
+	Name        string
+	Description string
+
+	// Severity is the default severity of the diagnostics of the rule
+	Severity Severity
+
+	Check func(ctx *Context)
+}
+
+// Config configures the rules, the built in rules run with their default
+// severity unless they are configured
+type Config struct {
+	// Rules sets the severity of rules by name, SeverityOff disables a rule
+	Rules map[string]Severity `json:"rules"`
+
+	// CustomRules run along the built in rules
+	CustomRules []*Rule `json:"-"`
+}
+
+// LoadConfig reads a JSON config like {"rules": {"descriptions-required": "off"}}
+func LoadConfig(path string) (Config, error) {
+	config := Config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid lint config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Diagnostic is a rule violation at a position of a source
+type Diagnostic struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+}
+
+// String formats the diagnostic as file:line:column: severity: message (rule)
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", d.File, d.Line, d.Column, d.Severity, d.Message, d.Rule)
+}
+
+// Diagnostics are the diagnostics of a document sorted by position
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any diagnostic is an error
+func (d Diagnostics) HasErrors() bool {
+	for _, diagnostic := range d {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes one diagnostic per line followed by a summary
+func (d Diagnostics) WriteText(w io.Writer) error {
+	errors := 0
+	for _, diagnostic := range d {
+		if diagnostic.Severity == SeverityError {
+			errors++
+		}
+		if _, err := fmt.Fprintln(w, diagnostic.String()); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d errors, %d warnings\n", errors, len(d)-errors)
+	return err
+}
+
+// WriteJSON writes the diagnostics as a JSON array
+func (d Diagnostics) WriteJSON(w io.Writer) error {
+	if d == nil {
+		d = Diagnostics{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Lint runs the built in and custom rules on a document, typically the one
+// built by ExecutableSchema.ConcatenateTypeDefs or ParseFiles
+func Lint(doc *ast.Document, config Config) (Diagnostics, error) {
+	rules := append(Rules(), config.CustomRules...)
+
+	known := map[string]bool{}
+	for _, rule := range rules {
+		known[rule.Name] = true
+	}
+	for name, severity := range config.Rules {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		switch severity {
+		case SeverityOff, SeverityWarning, SeverityError:
+		default:
+			return nil, fmt.Errorf("invalid severity %q for lint rule %q", severity, name)
+		}
+	}
+
+	ctx := newContext(doc)
+	for _, rule := range rules {
+		severity := rule.Severity
+		if s, ok := config.Rules[rule.Name]; ok {
+			severity = s
+		}
+		if severity == SeverityOff || rule.Check == nil {
+			continue
+		}
+
+		ctx.rule, ctx.severity = rule.Name, severity
+		rule.Check(ctx)
+	}
+
+	sort.SliceStable(ctx.diagnostics, func(i, j int) bool {
+		a, b := ctx.diagnostics[i], ctx.diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return ctx.diagnostics, nil
+}
+
+// ParseFiles parses files into one document like ConcatenateTypeDefs, but
+// keeps the file names so diagnostics point to them
+func ParseFiles(paths ...string) (*ast.Document, error) {
+	doc := ast.NewDocument(nil)
+	for _, path := range paths {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		sub, err := parser.Parse(parser.ParseParams{
+			Source: &source.Source{
+				Body: body,
+				Name: path,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		doc.Definitions = append(doc.Definitions, sub.Definitions...)
+	}
+	return doc, nil
+}
+
+// Context gives rules access to the document and collects their diagnostics
+type Context struct {
+	Document *ast.Document
+
+	rule        string
+	severity    Severity
+	types       map[string]ast.Node
+	fields      map[string][]*ast.FieldDefinition
+	reachable   map[string]bool
+	diagnostics Diagnostics
+}
+
+func newContext(doc *ast.Document) *Context {
+	ctx := &Context{
+		Document: doc,
+		types:    map[string]ast.Node{},
+		fields:   map[string][]*ast.FieldDefinition{},
+	}
+
+	for _, def := range doc.Definitions {
+		if ext, ok := def.(*ast.TypeExtensionDefinition); ok && ext.Definition != nil {
+			name := ext.Definition.Name.Value
+			ctx.fields[name] = append(ctx.fields[name], ext.Definition.Fields...)
+			continue
+		}
+
+		name := typeName(def)
+		if name == "" {
+			continue
+		}
+		if _, ok := ctx.types[name]; !ok {
+			ctx.types[name] = def
+		}
+		ctx.fields[name] = append(ctx.fields[name], fieldsOf(def)...)
+	}
+	return ctx
+}
+
+// Report adds a diagnostic of the running rule at the location of node
+func (ctx *Context) Report(node ast.Node, format string, args ...any) {
+	diagnostic := Diagnostic{
+		Rule:     ctx.rule,
+		Severity: ctx.severity,
+		Message:  fmt.Sprintf(format, args...),
+	}
+
+	if loc := nameLoc(node); loc != nil {
+		position := location.GetLocation(loc.Source, loc.Start)
+		diagnostic.Line, diagnostic.Column = position.Line, position.Column
+		if loc.Source != nil {
+			diagnostic.File = loc.Source.Name
+		}
+	}
+	ctx.diagnostics = append(ctx.diagnostics, diagnostic)
+}
+
+// returns the location of the name of a definition so diagnostics do not
+// point at its description
+func nameLoc(node ast.Node) *ast.Location {
+	var name *ast.Name
+	switch n := node.(type) {
+	case interface{ GetName() *ast.Name }:
+		name = n.GetName()
+	case *ast.FieldDefinition:
+		name = n.Name
+	case *ast.InputValueDefinition:
+		name = n.Name
+	case *ast.EnumValueDefinition:
+		name = n.Name
+	}
+	if name != nil && name.Loc != nil {
+		return name.Loc
+	}
+	return node.GetLoc()
+}
+
+// Type returns the definition of a type, or nil if it is not defined in the
+// document
+func (ctx *Context) Type(name string) ast.Node {
+	return ctx.types[name]
+}
+
+// Fields returns the fields of an object or interface including the fields
+// added by extensions
+func (ctx *Context) Fields(typeName string) []*ast.FieldDefinition {
+	return ctx.fields[typeName]
+}
+
+// Reachable reports whether a type is used by the root operation types,
+// directly or through other types
+func (ctx *Context) Reachable(name string) bool {
+	if ctx.reachable == nil {
+		ctx.reachable = ctx.walkReachable()
+	}
+	return ctx.reachable[name]
+}
+
+// returns the name of a type definition, or an empty string for other
+// definitions
+func typeName(def ast.Node) string {
+	switch d := def.(type) {
+	case *ast.ObjectDefinition:
+		return d.Name.Value
+	case *ast.InterfaceDefinition:
+		return d.Name.Value
+	case *ast.UnionDefinition:
+		return d.Name.Value
+	case *ast.EnumDefinition:
+		return d.Name.Value
+	case *ast.InputObjectDefinition:
+		return d.Name.Value
+	case *ast.ScalarDefinition:
+		return d.Name.Value
+	}
+	return ""
+}
+
+func fieldsOf(def ast.Node) []*ast.FieldDefinition {
+	switch d := def.(type) {
+	case *ast.ObjectDefinition:
+		return d.Fields
+	case *ast.InterfaceDefinition:
+		return d.Fields
+	}
+	return nil
+}
+
+// returns the name of the named type wrapped by lists and non nulls
+func namedType(t ast.Type) string {
+	switch t := t.(type) {
+	case *ast.NonNull:
+		return namedType(t.Type)
+	case *ast.List:
+		return namedType(t.Type)
+	case *ast.Named:
+		return t.Name.Value
+	}
+	return ""
+}
+
+// collects the types reachable from the root operation types and from the
+// arguments of directives
+func (ctx *Context) walkReachable() map[string]bool {
+	reachable := map[string]bool{}
+	queue := []string{}
+	visit := func(name string) {
+		if name != "" && !reachable[name] {
+			reachable[name] = true
+			queue = append(queue, name)
+		}
+	}
+
+	hasSchema := false
+	for _, def := range ctx.Document.Definitions {
+		switch d := def.(type) {
+		case *ast.SchemaDefinition:
+			hasSchema = true
+			for _, op := range d.OperationTypes {
+				visit(op.Type.Name.Value)
+			}
+		case *ast.DirectiveDefinition:
+			for _, arg := range d.Arguments {
+				visit(namedType(arg.Type))
+			}
+		}
+	}
+	if !hasSchema {
+		visit("Query")
+		visit("Mutation")
+		visit("Subscription")
+	}
+
+	// implementations of an interface are reachable through it
+	implementations := map[string][]string{}
+	for _, def := range ctx.Document.Definitions {
+		if obj, ok := def.(*ast.ObjectDefinition); ok {
+			for _, iface := range obj.Interfaces {
+				implementations[iface.Name.Value] = append(implementations[iface.Name.Value], obj.Name.Value)
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		for _, field := range ctx.fields[name] {
+			visit(namedType(field.Type))
+			for _, arg := range field.Arguments {
+				visit(namedType(arg.Type))
+			}
+		}
+		for _, impl := range implementations[name] {
+			visit(impl)
+		}
+
+		switch d := ctx.types[name].(type) {
+		case *ast.ObjectDefinition:
+			for _, iface := range d.Interfaces {
+				visit(iface.Name.Value)
+			}
+		case *ast.UnionDefinition:
+			for _, member := range d.Types {
+				visit(member.Name.Value)
+			}
+		case *ast.InputObjectDefinition:
+			for _, field := range d.Fields {
+				visit(namedType(field.Type))
+			}
+		}
+	}
+	return reachable
+}
@@ -0,0 +1,225 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+const typeDefs = `
+"""
+The root query
+"""
+type Query {
+  user(id: ID!): user
+  Friends(first: Int, after: String): UserConnection!
+  search(filter: Filter): [String]
+}
+
+type user {
+  id: ID!
+  name: String @deprecated
+  status: Status
+}
+
+"""
+A status
+"""
+enum Status {
+  ACTIVE
+  inactive
+}
+
+"""
+A filter
+"""
+input Filter {
+  query: String
+}
+
+"""
+Users
+"""
+type UserConnection {
+  edges: [UserEdge]
+}
+
+"""
+A user edge
+"""
+type UserEdge {
+  node: user
+  cursor: String
+}
+
+"""
+An unused type
+"""
+type Unused {
+  id: ID!
+}
+
+extend type Query {
+  posts: PostConnection
+}
+
+"""
+Posts
+"""
+type PostConnection {
+  edges: [PostEdge]
+  pageInfo: PageInfo!
+}
+
+"""
+A post edge
+"""
+type PostEdge {
+  node: String
+  cursor: String!
+}
+
+"""
+Pagination
+"""
+type PageInfo {
+  hasNextPage: Boolean!
+}
+`
+
+func TestLint(t *testing.T) {
+	doc, err := (&tools.ExecutableSchema{TypeDefs: typeDefs}).ConcatenateTypeDefs()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	diagnostics, err := Lint(doc, Config{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []string{
+		"GraphQL:7:3: error: field Query.Friends should be camelCase (field-names-camel-case)",
+		"GraphQL:11:6: error: type user should be PascalCase (type-names-pascal-case)",
+		"GraphQL:11:6: warning: type user should have a description (descriptions-required)",
+		"GraphQL:13:16: warning: deprecation of user.name should have a reason (deprecation-reason-required)",
+		"GraphQL:22:3: error: enum value Status.inactive should be UPPER_CASE (enum-values-upper-case)",
+		"GraphQL:28:7: error: input type Filter should end with Input (input-types-suffix)",
+		"GraphQL:35:6: error: connection UserConnection should have a pageInfo: PageInfo! field (relay-connections)",
+		"GraphQL:42:6: error: edge UserEdge should have a cursor: String! field (relay-connections)",
+		"GraphQL:50:6: warning: type Unused is not used (no-unused-types)",
+		"GraphQL:55:3: error: connection field Query.posts should have first and after or last and before arguments (relay-connections)",
+	}
+
+	actual := []string{}
+	for _, diagnostic := range diagnostics {
+		actual = append(actual, diagnostic.String())
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected diagnostics:\n%s", strings.Join(actual, "\n"))
+		return
+	}
+	if !diagnostics.HasErrors() {
+		t.Error("expected errors")
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	doc, err := (&tools.ExecutableSchema{TypeDefs: typeDefs}).ConcatenateTypeDefs()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	config := Config{
+		Rules: map[string]Severity{
+			RuleTypeNames:        SeverityWarning,
+			RuleFieldNames:       SeverityOff,
+			RuleEnumValues:       SeverityOff,
+			RuleInputSuffix:      SeverityOff,
+			RuleRelayConnections: SeverityOff,
+			"no-query":           SeverityError,
+		},
+		CustomRules: []*Rule{
+			{
+				Name:     "no-query",
+				Severity: SeverityOff,
+				Check: func(ctx *Context) {
+					if def := ctx.Type("Query"); def != nil {
+						ctx.Report(def, "Query is not allowed")
+					}
+				},
+			},
+		},
+	}
+
+	diagnostics, err := Lint(doc, config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if diagnostics.HasErrors() != true || len(diagnostics) != 5 {
+		t.Errorf("unexpected diagnostics %v", diagnostics)
+		return
+	}
+	if diagnostics[0].Rule != "no-query" || diagnostics[1].Severity != SeverityWarning {
+		t.Errorf("unexpected diagnostics %v", diagnostics)
+		return
+	}
+
+	if _, err := Lint(doc, Config{Rules: map[string]Severity{"missing": SeverityError}}); err == nil {
+		t.Error("expected an error for an unknown rule")
+		return
+	}
+	if _, err := Lint(doc, Config{Rules: map[string]Severity{RuleTypeNames: "fatal"}}); err == nil {
+		t.Error("expected an error for an invalid severity")
+	}
+}
+
+func TestLintFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.graphql")
+	if err := os.WriteFile(path, []byte("\"Root\"\ntype Query {\n  Hello: String\n}\n"), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	doc, err := ParseFiles(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	diagnostics, err := Lint(doc, Config{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var out bytes.Buffer
+	if err := diagnostics.WriteJSON(&out); err != nil {
+		t.Error(err)
+		return
+	}
+	decoded := []Diagnostic{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(decoded) != 1 || decoded[0].File != path || decoded[0].Line != 3 || decoded[0].Column != 3 {
+		t.Errorf("unexpected diagnostics %s", out.String())
+		return
+	}
+
+	out.Reset()
+	diagnostics.WriteText(&out)
+	if !strings.HasSuffix(out.String(), "1 errors, 0 warnings\n") {
+		t.Errorf("unexpected output %s", out.String())
+	}
+}
@@ -0,0 +1,303 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/dagger/graphql/language/ast"
+)
+
+// Built in rule names
+const (
+	RuleTypeNames          = "type-names-pascal-case"
+	RuleFieldNames         = "field-names-camel-case"
+	RuleEnumValues         = "enum-values-upper-case"
+	RuleDescriptions       = "descriptions-required"
+	RuleUnusedTypes        = "no-unused-types"
+	RuleDeprecationReasons = "deprecation-reason-required"
+	RuleInputSuffix        = "input-types-suffix"
+	RuleRelayConnections   = "relay-connections"
+)
+
+var (
+	pascalCase = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	camelCase  = regexp.MustCompile(`^[a-z][A-Za-z0-9]*$`)
+	upperCase  = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+)
+
+// Rules returns the built in rules with their default severities
+func Rules() []*Rule {
+	return []*Rule{
+		{
+			Name:        RuleTypeNames,
+			Description: "type names are PascalCase",
+			Severity:    SeverityError,
+			Check:       checkTypeNames,
+		},
+		{
+			Name:        RuleFieldNames,
+			Description: "field and argument names are camelCase",
+			Severity:    SeverityError,
+			Check:       checkFieldNames,
+		},
+		{
+			Name:        RuleEnumValues,
+			Description: "enum values are UPPER_CASE",
+			Severity:    SeverityError,
+			Check:       checkEnumValues,
+		},
+		{
+			Name:        RuleDescriptions,
+			Description: "types reachable from the root types have a description",
+			Severity:    SeverityWarning,
+			Check:       checkDescriptions,
+		},
+		{
+			Name:        RuleUnusedTypes,
+			Description: "every type is reachable from the root types",
+			Severity:    SeverityWarning,
+			Check:       checkUnusedTypes,
+		},
+		{
+			Name:        RuleDeprecationReasons,
+			Description: "@deprecated has a reason",
+			Severity:    SeverityWarning,
+			Check:       checkDeprecationReasons,
+		},
+		{
+			Name:        RuleInputSuffix,
+			Description: "input type names end with Input",
+			Severity:    SeverityError,
+			Check:       checkInputSuffix,
+		},
+		{
+			Name:        RuleRelayConnections,
+			Description: "connection types, edge types and connection fields follow the Relay specification",
+			Severity:    SeverityError,
+			Check:       checkRelayConnections,
+		},
+	}
+}
+
+func checkTypeNames(ctx *Context) {
+	for _, def := range ctx.Document.Definitions {
+		if name := typeName(def); name != "" && !pascalCase.MatchString(name) {
+			ctx.Report(def, "type %s should be PascalCase", name)
+		}
+	}
+}
+
+func checkFieldNames(ctx *Context) {
+	checkArgs := func(parent string, args []*ast.InputValueDefinition) {
+		for _, arg := range args {
+			if !camelCase.MatchString(arg.Name.Value) {
+				ctx.Report(arg, "argument %s(%s:) should be camelCase", parent, arg.Name.Value)
+			}
+		}
+	}
+	checkFields := func(parent string, fields []*ast.FieldDefinition) {
+		for _, field := range fields {
+			if !camelCase.MatchString(field.Name.Value) {
+				ctx.Report(field, "field %s.%s should be camelCase", parent, field.Name.Value)
+			}
+			checkArgs(parent+"."+field.Name.Value, field.Arguments)
+		}
+	}
+
+	for _, def := range ctx.Document.Definitions {
+		switch d := def.(type) {
+		case *ast.ObjectDefinition:
+			checkFields(d.Name.Value, d.Fields)
+		case *ast.InterfaceDefinition:
+			checkFields(d.Name.Value, d.Fields)
+		case *ast.TypeExtensionDefinition:
+			if d.Definition != nil {
+				checkFields(d.Definition.Name.Value, d.Definition.Fields)
+			}
+		case *ast.InputObjectDefinition:
+			for _, field := range d.Fields {
+				if !camelCase.MatchString(field.Name.Value) {
+					ctx.Report(field, "input field %s.%s should be camelCase", d.Name.Value, field.Name.Value)
+				}
+			}
+		case *ast.DirectiveDefinition:
+			checkArgs("@"+d.Name.Value, d.Arguments)
+		}
+	}
+}
+
+func checkEnumValues(ctx *Context) {
+	for _, def := range ctx.Document.Definitions {
+		if enum, ok := def.(*ast.EnumDefinition); ok {
+			for _, value := range enum.Values {
+				if !upperCase.MatchString(value.Name.Value) {
+					ctx.Report(value, "enum value %s.%s should be UPPER_CASE", enum.Name.Value, value.Name.Value)
+				}
+			}
+		}
+	}
+}
+
+func checkDescriptions(ctx *Context) {
+	for _, def := range ctx.Document.Definitions {
+		name := typeName(def)
+		if name == "" || !ctx.Reachable(name) {
+			continue
+		}
+		if described, ok := def.(ast.DescribableNode); ok {
+			if desc := described.GetDescription(); desc == nil || strings.TrimSpace(desc.Value) == "" {
+				ctx.Report(def, "type %s should have a description", name)
+			}
+		}
+	}
+}
+
+func checkUnusedTypes(ctx *Context) {
+	for _, def := range ctx.Document.Definitions {
+		if name := typeName(def); name != "" && !ctx.Reachable(name) {
+			ctx.Report(def, "type %s is not used", name)
+		}
+	}
+}
+
+func checkDeprecationReasons(ctx *Context) {
+	check := func(path string, directives []*ast.Directive) {
+		for _, directive := range directives {
+			if directive.Name.Value != "deprecated" {
+				continue
+			}
+
+			reason := ""
+			for _, arg := range directive.Arguments {
+				if value, ok := arg.Value.(*ast.StringValue); ok && arg.Name.Value == "reason" {
+					reason = strings.TrimSpace(value.Value)
+				}
+			}
+			if reason == "" {
+				ctx.Report(directive, "deprecation of %s should have a reason", path)
+			}
+		}
+	}
+	checkFields := func(parent string, fields []*ast.FieldDefinition) {
+		for _, field := range fields {
+			path := parent + "." + field.Name.Value
+			check(path, field.Directives)
+			for _, arg := range field.Arguments {
+				check(path+"("+arg.Name.Value+":)", arg.Directives)
+			}
+		}
+	}
+
+	for _, def := range ctx.Document.Definitions {
+		switch d := def.(type) {
+		case *ast.ObjectDefinition:
+			checkFields(d.Name.Value, d.Fields)
+		case *ast.InterfaceDefinition:
+			checkFields(d.Name.Value, d.Fields)
+		case *ast.TypeExtensionDefinition:
+			if d.Definition != nil {
+				checkFields(d.Definition.Name.Value, d.Definition.Fields)
+			}
+		case *ast.InputObjectDefinition:
+			for _, field := range d.Fields {
+				check(d.Name.Value+"."+field.Name.Value, field.Directives)
+			}
+		case *ast.EnumDefinition:
+			for _, value := range d.Values {
+				check(d.Name.Value+"."+value.Name.Value, value.Directives)
+			}
+		}
+	}
+}
+
+func checkInputSuffix(ctx *Context) {
+	for _, def := range ctx.Document.Definitions {
+		if input, ok := def.(*ast.InputObjectDefinition); ok && !strings.HasSuffix(input.Name.Value, "Input") {
+			ctx.Report(input, "input type %s should end with Input", input.Name.Value)
+		}
+	}
+}
+
+// connection types have edges and pageInfo: PageInfo!, edge types have node
+// and cursor: String!, and fields returning a connection paginate forward with
+// first and after or backward with last and before
+func checkRelayConnections(ctx *Context) {
+	isConnection := func(name string) bool {
+		_, ok := ctx.Type(name).(*ast.ObjectDefinition)
+		return ok && strings.HasSuffix(name, "Connection")
+	}
+	field := func(typeName, fieldName string) *ast.FieldDefinition {
+		for _, f := range ctx.Fields(typeName) {
+			if f.Name.Value == fieldName {
+				return f
+			}
+		}
+		return nil
+	}
+
+	checkedEdges := map[string]bool{}
+	for _, def := range ctx.Document.Definitions {
+		obj, ok := def.(*ast.ObjectDefinition)
+		if !ok {
+			continue
+		}
+		name := obj.Name.Value
+
+		if isConnection(name) {
+			edges := field(name, "edges")
+			if _, isList := unwrapNonNull(edgesType(edges)).(*ast.List); !isList {
+				ctx.Report(obj, "connection %s should have an edges field returning a list", name)
+			} else if edge := namedType(edges.Type); !checkedEdges[edge] {
+				checkedEdges[edge] = true
+				if edgeDef := ctx.Type(edge); edgeDef != nil {
+					if field(edge, "node") == nil {
+						ctx.Report(edgeDef, "edge %s should have a node field", edge)
+					}
+					if cursor := field(edge, "cursor"); cursor == nil || !isNonNullNamed(cursor.Type, "String") {
+						ctx.Report(edgeDef, "edge %s should have a cursor: String! field", edge)
+					}
+				}
+			}
+
+			if pageInfo := field(name, "pageInfo"); pageInfo == nil || !isNonNullNamed(pageInfo.Type, "PageInfo") {
+				ctx.Report(obj, "connection %s should have a pageInfo: PageInfo! field", name)
+			}
+		}
+
+		for _, f := range ctx.Fields(name) {
+			if isConnection(name) || !isConnection(namedType(f.Type)) {
+				continue
+			}
+			args := map[string]bool{}
+			for _, arg := range f.Arguments {
+				args[arg.Name.Value] = true
+			}
+			if !(args["first"] && args["after"]) && !(args["last"] && args["before"]) {
+				ctx.Report(f, "connection field %s.%s should have first and after or last and before arguments", name, f.Name.Value)
+			}
+		}
+	}
+}
+
+func edgesType(field *ast.FieldDefinition) ast.Type {
+	if field == nil {
+		return nil
+	}
+	return field.Type
+}
+
+func unwrapNonNull(t ast.Type) ast.Type {
+	if nonNull, ok := t.(*ast.NonNull); ok {
+		return nonNull.Type
+	}
+	return t
+}
+
+func isNonNullNamed(t ast.Type, name string) bool {
+	nonNull, ok := t.(*ast.NonNull)
+	if !ok {
+		return false
+	}
+	named, ok := nonNull.Type.(*ast.Named)
+	return ok && named.Name.Value == name
+}
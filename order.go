@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/dagger/graphql"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/dagger/graphql/language/ast"
+	"github.com/dagger/graphql/language/kinds"
+)
+
+// Order the order of the types and directives in a built schema
+type Order int
+
+const (
+	// DeclarationOrder keeps the order in which types and directives are
+	// declared in the type definitions, built-ins first and types only
+	// known from resolvers last
+	DeclarationOrder Order = iota
+
+	// AlphabeticalOrder sorts types and directives by name
+	AlphabeticalOrder
+)
+
+// built-in types and directives in the order they are added to every schema
+var (
+	builtinTypeNames      = []string{"ID", "String", "Int", "Float", "Boolean", "DateTime"}
+	builtinDirectiveNames = []string{"include", "skip", "defer", "stream", "deprecated", "hide"}
+)
+
+// orders the names of the registered types or directives
+func (c *registry) orderNames(names map[string]bool, builtins []string, directives bool) []string {
+	ordered := make([]string, 0, len(names))
+	add := func(name string) {
+		if names[name] {
+			ordered = append(ordered, name)
+			delete(names, name)
+		}
+	}
+
+	if c.order == DeclarationOrder {
+		for _, name := range builtins {
+			add(name)
+		}
+		for _, def := range c.document.Definitions {
+			if (def.GetKind() == kinds.DirectiveDefinition) == directives {
+				add(getNodeName(def))
+			}
+		}
+	}
+
+	rest := make([]string, 0, len(names))
+	for name := range names {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+
+	return append(ordered, rest...)
+}
+
+// the order of the types of a built schema and of the fields, input fields
+// and enum values of each type. members are only ranked in declaration order,
+// names without a rank are sorted by name after the ranked ones
+type schemaOrder struct {
+	types   map[string]int
+	members map[string]map[string]int
+
+	// the types the fields and input fields of the schema belong to, the
+	// introspection lists of fields do not tell their type
+	owners map[any]string
+}
+
+// gets the order of a schema built from the types in registry order
+func (c *registry) schemaOrder(types []graphql.Type) *schemaOrder {
+	order := &schemaOrder{types: make(map[string]int, len(types))}
+	for i, t := range types {
+		order.types[t.Name()] = i
+	}
+
+	if c.order != DeclarationOrder {
+		return order
+	}
+
+	order.members = map[string]map[string]int{}
+	add := func(typeName, name string) {
+		ranks, ok := order.members[typeName]
+		if !ok {
+			ranks = map[string]int{}
+			order.members[typeName] = ranks
+		}
+		if _, ok := ranks[name]; !ok {
+			ranks[name] = len(ranks)
+		}
+	}
+
+	for _, def := range c.document.Definitions {
+		switch def := def.(type) {
+		case *ast.ObjectDefinition:
+			for _, field := range def.Fields {
+				add(def.Name.Value, field.Name.Value)
+			}
+		case *ast.TypeExtensionDefinition:
+			for _, field := range def.Definition.Fields {
+				add(def.Definition.Name.Value, field.Name.Value)
+			}
+		case *ast.InterfaceDefinition:
+			for _, field := range def.Fields {
+				add(def.Name.Value, field.Name.Value)
+			}
+		case *ast.InputObjectDefinition:
+			for _, field := range def.Fields {
+				add(def.Name.Value, field.Name.Value)
+			}
+		case *ast.EnumDefinition:
+			for _, value := range def.Values {
+				add(def.Name.Value, value.Name.Value)
+			}
+		}
+	}
+
+	return order
+}
+
+// orders the lists of a built schema that the library fills from maps and
+// that the schema keeps, the enum values declared in the type definitions and
+// the implementations of the interfaces. the schema is not shared yet so they
+// can be sorted in place
+func (c *registry) orderSchema(schema *graphql.Schema, order *schemaOrder) {
+	for _, def := range c.document.Definitions {
+		if def, ok := def.(*ast.EnumDefinition); ok {
+			if enum, ok := schema.Type(def.Name.Value).(*graphql.Enum); ok {
+				sortByRank(enum.Values(), order.members[enum.Name()], func(v *graphql.EnumValueDefinition) string { return v.Name })
+			}
+		}
+	}
+
+	for _, t := range schema.TypeMap() {
+		if iface, ok := t.(*graphql.Interface); ok && !strings.HasPrefix(iface.Name(), "__") {
+			sortByRank(schema.PossibleTypes(iface), order.types, (*graphql.Object).Name)
+		}
+	}
+
+	if !c.orderIntrospection {
+		return
+	}
+	order.owners = map[any]string{}
+	for name, t := range schema.TypeMap() {
+		switch t := t.(type) {
+		case *graphql.Object:
+			for _, field := range t.Fields() {
+				order.owners[field] = name
+			}
+		case *graphql.Interface:
+			for _, field := range t.Fields() {
+				order.owners[field] = name
+			}
+		case *graphql.InputObject:
+			for _, field := range t.Fields() {
+				order.owners[field] = name
+			}
+		}
+	}
+}
+
+// gets the extensions of a built schema, the configured extensions followed
+// by the one that orders its introspection when OrderIntrospection is set
+func (c *registry) schemaExtensions(order *schemaOrder) []graphql.Extension {
+	if !c.orderIntrospection {
+		return c.extensions
+	}
+	extensions := make([]graphql.Extension, 0, len(c.extensions)+1)
+	extensions = append(extensions, c.extensions...)
+	return append(extensions, &orderExtension{order: order})
+}
+
+// sorts names by their rank
+func sortByRank[T any](items []T, ranks map[string]int, name func(T) string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := name(items[i]), name(items[j])
+		ra, okA := ranks[a]
+		rb, okB := ranks[b]
+		switch {
+		case okA && okB:
+			return ra < rb
+		case okA != okB:
+			return okA
+		}
+		return a < b
+	})
+}
+
+// orderExtension sorts the lists that the introspection resolvers build from
+// maps on every request, the types of the schema and the fields and input
+// fields of a type. the lists are new slices so they are sorted in place once
+// resolved
+type orderExtension struct {
+	order *schemaOrder
+}
+
+func (e *orderExtension) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	return ctx
+}
+
+func (e *orderExtension) Name() string {
+	return "schemaOrder"
+}
+
+func (e *orderExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (e *orderExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (e *orderExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (e *orderExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	if info.ParentType != graphql.SchemaType && info.ParentType != graphql.TypeType {
+		return ctx, func(any, error) {}
+	}
+
+	return ctx, func(result any, err error) {
+		switch items := result.(type) {
+		case []graphql.Type:
+			if info.FieldName == "types" {
+				sortByRank(items, e.order.types, graphql.Type.Name)
+			}
+		case []*graphql.FieldDefinition:
+			if len(items) > 0 {
+				sortByRank(items, e.order.members[e.order.owners[items[0]]], func(f *graphql.FieldDefinition) string { return f.Name })
+			}
+		case []*graphql.InputObjectField:
+			if len(items) > 0 {
+				sortByRank(items, e.order.members[e.order.owners[items[0]]], func(f *graphql.InputObjectField) string { return f.PrivateName })
+			}
+		}
+	}
+}
+
+func (e *orderExtension) HasResult() bool {
+	return false
+}
+
+func (e *orderExtension) GetResult(context.Context) any {
+	return nil
+}
@@ -0,0 +1,348 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dagger/graphql"
+)
+
+var orderTypeDefs = `
+directive @zeta on FIELD_DEFINITION
+directive @alpha on FIELD_DEFINITION
+
+type Query {
+	node(id: ID!): Node
+	search(filter: Filter): [Result]
+}
+
+interface Node {
+	id: ID!
+	name: String
+	createdAt: DateTime
+}
+
+type User implements Node {
+	id: ID!
+	name: String
+	createdAt: DateTime
+}
+
+type Team implements Node {
+	id: ID!
+	name: String
+	createdAt: DateTime
+}
+
+union Result = User | Team
+
+input Filter {
+	query: String
+	limit: Int
+	after: ID
+}
+
+enum Color {
+	RED
+	GREEN
+	BLUE
+	YELLOW
+}
+`
+
+var orderResolvers = map[string]any{
+	"Node":   &InterfaceResolver{ResolveType: resolveNoType},
+	"Result": &UnionResolver{ResolveType: resolveNoType},
+}
+
+func TestDeterministicIntrospection(t *testing.T) {
+	var expected []byte
+	for i := 0; i < 10; i++ {
+		schema, err := MakeExecutableSchema(ExecutableSchema{
+			TypeDefs:           orderTypeDefs,
+			Resolvers:          orderResolvers,
+			OrderIntrospection: true,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:        schema,
+			RequestString: IntrospectionQuery,
+		})
+		if result.HasErrors() {
+			t.Errorf("failed to introspect: %v", result.Errors)
+			return
+		}
+
+		b, err := json.Marshal(result)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if expected == nil {
+			expected = b
+		} else if string(b) != string(expected) {
+			t.Errorf("build %d produced a different introspection result", i)
+			return
+		}
+	}
+}
+
+func TestDeclarationOrder(t *testing.T) {
+	schema, err := MakeExecutableSchema(ExecutableSchema{
+		TypeDefs:           orderTypeDefs,
+		Resolvers:          orderResolvers,
+		OrderIntrospection: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []string{"include", "skip", "defer", "stream", "deprecated", "hide", "zeta", "alpha"}
+	if names := directiveNames(schema); !equalNames(names, expected) {
+		t.Errorf("expected directives %v, got %v", expected, names)
+		return
+	}
+
+	order, err := introspectOrder(schema)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name     string
+		actual   []string
+		expected []string
+	}{
+		{"types", userTypeNames(order.types), []string{"ID", "String", "Int", "Float", "Boolean", "DateTime", "Query", "Node", "User", "Team", "Result", "Filter", "Color"}},
+		{"object fields", order.fields["User"], []string{"id", "name", "createdAt"}},
+		{"interface fields", order.fields["Node"], []string{"id", "name", "createdAt"}},
+		{"input fields", order.fields["Filter"], []string{"query", "limit", "after"}},
+		{"enum values", order.fields["Color"], []string{"RED", "GREEN", "BLUE", "YELLOW"}},
+		{"possible types", order.possibleTypes["Node"], []string{"User", "Team"}},
+	}
+	for _, test := range tests {
+		if !equalNames(test.actual, test.expected) {
+			t.Errorf("expected %s %v, got %v", test.name, test.expected, test.actual)
+		}
+	}
+}
+
+func TestAlphabeticalOrder(t *testing.T) {
+	schema, err := MakeExecutableSchema(ExecutableSchema{
+		TypeDefs:           orderTypeDefs,
+		Resolvers:          orderResolvers,
+		Order:              AlphabeticalOrder,
+		OrderIntrospection: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []string{"alpha", "defer", "deprecated", "hide", "include", "skip", "stream", "zeta"}
+	if names := directiveNames(schema); !equalNames(names, expected) {
+		t.Errorf("expected directives %v, got %v", expected, names)
+		return
+	}
+
+	order, err := introspectOrder(schema)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name     string
+		actual   []string
+		expected []string
+	}{
+		{"types", userTypeNames(order.types), []string{"Boolean", "Color", "DateTime", "Filter", "Float", "ID", "Int", "Node", "Query", "Result", "String", "Team", "User"}},
+		{"interface fields", order.fields["Node"], []string{"createdAt", "id", "name"}},
+		{"input fields", order.fields["Filter"], []string{"after", "limit", "query"}},
+		{"enum values", order.fields["Color"], []string{"BLUE", "GREEN", "RED", "YELLOW"}},
+		{"possible types", order.possibleTypes["Node"], []string{"Team", "User"}},
+	}
+	for _, test := range tests {
+		if !equalNames(test.actual, test.expected) {
+			t.Errorf("expected %s %v, got %v", test.name, test.expected, test.actual)
+		}
+	}
+}
+
+// without OrderIntrospection the lists the schema keeps are still ordered
+func TestDefaultOrder(t *testing.T) {
+	for _, test := range []struct {
+		order         Order
+		enumValues    []string
+		possibleTypes []string
+	}{
+		{DeclarationOrder, []string{"RED", "GREEN", "BLUE", "YELLOW"}, []string{"User", "Team"}},
+		{AlphabeticalOrder, []string{"BLUE", "GREEN", "RED", "YELLOW"}, []string{"Team", "User"}},
+	} {
+		schema, err := MakeExecutableSchema(ExecutableSchema{
+			TypeDefs:  orderTypeDefs,
+			Resolvers: orderResolvers,
+			Order:     test.order,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		order, err := introspectOrder(schema)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !equalNames(order.fields["Color"], test.enumValues) {
+			t.Errorf("order %d: expected enum values %v, got %v", test.order, test.enumValues, order.fields["Color"])
+		}
+		if !equalNames(order.possibleTypes["Node"], test.possibleTypes) {
+			t.Errorf("order %d: expected possible types %v, got %v", test.order, test.possibleTypes, order.possibleTypes["Node"])
+		}
+	}
+}
+
+// schemas that were not built by this package keep the order of the library
+func TestCodeFirstOrder(t *testing.T) {
+	color := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Color",
+		Values: graphql.EnumValueConfigMap{
+			"RED":    &graphql.EnumValueConfig{Value: "red"},
+			"GREEN":  &graphql.EnumValueConfig{Value: "green"},
+			"BLUE":   &graphql.EnumValueConfig{Value: "blue"},
+			"YELLOW": &graphql.EnumValueConfig{Value: "yellow"},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"color": &graphql.Field{Type: color}},
+		}),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	order, err := introspectOrder(schema)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []string{}
+	for _, value := range color.Values() {
+		expected = append(expected, value.Name)
+	}
+	if !equalNames(order.fields["Color"], expected) {
+		t.Errorf("expected enum values %v, got %v", expected, order.fields["Color"])
+	}
+}
+
+type introspectedOrder struct {
+	types         []string
+	fields        map[string][]string
+	possibleTypes map[string][]string
+}
+
+// introspects the order of the types and of their fields, input fields, enum
+// values and possible types
+func introspectOrder(schema graphql.Schema) (*introspectedOrder, error) {
+	result := graphql.Do(graphql.Params{
+		Schema: schema,
+		RequestString: `{ __schema { types {
+			name
+			fields { name }
+			inputFields { name }
+			enumValues { name }
+			possibleTypes { name }
+		} } }`,
+	})
+	if result.HasErrors() {
+		return nil, fmt.Errorf("failed to introspect: %v", result.Errors)
+	}
+
+	b, err := json.Marshal(result.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	type named struct {
+		Name string `json:"name"`
+	}
+	var data struct {
+		Schema struct {
+			Types []struct {
+				Name          string  `json:"name"`
+				Fields        []named `json:"fields"`
+				InputFields   []named `json:"inputFields"`
+				EnumValues    []named `json:"enumValues"`
+				PossibleTypes []named `json:"possibleTypes"`
+			} `json:"types"`
+		} `json:"__schema"`
+	}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+
+	order := &introspectedOrder{
+		fields:        map[string][]string{},
+		possibleTypes: map[string][]string{},
+	}
+	names := func(items []named) []string {
+		a := []string{}
+		for _, item := range items {
+			a = append(a, item.Name)
+		}
+		return a
+	}
+	for _, t := range data.Schema.Types {
+		order.types = append(order.types, t.Name)
+		fields := append(append(names(t.Fields), names(t.InputFields)...), names(t.EnumValues)...)
+		order.fields[t.Name] = fields
+		order.possibleTypes[t.Name] = names(t.PossibleTypes)
+	}
+	return order, nil
+}
+
+// removes the introspection types
+func userTypeNames(names []string) []string {
+	a := []string{}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "__") {
+			a = append(a, name)
+		}
+	}
+	return a
+}
+
+func directiveNames(schema graphql.Schema) []string {
+	names := []string{}
+	for _, d := range schema.Directives() {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Handler receives the payloads published to the topics of a subscription
+type Handler func(ctx context.Context, topic string, payload any)
+
+// Backend delivers published payloads to the handlers subscribed to a topic.
+// Implementations may be backed by an external broker, the default
+// MemoryBackend delivers payloads within the process
+type Backend interface {
+	// Publish calls the handlers subscribed to the topic with the payload
+	Publish(ctx context.Context, topic string, payload any) error
+
+	// Subscribe registers the handler for the topics until unsubscribe is called
+	Subscribe(topics []string, handler Handler) (unsubscribe func(), err error)
+}
+
+// MemoryBackend is an in-process backend
+type MemoryBackend struct {
+	mx       sync.RWMutex
+	nextID   uint64
+	handlers map[string]map[uint64]Handler
+}
+
+// NewMemoryBackend creates a new in-process backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		handlers: make(map[string]map[uint64]Handler),
+	}
+}
+
+// Publish calls the handlers of the topic in the calling goroutine
+func (b *MemoryBackend) Publish(ctx context.Context, topic string, payload any) error {
+	b.mx.RLock()
+	handlers := make([]Handler, 0, len(b.handlers[topic]))
+	for _, handler := range b.handlers[topic] {
+		handlers = append(handlers, handler)
+	}
+	b.mx.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, topic, payload)
+	}
+	return ctx.Err()
+}
+
+// Subscribe registers the handler for the topics
+func (b *MemoryBackend) Subscribe(topics []string, handler Handler) (func(), error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	id := b.nextID
+	b.nextID++
+
+	for _, topic := range topics {
+		handlers, ok := b.handlers[topic]
+		if !ok {
+			handlers = make(map[uint64]Handler)
+			b.handlers[topic] = handlers
+		}
+		handlers[id] = handler
+	}
+
+	return func() {
+		b.mx.Lock()
+		defer b.mx.Unlock()
+
+		for _, topic := range topics {
+			delete(b.handlers[topic], id)
+			if len(b.handlers[topic]) == 0 {
+				delete(b.handlers, topic)
+			}
+		}
+	}, nil
+}
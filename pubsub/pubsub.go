@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dagger/graphql"
+)
+
+// PubSub publishes payloads to the subscribers of a topic and turns
+// subscriptions into the channels expected by subscription resolvers
+type PubSub struct {
+	backend    Backend
+	bufferSize int
+}
+
+// Option configures a PubSub
+type Option func(*PubSub)
+
+// WithBackend replaces the in-process backend
+func WithBackend(backend Backend) Option {
+	return func(ps *PubSub) {
+		ps.backend = backend
+	}
+}
+
+// WithBufferSize sets the number of payloads buffered for each subscriber
+// before publishers block
+func WithBufferSize(size int) Option {
+	return func(ps *PubSub) {
+		ps.bufferSize = size
+	}
+}
+
+// New creates a new PubSub, by default payloads are delivered in process
+func New(options ...Option) *PubSub {
+	ps := &PubSub{
+		bufferSize: 16,
+	}
+	for _, option := range options {
+		option(ps)
+	}
+	if ps.backend == nil {
+		ps.backend = NewMemoryBackend()
+	}
+	return ps
+}
+
+// Publish sends a payload to the subscribers of a topic. Publishing blocks
+// while a subscriber's buffer is full until ctx is done
+func (ps *PubSub) Publish(ctx context.Context, topic string, payload any) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ps.backend.Publish(ctx, topic, payload)
+}
+
+// Subscribe returns a channel receiving the payloads published to the topics.
+// The channel is closed once unsubscribe is called or ctx is done, and right
+// away if the backend fails to subscribe
+func (ps *PubSub) Subscribe(ctx context.Context, topics ...string) (<-chan any, func()) {
+	sub, err := ps.subscribe(ctx, topics)
+	if err != nil {
+		ch := make(chan any)
+		close(ch)
+		return ch, func() {}
+	}
+	return sub.ch, sub.close
+}
+
+// SubscribeFn returns a subscription resolver for the topics. The
+// subscription ends with the context of the operation
+func (ps *PubSub) SubscribeFn(topics ...string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		sub, err := ps.subscribe(p.Context, topics)
+		if err != nil {
+			return nil, err
+		}
+		return sub.ch, nil
+	}
+}
+
+func (ps *PubSub) subscribe(ctx context.Context, topics []string) (*subscription, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	sub := &subscription{
+		ch:   make(chan any, ps.bufferSize),
+		done: make(chan struct{}),
+	}
+
+	unsubscribe, err := ps.backend.Subscribe(topics, sub.deliver)
+	if err != nil {
+		return nil, err
+	}
+	sub.unsubscribe = unsubscribe
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.close()
+		case <-sub.done:
+		}
+	}()
+
+	return sub, nil
+}
+
+// subscription forwards payloads from the backend to a channel
+type subscription struct {
+	ch          chan any
+	done        chan struct{}
+	unsubscribe func()
+	once        sync.Once
+	mx          sync.Mutex
+	closed      bool
+}
+
+// sends a payload to the channel, holding the lock so the channel is not
+// closed during the send
+func (s *subscription) deliver(ctx context.Context, topic string, payload any) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- payload:
+	case <-s.done:
+	case <-ctx.Done():
+	}
+}
+
+func (s *subscription) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.unsubscribe()
+
+		s.mx.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mx.Unlock()
+	})
+}
@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+func TestPubSub(t *testing.T) {
+	ps := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, _ := ps.Subscribe(ctx, "a", "b")
+	other, unsubscribe := ps.Subscribe(context.Background(), "b")
+
+	ps.Publish(context.Background(), "a", 1)
+	ps.Publish(context.Background(), "b", 2)
+	ps.Publish(context.Background(), "c", 3)
+
+	if a, b := <-ch, <-ch; a != 1 || b != 2 {
+		t.Errorf("unexpected payloads %v %v", a, b)
+		return
+	}
+	if b := <-other; b != 2 {
+		t.Errorf("unexpected payload %v", b)
+		return
+	}
+
+	cancel()
+	unsubscribe()
+	for _, c := range []<-chan any{ch, other} {
+		select {
+		case _, ok := <-c:
+			if ok {
+				t.Error("expected the channel to be closed")
+				return
+			}
+		case <-time.After(time.Second):
+			t.Error("expected the channel to be closed")
+			return
+		}
+	}
+
+	if backend := ps.backend.(*MemoryBackend); len(backend.handlers) != 0 {
+		t.Errorf("expected no handlers, got %d topics", len(backend.handlers))
+		return
+	}
+}
+
+func TestSubscribeFn(t *testing.T) {
+	ps := New()
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type Message {
+	room: String
+	text: String
+}
+
+type Query {
+	hello: String
+}
+
+type Subscription {
+	messageAdded(room: String): Message
+}`,
+		Resolvers: map[string]any{
+			"Subscription": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"messageAdded": &tools.FieldResolve{
+						Subscribe: WithMap(WithFilter(ps.SubscribeFn("messages"), func(p graphql.ResolveParams, payload any) bool {
+							return payload.(map[string]any)["room"] == p.Args["room"]
+						}), func(p graphql.ResolveParams, payload any) any {
+							return map[string]any{"messageAdded": payload}
+						}),
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							return p.Source.(map[string]any)["messageAdded"], nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := graphql.Subscribe(graphql.Params{
+		Schema:        schema,
+		RequestString: `subscription { messageAdded(room: "a") { text } }`,
+		Context:       ctx,
+	})
+
+	// wait for the subscription to be registered
+	backend := ps.backend.(*MemoryBackend)
+	for i := 0; i < 100; i++ {
+		backend.mx.RLock()
+		n := len(backend.handlers["messages"])
+		backend.mx.RUnlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ps.Publish(ctx, "messages", map[string]any{"room": "b", "text": "skipped"})
+	ps.Publish(ctx, "messages", map[string]any{"room": "a", "text": "hello"})
+
+	result := <-results
+	j, _ := json.Marshal(result)
+	if string(j) != `{"data":{"messageAdded":{"text":"hello"}}}` {
+		t.Errorf("unexpected result %s", j)
+		return
+	}
+
+	cancel()
+	for i := 0; i < 100; i++ {
+		backend.mx.RLock()
+		n := len(backend.handlers)
+		backend.mx.RUnlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected the subscription to be cleaned up")
+}
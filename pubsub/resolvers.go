@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"context"
+
+	"github.com/dagger/graphql"
+)
+
+// FilterFunc decides if a payload is sent to the subscriber
+type FilterFunc func(p graphql.ResolveParams, payload any) bool
+
+// MapFunc transforms a payload before it is resolved
+type MapFunc func(p graphql.ResolveParams, payload any) any
+
+// WithFilter wraps a subscription resolver and drops the payloads rejected
+// by fn, which receives the arguments of the subscription
+func WithFilter(subscribe graphql.FieldResolveFn, fn FilterFunc) graphql.FieldResolveFn {
+	return pipe(subscribe, func(p graphql.ResolveParams, payload any) (any, bool) {
+		return payload, fn(p, payload)
+	})
+}
+
+// WithMap wraps a subscription resolver and replaces each payload with the
+// result of fn
+func WithMap(subscribe graphql.FieldResolveFn, fn MapFunc) graphql.FieldResolveFn {
+	return pipe(subscribe, func(p graphql.ResolveParams, payload any) (any, bool) {
+		return fn(p, payload), true
+	})
+}
+
+// forwards the payloads of a subscription resolver through fn
+func pipe(subscribe graphql.FieldResolveFn, fn func(graphql.ResolveParams, any) (any, bool)) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		result, err := subscribe(p)
+		if err != nil {
+			return nil, err
+		}
+
+		// the executor only streams bidirectional channels, other results
+		// are a single payload
+		source, ok := result.(chan any)
+		if !ok {
+			if payload, ok := fn(p, result); ok {
+				return payload, nil
+			}
+			return nil, nil
+		}
+
+		ctx := p.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		out := make(chan any)
+		go func() {
+			defer close(out)
+			for payload := range source {
+				payload, ok := fn(p, payload)
+				if !ok {
+					continue
+				}
+
+				select {
+				case out <- payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return out, nil
+	}
+}
@@ -15,19 +15,21 @@ var errUnresolvedDependencies = errors.New("unresolved dependencies")
 
 // registry the registry holds all of the types
 type registry struct {
-	ctx              context.Context
-	types            map[string]graphql.Type
-	directives       map[string]*graphql.Directive
-	schema           *graphql.Schema
-	resolverMap      resolverMap
-	directiveMap     SchemaDirectiveVisitorMap
-	schemaDirectives []*ast.Directive
-	document         *ast.Document
-	extensions       []graphql.Extension
-	unresolvedDefs   []ast.Node
-	maxIterations    int
-	iterations       int
-	dependencyMap    DependencyMap
+	ctx                context.Context
+	types              map[string]graphql.Type
+	directives         map[string]*graphql.Directive
+	schema             *graphql.Schema
+	resolverMap        resolverMap
+	directiveMap       SchemaDirectiveVisitorMap
+	schemaDirectives   []*ast.Directive
+	document           *ast.Document
+	extensions         []graphql.Extension
+	graph              *DependencyGraph
+	dependencyMap      DependencyMap
+	extensionMap       map[string][]*ast.ObjectDefinition
+	imports            []*importedObject
+	order              Order
+	orderIntrospection bool
 }
 
 // newRegistry creates a new registry
@@ -55,6 +57,8 @@ func newRegistry(
 		directives: map[string]*graphql.Directive{
 			"include":    graphql.IncludeDirective,
 			"skip":       graphql.SkipDirective,
+			"defer":      DeferDirective,
+			"stream":     StreamDirective,
 			"deprecated": graphql.DeprecatedDirective,
 			"hide":       HideDirective,
 		},
@@ -63,9 +67,6 @@ func newRegistry(
 		schemaDirectives: []*ast.Directive{},
 		document:         document,
 		extensions:       extensions,
-		unresolvedDefs:   document.Definitions,
-		iterations:       0,
-		maxIterations:    len(document.Definitions),
 	}
 
 	// import each resolver to the correct location
@@ -101,11 +102,16 @@ func (c *registry) getObject(name string) (*graphql.Object, error) {
 	return nil, nil
 }
 
-// converts the type map to an array
+// converts the type map to an array in the registry order
 func (c *registry) typeArray() []graphql.Type {
-	a := make([]graphql.Type, 0)
-	for _, t := range c.types {
-		a = append(a, t)
+	names := make(map[string]bool, len(c.types))
+	for name := range c.types {
+		names[name] = true
+	}
+
+	a := make([]graphql.Type, 0, len(names))
+	for _, name := range c.orderNames(names, builtinTypeNames, false) {
+		a = append(a, c.types[name])
 	}
 	return a
 }
@@ -133,17 +139,23 @@ func (c *registry) getDirective(name string) (*graphql.Directive, error) {
 
 // gets the extensions for the current type
 func (c *registry) getExtensions(name, kind string) []*ast.ObjectDefinition {
-	extensions := []*ast.ObjectDefinition{}
-
-	for _, def := range c.document.Definitions {
-		if def.GetKind() == kinds.TypeExtensionDefinition {
-			extDef := def.(*ast.TypeExtensionDefinition).Definition
-			if extDef.Name.Value == name && extDef.GetKind() == kind {
-				extensions = append(extensions, extDef)
+	if c.extensionMap == nil {
+		c.extensionMap = map[string][]*ast.ObjectDefinition{}
+		for _, def := range c.document.Definitions {
+			if def.GetKind() == kinds.TypeExtensionDefinition {
+				extDef := def.(*ast.TypeExtensionDefinition).Definition
+				c.extensionMap[extDef.Name.Value] = append(c.extensionMap[extDef.Name.Value], extDef)
 			}
 		}
 	}
 
+	extensions := []*ast.ObjectDefinition{}
+	for _, extDef := range c.extensionMap[name] {
+		if extDef.GetKind() == kind {
+			extensions = append(extensions, extDef)
+		}
+	}
+
 	return extensions
 }
 
@@ -247,114 +259,123 @@ func (c *registry) willResolve(name string) bool {
 	if _, ok := c.types[name]; ok {
 		return true
 	}
-	for _, n := range c.unresolvedDefs {
-		if getNodeName(n) == name {
-			return true
-		}
-	}
-	return false
+	return c.graph != nil && c.graph.Kind(name) != ""
 }
 
-// iteratively resolves dependencies until all types are resolved
+// builds the document definitions in the topological order of the
+// dependency graph, only the types of a cycle are built with thunks
 func (c *registry) resolveDefinitions() error {
-	unresolved := []ast.Node{}
-
-	for len(c.unresolvedDefs) > 0 && c.iterations < c.maxIterations {
-		c.iterations = c.iterations + 1
-
-		for _, definition := range c.unresolvedDefs {
-			switch nodeKind := definition.GetKind(); nodeKind {
-			case kinds.DirectiveDefinition:
-				if err := c.buildDirectiveFromAST(definition.(*ast.DirectiveDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
-					}
-				}
-			case kinds.ScalarDefinition:
-				if err := c.buildScalarFromAST(definition.(*ast.ScalarDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
-					}
-				}
-			case kinds.EnumDefinition:
-				if err := c.buildEnumFromAST(definition.(*ast.EnumDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
-					}
-				}
-			case kinds.InputObjectDefinition:
-				if err := c.buildInputObjectFromAST(definition.(*ast.InputObjectDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
-					}
-				}
-			case kinds.ObjectDefinition:
-				if err := c.buildObjectFromAST(definition.(*ast.ObjectDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
-					}
-				}
-			case kinds.InterfaceDefinition:
-				if err := c.buildInterfaceFromAST(definition.(*ast.InterfaceDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
-					}
-				}
-			case kinds.UnionDefinition:
-				if err := c.buildUnionFromAST(definition.(*ast.UnionDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
-					}
-				}
-			case kinds.SchemaDefinition:
-				if err := c.buildSchemaFromAST(definition.(*ast.SchemaDefinition)); err != nil {
-					if err == errUnresolvedDependencies {
-						unresolved = append(unresolved, definition)
-					} else {
-						return err
+	graph, err := NewDependencyGraph(c.document)
+	if err != nil {
+		return err
+	}
+	c.graph = graph
+
+	definitions := map[string][]ast.Node{}
+	schemaDefs := []*ast.SchemaDefinition{}
+	for _, def := range c.document.Definitions {
+		if name, _ := graphNode(def); name != "" {
+			definitions[name] = append(definitions[name], def)
+		} else if schemaDef, ok := def.(*ast.SchemaDefinition); ok {
+			schemaDefs = append(schemaDefs, schemaDef)
+		}
+	}
+
+	c.dependencyMap = DependencyMap{}
+	for _, component := range graph.components() {
+		cyclic := len(component) > 1 || graph.dependsOn(component[0], component[0])
+		if cyclic {
+			members := map[string]bool{}
+			for _, name := range component {
+				members[name] = true
+			}
+			for _, name := range component {
+				deps := map[string]any{}
+				for _, dep := range graph.dependencies[name] {
+					if members[dep] {
+						deps[dep] = nil
 					}
 				}
+				c.dependencyMap[name] = deps
 			}
 		}
 
-		// check if everything has been resolved
-		if len(unresolved) == 0 {
-			return nil
+		if err := c.buildComponent(component, definitions, cyclic); err != nil {
+			return err
 		}
+	}
 
-		// prepare the next loop
-		c.unresolvedDefs = unresolved
+	if err := c.finishImports(); err != nil {
+		return err
+	}
 
-		if c.iterations < c.maxIterations {
-			unresolved = []ast.Node{}
+	for _, def := range schemaDefs {
+		if err := c.buildSchemaFromAST(def); err != nil {
+			if err == errUnresolvedDependencies {
+				return fmt.Errorf("failed to resolve the schema definition")
+			}
+			return err
 		}
 	}
 
-	if len(unresolved) > 0 {
-		names := []string{}
-		for _, n := range unresolved {
-			if name := getNodeName(n); name != "" {
+	return nil
+}
+
+// builds the definitions of a strongly connected component. Members of a
+// cycle can wait on each other, for example a union on its objects, so
+// they are retried as long as one of them can be built
+func (c *registry) buildComponent(component []string, definitions map[string][]ast.Node, cyclic bool) error {
+	pending := []ast.Node{}
+	for _, name := range component {
+		pending = append(pending, definitions[name]...)
+	}
+
+	for len(pending) > 0 {
+		unresolved := []ast.Node{}
+		for _, def := range pending {
+			if err := c.buildDefinition(def); err != nil {
+				if err != errUnresolvedDependencies {
+					return err
+				}
+				unresolved = append(unresolved, def)
+			}
+		}
+
+		if len(unresolved) == len(pending) {
+			names := []string{}
+			for _, def := range unresolved {
+				name, _ := graphNode(def)
 				names = append(names, name)
-			} else {
-				names = append(names, n.GetKind())
 			}
+			if cyclic {
+				path := c.graph.cyclePath(component)
+				return fmt.Errorf("failed to resolve type definitions %v in cycle %s", names, strings.Join(path, " -> "))
+			}
+			return fmt.Errorf("failed to resolve type definitions %v", names)
 		}
-		return fmt.Errorf("failed to resolve all type definitions: %v", names)
+		pending = unresolved
 	}
 
 	return nil
 }
+
+// builds a type or directive definition
+func (c *registry) buildDefinition(definition ast.Node) error {
+	switch def := definition.(type) {
+	case *ast.DirectiveDefinition:
+		return c.buildDirectiveFromAST(def)
+	case *ast.ScalarDefinition:
+		return c.buildScalarFromAST(def)
+	case *ast.EnumDefinition:
+		return c.buildEnumFromAST(def)
+	case *ast.InputObjectDefinition:
+		return c.buildInputObjectFromAST(def)
+	case *ast.ObjectDefinition:
+		return c.buildObjectFromAST(def)
+	case *ast.InterfaceDefinition:
+		return c.buildInterfaceFromAST(def)
+	case *ast.UnionDefinition:
+		return c.buildUnionFromAST(def)
+	}
+	return nil
+}
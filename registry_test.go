@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// generates type definitions with n types that reference types declared
+// after them, every tenth object is part of a cycle
+func generateTypeDefs(n int) string {
+	var b strings.Builder
+	b.WriteString("type Query {\n\tnode(id: ID!): Node\n\tfirst: Object2\n}\n\n")
+	b.WriteString("interface Node {\n\tid: ID!\n}\n\n")
+
+	for i := 0; i < n; i++ {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "enum Enum%d {\n\tA\n\tB\n\tC\n}\n\n", i)
+		case 1:
+			fmt.Fprintf(&b, "input Input%d {\n\tvalue: Enum%d\n\tnested: [Input%d]\n}\n\n", i, i-1, i)
+		case 2:
+			fmt.Fprintf(&b, "type Object%d implements Node {\n\tid: ID!\n\tvalue: Enum%d\n", i, i-2)
+			if i+4 < n {
+				fmt.Fprintf(&b, "\tnext(input: Input%d): Object%d\n", i-1, i+4)
+			}
+			if i >= 4 && i/4%10 == 0 {
+				fmt.Fprintf(&b, "\tprevious: Object%d\n", i-4)
+			}
+			b.WriteString("}\n\n")
+		case 3:
+			fmt.Fprintf(&b, "union Union%d = Object%d\n\n", i, i-1)
+		}
+	}
+	return b.String()
+}
+
+// resolvers for the interface and unions of generateTypeDefs
+func generateResolvers(n int) map[string]any {
+	resolvers := map[string]any{
+		"Node": &InterfaceResolver{ResolveType: resolveNoType},
+	}
+	for i := 3; i < n; i += 4 {
+		resolvers[fmt.Sprintf("Union%d", i)] = &UnionResolver{ResolveType: resolveNoType}
+	}
+	return resolvers
+}
+
+func TestResolveDefinitions(t *testing.T) {
+	typeDefs := generateTypeDefs(200)
+	config := ExecutableSchema{TypeDefs: typeDefs}
+	document, err := config.ConcatenateTypeDefs()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	r, err := newRegistry(nil, generateResolvers(200), nil, nil, document)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := r.resolveDefinitions(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// only the types of cycles use thunks
+	for _, name := range []string{"Input1", "Object38", "Object42"} {
+		if _, ok := r.dependencyMap[name]; !ok {
+			t.Errorf("expected %s to be built with thunks", name)
+		}
+	}
+	for _, name := range []string{"Object2", "Object46", "Enum0", "Union3"} {
+		if _, ok := r.dependencyMap[name]; ok {
+			t.Errorf("expected %s to be built without thunks", name)
+		}
+	}
+}
+
+func TestResolveDefinitionsErrors(t *testing.T) {
+	tests := []struct {
+		typeDefs string
+		expected string
+	}{
+		{
+			typeDefs: "type Query {\n\tfoo: Foo\n}",
+			expected: `no definition found for type "Foo"`,
+		},
+		{
+			typeDefs: "directive @a(arg: A) on INPUT_OBJECT\n\ninput A @a {\n\tname: String\n}\n\ntype Query {\n\tfoo(a: A): String\n}",
+			expected: "failed to resolve type definitions [@a A] in cycle @a -> A -> @a",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := MakeExecutableSchema(ExecutableSchema{
+			TypeDefs: test.typeDefs,
+			SchemaDirectives: SchemaDirectiveVisitorMap{
+				"a": &SchemaDirectiveVisitor{},
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected error %q, got %v", test.expected, err)
+		}
+	}
+}
+
+func BenchmarkMakeExecutableSchema(b *testing.B) {
+	for _, n := range []int{1000, 5000, 20000} {
+		typeDefs := generateTypeDefs(n)
+		resolvers := generateResolvers(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := MakeExecutableSchema(ExecutableSchema{
+					TypeDefs:  typeDefs,
+					Resolvers: resolvers,
+				}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -19,6 +19,37 @@ func MakeExecutableSchema(config ExecutableSchema) (graphql.Schema, error) {
 	return config.Make(context.Background())
 }
 
+// BuildSchema builds a schema from TypeDefs alone for tools that inspect a
+// schema without executing it. Unions and interfaces resolve to no type and
+// introspection is listed in declaration order
+func BuildSchema(typeDefs any) (graphql.Schema, error) {
+	config := ExecutableSchema{
+		TypeDefs:           typeDefs,
+		Resolvers:          map[string]any{},
+		OrderIntrospection: true,
+	}
+
+	document, err := config.ConcatenateTypeDefs()
+	if err != nil {
+		return graphql.Schema{}, err
+	}
+
+	for _, def := range document.Definitions {
+		switch d := def.(type) {
+		case *ast.UnionDefinition:
+			config.Resolvers[d.Name.Value] = &UnionResolver{ResolveType: resolveNoType}
+		case *ast.InterfaceDefinition:
+			config.Resolvers[d.Name.Value] = &InterfaceResolver{ResolveType: resolveNoType}
+		}
+	}
+
+	return MakeExecutableSchema(config)
+}
+
+func resolveNoType(p graphql.ResolveTypeParams) *graphql.Object {
+	return nil
+}
+
 // MakeExecutableSchemaWithContext make a schema and supply a context
 func MakeExecutableSchemaWithContext(ctx context.Context, config ExecutableSchema) (graphql.Schema, error) {
 	return config.Make(ctx)
@@ -28,12 +59,14 @@ func MakeExecutableSchemaWithContext(ctx context.Context, config ExecutableSchem
 // this attempts to provide similar functionality to Apollo graphql-tools
 // https://www.apollographql.com/docs/graphql-tools/generate-schema
 type ExecutableSchema struct {
-	document         *ast.Document
-	TypeDefs         any                       // a string, []string, or func() []string
-	Resolvers        map[string]any            // a map of Resolver, Directive, Scalar, Enum, Object, InputObject, Union, or Interface
-	SchemaDirectives SchemaDirectiveVisitorMap // Map of SchemaDirectiveVisitor
-	Extensions       []graphql.Extension       // GraphQL extensions
-	Debug            bool                      // Prints debug messages during compile
+	document           *ast.Document
+	TypeDefs           any                       // a string, []string, or func() []string
+	Resolvers          map[string]any            // a map of Resolver, Directive, Scalar, Enum, Object, InputObject, Union, or Interface
+	SchemaDirectives   SchemaDirectiveVisitorMap // Map of SchemaDirectiveVisitor
+	Extensions         []graphql.Extension       // GraphQL extensions
+	Debug              bool                      // Prints debug messages during compile
+	Order              Order                     // Order of the types and directives, DeclarationOrder by default
+	OrderIntrospection bool                      // Lists introspected types and fields in Order with an extension that runs for every field
 }
 
 // Document returns the document
@@ -43,6 +76,11 @@ func (c *ExecutableSchema) Document() *ast.Document {
 
 // Make creates a graphql schema config, this struct maintains intact the types and does not require the use of a non empty Query
 func (c *ExecutableSchema) Make(ctx context.Context) (graphql.Schema, error) {
+	return c.make(ctx, nil)
+}
+
+// makes the schema, extending the types of base when it is not nil
+func (c *ExecutableSchema) make(ctx context.Context, base *graphql.Schema) (graphql.Schema, error) {
 	// combine the TypeDefs
 	document, err := c.ConcatenateTypeDefs()
 	if err != nil {
@@ -56,9 +94,14 @@ func (c *ExecutableSchema) Make(ctx context.Context) (graphql.Schema, error) {
 	if err != nil {
 		return graphql.Schema{}, err
 	}
+	registry.order = c.Order
+	registry.orderIntrospection = c.OrderIntrospection
 
-	if registry.dependencyMap, err = registry.IdentifyDependencies(); err != nil {
-		return graphql.Schema{}, err
+	// import the types of the base schema
+	if base != nil {
+		if err := registry.importSchema(base); err != nil {
+			return graphql.Schema{}, err
+		}
 	}
 
 	// resolve the document definitions
@@ -71,29 +114,46 @@ func (c *ExecutableSchema) Make(ctx context.Context) (graphql.Schema, error) {
 		return *registry.schema, nil
 	}
 
-	// otherwise build a schema from default object names
-	query, err := registry.getObject(DefaultRootQueryName)
+	// otherwise build a schema from the root names of the base schema or the
+	// default object names
+	queryName, mutationName, subscriptionName := DefaultRootQueryName, DefaultRootMutationName, DefaultRootSubscriptionName
+	if base != nil {
+		if t := base.QueryType(); t != nil {
+			queryName = t.Name()
+		}
+		if t := base.MutationType(); t != nil {
+			mutationName = t.Name()
+		}
+		if t := base.SubscriptionType(); t != nil {
+			subscriptionName = t.Name()
+		}
+	}
+
+	query, err := registry.getObject(queryName)
 	if err != nil {
 		return graphql.Schema{}, err
 	}
 
-	mutation, _ := registry.getObject(DefaultRootMutationName)
-	subscription, _ := registry.getObject(DefaultRootSubscriptionName)
+	mutation, _ := registry.getObject(mutationName)
+	subscription, _ := registry.getObject(subscriptionName)
 
 	// create a new schema config
+	types := registry.typeArray()
+	order := registry.schemaOrder(types)
 	schemaConfig := &graphql.SchemaConfig{
 		Query:        query,
 		Mutation:     mutation,
 		Subscription: subscription,
-		Types:        registry.typeArray(),
+		Types:        types,
 		Directives:   registry.directiveArray(),
-		Extensions:   c.Extensions,
+		Extensions:   registry.schemaExtensions(order),
 	}
 
 	schema, err := graphql.NewSchema(*schemaConfig)
 	if err != nil {
 		return graphql.Schema{}, err
 	}
+	registry.orderSchema(&schema, order)
 
 	// create a new schema
 	return schema, nil
@@ -101,10 +161,12 @@ func (c *ExecutableSchema) Make(ctx context.Context) (graphql.Schema, error) {
 
 // build a schema from an ast
 func (c *registry) buildSchemaFromAST(definition *ast.SchemaDefinition) error {
+	types := c.typeArray()
+	order := c.schemaOrder(types)
 	schemaConfig := &graphql.SchemaConfig{
-		Types:      c.typeArray(),
+		Types:      types,
 		Directives: c.directiveArray(),
-		Extensions: c.extensions,
+		Extensions: c.schemaExtensions(order),
 	}
 
 	// add operations
@@ -145,6 +207,7 @@ func (c *registry) buildSchemaFromAST(definition *ast.SchemaDefinition) error {
 	if err != nil {
 		return err
 	}
+	c.orderSchema(&schema, order)
 
 	c.schema = &schema
 	return nil
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dagger/graphql-go-tools/server/graphqlws"
+	"github.com/gorilla/websocket"
+)
+
+// ConnectionInfo describes an open WebSocket connection
+type ConnectionInfo struct {
+	ID         string          `json:"id"`
+	RemoteAddr string          `json:"remoteAddr"`
+	Identity   string          `json:"identity,omitempty"`
+	StartedAt  time.Time       `json:"startedAt"`
+	Operations int             `json:"operations"`
+	Details    []OperationInfo `json:"details,omitempty"`
+}
+
+// OperationInfo describes an active operation of a WebSocket connection
+type OperationInfo struct {
+	ID            string    `json:"id"`
+	ConnectionID  string    `json:"connectionId"`
+	OperationName string    `json:"operationName,omitempty"`
+	QueryHash     string    `json:"queryHash"`
+	StartedAt     time.Time `json:"startedAt"`
+	MessagesSent  int64     `json:"messagesSent"`
+}
+
+// a WebSocket connection tracked by the server
+type wsConnection struct {
+	conn       graphqlws.Connection
+	remoteAddr string
+	startedAt  time.Time
+}
+
+// hashes a query so operations can be identified without exposing them
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Connections returns a snapshot of the open WebSocket connections
+func (s *Server) Connections() []ConnectionInfo {
+	s.connMutex.Lock()
+	connections := make([]*wsConnection, 0, len(s.connections))
+	for _, wsConn := range s.connections {
+		connections = append(connections, wsConn)
+	}
+	s.connMutex.Unlock()
+
+	infos := make([]ConnectionInfo, len(connections))
+	for i, wsConn := range connections {
+		infos[i] = s.connectionInfo(wsConn)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt.Before(infos[j].StartedAt)
+	})
+	return infos
+}
+
+// Operations returns a snapshot of the active operations of a connection
+func (s *Server) Operations(connID string) []OperationInfo {
+	return s.mgr.Operations(connID)
+}
+
+// StopOperation stops an operation and sends complete to the client,
+// returns false if the operation was not found
+func (s *Server) StopOperation(connID, opID string) bool {
+	wsConn := s.getConnection(connID)
+	if wsConn == nil || !s.mgr.Del(connID, opID) {
+		return false
+	}
+	wsConn.conn.SendComplete(opID)
+	return true
+}
+
+// KickConnection closes a connection with a policy violation close code,
+// returns false if the connection was not found
+func (s *Server) KickConnection(connID string) bool {
+	wsConn := s.getConnection(connID)
+	if wsConn == nil {
+		return false
+	}
+	wsConn.conn.Close(websocket.ClosePolicyViolation, "connection closed by an administrator")
+	return true
+}
+
+func (s *Server) getConnection(connID string) *wsConnection {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	return s.connections[connID]
+}
+
+func (s *Server) connectionInfo(wsConn *wsConnection) ConnectionInfo {
+	info := ConnectionInfo{
+		ID:         wsConn.conn.ID(),
+		RemoteAddr: wsConn.remoteAddr,
+		StartedAt:  wsConn.startedAt,
+		Operations: s.mgr.Count(wsConn.conn.ID()),
+	}
+	if s.options.WS != nil && s.options.WS.IdentityFunc != nil {
+		info.Identity = s.options.WS.IdentityFunc(wsConn.conn.Context())
+	}
+	return info
+}
+
+// AdminHandler serves the connections and operations as JSON. It is not
+// mounted by ServeHTTP, mount it behind your own authentication with
+// http.StripPrefix:
+//
+//	GET    /connections                      lists the connections
+//	GET    /connections/{id}                 gets a connection and its operations
+//	DELETE /connections/{id}                 kicks a connection
+//	DELETE /connections/{id}/operations/{op} stops an operation
+func (s *Server) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+		if parts[0] != "connections" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			s.writeAdminJSON(w, http.StatusOK, s.Connections())
+
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			wsConn := s.getConnection(parts[1])
+			if wsConn == nil {
+				http.NotFound(w, r)
+				return
+			}
+			info := s.connectionInfo(wsConn)
+			info.Details = s.Operations(parts[1])
+			s.writeAdminJSON(w, http.StatusOK, info)
+
+		case len(parts) == 2 && r.Method == http.MethodDelete:
+			if !s.KickConnection(parts[1]) {
+				http.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case len(parts) == 4 && parts[2] == "operations" && r.Method == http.MethodDelete:
+			if !s.StopOperation(parts[1], parts[3]) {
+				http.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case len(parts) <= 2 || (len(parts) == 4 && parts[2] == "operations"):
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func (s *Server) writeAdminJSON(w http.ResponseWriter, statusCode int, v any) {
+	var buff []byte
+	if s.options.Pretty {
+		buff, _ = json.MarshalIndent(v, "", "\t")
+	} else {
+		buff, _ = json.Marshal(v)
+	}
+
+	w.Header().Set("Content-Type", ContentTypeJSON+"; charset=utf-8")
+	w.WriteHeader(statusCode)
+	w.Write(buff)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dagger/graphql-go-tools/server/graphqlws"
+	"github.com/gorilla/websocket"
+)
+
+func TestAdmin(t *testing.T) {
+	s, _, ws := testWSServer(t, &Options{
+		WS: &WSOptions{
+			AuthenticateFunc: func(data map[string]any, conn graphqlws.Connection) (context.Context, error) {
+				return context.WithValue(context.Background(), ConnKey, "alice"), nil
+			},
+			IdentityFunc: func(ctx context.Context) string {
+				identity, _ := ctx.Value(ConnKey).(string)
+				return identity
+			},
+		},
+	})
+	startTicks(t, s, ws, "1")
+	startTicks(t, s, ws, "2")
+
+	admin := s.AdminHandler()
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	var connections []ConnectionInfo
+	if w := request(http.MethodGet, "/connections"); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &connections) != nil {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+		return
+	}
+	if len(connections) != 1 || connections[0].Identity != "alice" || connections[0].Operations != 2 {
+		t.Errorf("unexpected connections %+v", connections)
+		return
+	}
+
+	id := connections[0].ID
+	var info ConnectionInfo
+	if w := request(http.MethodGet, "/connections/"+id); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &info) != nil {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+		return
+	}
+	if len(info.Details) != 2 || info.Details[0].OperationName != "Ticks" || info.Details[0].QueryHash != queryHash("subscription Ticks { ticks }") {
+		t.Errorf("unexpected operations %+v", info.Details)
+		return
+	}
+
+	if w := request(http.MethodDelete, "/connections/"+id+"/operations/1"); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+		return
+	}
+	var msg graphqlws.OperationMessage
+	if err := ws.ReadJSON(&msg); err != nil || msg.Type != "complete" || msg.ID != "1" {
+		t.Errorf("expected complete, got %v %v", msg, err)
+		return
+	}
+	if operations := s.Operations(id); len(operations) != 1 || operations[0].ID != "2" {
+		t.Errorf("unexpected operations %+v", operations)
+		return
+	}
+
+	if w := request(http.MethodDelete, "/connections/"+id); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+		return
+	}
+	if err := ws.ReadJSON(&msg); err != nil || msg.Type != "complete" || msg.ID != "2" {
+		t.Errorf("expected complete, got %v %v", msg, err)
+		return
+	}
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("expected policy violation, got %v", err)
+		return
+	}
+
+	if w := request(http.MethodGet, "/connections/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("expected not found, got %d", w.Code)
+		return
+	}
+}
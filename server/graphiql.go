@@ -44,7 +44,7 @@ type graphiqlData struct {
 }
 
 // renderGraphiQL renders the GraphiQL GUI
-func renderGraphiQL(config *GraphiQLOptions, w http.ResponseWriter, r *http.Request, params graphql.Params) {
+func renderGraphiQL(config *GraphiQLOptions, w http.ResponseWriter, r *http.Request, params graphql.Params, result *graphql.Result) {
 	t := template.New("GraphiQL")
 	t, err := t.Parse(graphiqlTemplate)
 	if err != nil {
@@ -68,7 +68,7 @@ func renderGraphiQL(config *GraphiQLOptions, w http.ResponseWriter, r *http.Requ
 	if params.RequestString == "" {
 		resString = ""
 	} else {
-		result, err := json.MarshalIndent(graphql.Do(params), "", "  ")
+		result, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -3,75 +3,110 @@ package server
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
+	"time"
 
-	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/executor"
 	"github.com/dagger/graphql-go-tools/server/graphqlws"
+	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql-go-tools/trusted"
 	"github.com/gorilla/websocket"
 )
 
-func (s *Server) newGraphQLWSConnection(ctx context.Context, r *http.Request, ws *websocket.Conn) {
+func (s *Server) newGraphQLWSConnection(ctx context.Context, r *http.Request, ws *websocket.Conn, log logger.Logger) {
+	options := s.options.WS
+	if options == nil {
+		options = &WSOptions{}
+	}
+
 	// Establish a GraphQL WebSocket connection
-	graphqlws.NewConnection(ws, graphqlws.ConnectionConfig{
-		Authenticate: s.options.WS.AuthenticateFunc,
-		Logger:       s.log,
+	conn := graphqlws.NewConnection(ws, graphqlws.ConnectionConfig{
+		Authenticate: options.AuthenticateFunc,
+		FormatError:  s.options.FormatErrorFunc,
+		Logger:       log,
+		KeepAlive:    options.KeepAlive,
+		PingInterval: options.PingInterval,
+		PongTimeout:  options.PongTimeout,
+		InitTimeout:  options.InitTimeout,
+		IdleTimeout:  options.IdleTimeout,
 		EventHandlers: graphqlws.ConnectionEventHandlers{
 			Close: func(conn graphqlws.Connection) {
-				s.log.Debugf("closing websocket: %s", conn.ID())
+				log.Debug("closing websocket", logger.ConnectionIDKey, conn.ID())
 				s.mgr.DelConn(conn.ID())
+				s.removeConnection(conn)
 			},
 			StartOperation: func(
 				conn graphqlws.Connection,
 				opID string,
 				data *graphqlws.StartMessagePayload,
 			) []error {
-				s.log.Debugf("start operations %s on connection %s", opID, conn.ID())
+				log := log.With(
+					logger.ConnectionIDKey, conn.ID(),
+					logger.OperationIDKey, opID,
+					logger.OperationNameKey, data.OperationName,
+				)
+				log.Debug("start operation")
 
 				rootObject := map[string]any{}
 				if s.options.RootValueFunc != nil {
 					rootObject = s.options.RootValueFunc(ctx, r)
 				}
 				ctx, cancelFunc := context.WithCancel(context.WithValue(context.Background(), ConnKey, conn))
-				resultChannel := graphql.Subscribe(graphql.Params{
-					Schema:         s.schema,
-					RequestString:  data.Query,
-					VariableValues: data.Variables,
-					OperationName:  data.OperationName,
-					Context:        ctx,
-					RootObject:     rootObject,
+				ctx = logger.WithContext(ctx, log)
+				resultChannel := s.executor.Subscribe(&executor.Request{
+					Query:         data.Query,
+					Variables:     data.Variables,
+					OperationName: data.OperationName,
+					DocumentID:    trusted.DocumentID(data.DocumentID, data.Extensions),
+					Extensions:    data.Extensions,
+					RootObject:    rootObject,
+					Context:       ctx,
+					Transport:     executor.TransportWebSocket,
+					HTTPRequest:   r,
 				})
 
-				s.mgr.Add(&ResultChan{
-					ch:         resultChannel,
-					cancelFunc: cancelFunc,
-					ctx:        ctx,
-					cid:        conn.ID(),
-					oid:        opID,
-				})
+				rc := &ResultChan{
+					ch:            resultChannel,
+					cancelFunc:    cancelFunc,
+					ctx:           ctx,
+					cid:           conn.ID(),
+					oid:           opID,
+					operationName: data.OperationName,
+					queryHash:     queryHash(data.Query),
+					startedAt:     time.Now(),
+				}
+				s.mgr.Add(rc)
 
+				s.wg.Add(1)
 				go func() {
+					defer s.wg.Done()
 					for {
 						select {
 						case <-ctx.Done():
 							s.mgr.Del(conn.ID(), opID)
+
+							// Wait for the executor to stop sending results
+							for range resultChannel {
+							}
 							return
 						case res, more := <-resultChannel:
+							// The operation finished on the server, tell the
+							// client and unregister it
 							if !more {
+								conn.SendComplete(opID)
+								s.mgr.Del(conn.ID(), opID)
 								return
 							}
 
-							errs := []error{}
-
-							if res.HasErrors() {
-								for _, err := range res.Errors {
-									s.log.Debugf("subscription_error: %v", err)
-									errs = append(errs, err.OriginalError())
-								}
+							for _, err := range res.Errors {
+								log.Debug("subscription error", "error", err)
 							}
 
 							conn.SendData(opID, &graphqlws.DataMessagePayload{
 								Data:   res.Data,
-								Errors: errs,
+								Errors: res.Errors,
 							})
+							atomic.AddInt64(&rc.messagesSent, 1)
 						}
 					}
 				}()
@@ -79,9 +114,11 @@ func (s *Server) newGraphQLWSConnection(ctx context.Context, r *http.Request, ws
 				return nil
 			},
 			StopOperation: func(conn graphqlws.Connection, opID string) {
-				s.log.Debugf("stop operation %s on connection %s", opID, conn.ID())
+				log.Debug("stop operation", logger.ConnectionIDKey, conn.ID(), logger.OperationIDKey, opID)
 				s.mgr.Del(conn.ID(), opID)
 			},
 		},
 	})
+
+	s.addConnection(conn, r)
 }
@@ -3,12 +3,13 @@ package graphqlws
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql/gqlerrors"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
@@ -32,6 +33,9 @@ const (
 
 	// Timeout for outgoing messages
 	writeTimeout = 10 * time.Second
+
+	// Close code for connections that are not initialised in time
+	closeInitTimeout = 4408
 )
 
 // InitMessagePayload defines the parameters of a connection
@@ -47,12 +51,14 @@ type StartMessagePayload struct {
 	Query         string         `json:"query"`
 	Variables     map[string]any `json:"variables"`
 	OperationName string         `json:"operationName"`
+	DocumentID    string         `json:"documentId,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
 }
 
 // DataMessagePayload defines the result data of an operation.
 type DataMessagePayload struct {
-	Data   any     `json:"data"`
-	Errors []error `json:"errors"`
+	Data   any                        `json:"data"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
 }
 
 // OperationMessage represents a GraphQL WebSocket message.
@@ -99,9 +105,36 @@ type ConnectionEventHandlers struct {
 // ConnectionConfig defines the configuration parameters of a
 // GraphQL WebSocket connection.
 type ConnectionConfig struct {
-	Logger        logger.Logger
+	// Logger is a logger.Logger or a printf style logger.PrintfLogger of
+	// earlier versions, see logger.From
+	Logger        any
 	Authenticate  AuthenticateFunc
 	EventHandlers ConnectionEventHandlers
+
+	// FormatError formats the errors sent to the client, defaults to
+	// gqlerrors.FormatError
+	FormatError func(error) gqlerrors.FormattedError
+
+	// KeepAlive sends a ka message at this interval once the connection
+	// has been acknowledged
+	KeepAlive time.Duration
+
+	// PingInterval sends a WebSocket ping at this interval. Peers that send
+	// neither a pong nor a message within PingInterval plus PongTimeout are
+	// disconnected
+	PingInterval time.Duration
+
+	// PongTimeout is the time allowed for a pong to arrive, defaults to
+	// PingInterval
+	PongTimeout time.Duration
+
+	// InitTimeout closes connections that have not been acknowledged
+	// after a connection_init message within this duration
+	InitTimeout time.Duration
+
+	// IdleTimeout closes connections that have had no active operations
+	// for this duration
+	IdleTimeout time.Duration
 }
 
 // Connection is an interface to represent GraphQL WebSocket connections.
@@ -122,6 +155,20 @@ type Connection interface {
 
 	// SendError sends an error to the client.
 	SendError(error)
+
+	// SendComplete tells the client that an operation has finished. No
+	// more data is sent for the operation afterwards.
+	SendComplete(string)
+
+	// Operations returns the IDs of the active operations.
+	Operations() []string
+
+	// Close sends complete for the active operations and closes the
+	// connection with a close frame.
+	Close(code int, reason string)
+
+	// Done is closed once the connection has been closed.
+	Done() <-chan struct{}
 }
 
 /**
@@ -137,6 +184,14 @@ type connection struct {
 	closeMutex *sync.Mutex
 	closed     bool
 	context    context.Context
+	done       chan struct{}
+	closing    chan []byte
+
+	// guards the context and the keep-alive state below
+	stateMutex   sync.Mutex
+	acknowledged bool
+	operations   map[string]struct{}
+	idleSince    time.Time
 }
 
 func operationMessageForType(messageType string) OperationMessage {
@@ -154,14 +209,19 @@ func NewConnection(ws *websocket.Conn, config ConnectionConfig) Connection {
 	conn.ws = ws
 	conn.context = context.Background()
 	conn.config = config
-	conn.logger = config.Logger
+	conn.logger = logger.From(config.Logger).With(logger.ConnectionIDKey, conn.id)
 	conn.closed = false
 	conn.closeMutex = &sync.Mutex{}
 	conn.outgoing = make(chan OperationMessage)
+	conn.done = make(chan struct{})
+	conn.closing = make(chan []byte, 1)
+	conn.operations = make(map[string]struct{})
+	conn.idleSince = time.Now()
 
 	go conn.writeLoop()
 	go conn.readLoop()
-	conn.logger.Infof("Created connection")
+	go conn.keepAlive()
+	conn.logger.Info("created connection")
 
 	return conn
 }
@@ -171,27 +231,82 @@ func (conn *connection) ID() string {
 }
 
 func (conn *connection) Context() context.Context {
+	conn.stateMutex.Lock()
+	defer conn.stateMutex.Unlock()
 	return conn.context
 }
 
+func (conn *connection) setContext(ctx context.Context) {
+	conn.stateMutex.Lock()
+	conn.context = ctx
+	conn.stateMutex.Unlock()
+}
+
 func (conn *connection) WS() *websocket.Conn {
 	return conn.ws
 }
 
+func (conn *connection) Done() <-chan struct{} {
+	return conn.done
+}
+
 func (conn *connection) SendData(opID string, data *DataMessagePayload) {
 	msg := operationMessageForType(gqlData)
 	msg.ID = opID
 	msg.Payload = data
-	conn.closeMutex.Lock()
-	if !conn.closed {
-		conn.outgoing <- msg
+	conn.sendOperation(msg, false)
+}
+
+func (conn *connection) SendComplete(opID string) {
+	msg := operationMessageForType(gqlComplete)
+	msg.ID = opID
+	conn.sendOperation(msg, true)
+}
+
+func (conn *connection) Operations() []string {
+	conn.stateMutex.Lock()
+	defer conn.stateMutex.Unlock()
+
+	ids := make([]string, 0, len(conn.operations))
+	for id := range conn.operations {
+		ids = append(ids, id)
 	}
-	conn.closeMutex.Unlock()
+	sort.Strings(ids)
+	return ids
 }
 
 func (conn *connection) SendError(err error) {
 	msg := operationMessageForType(gqlError)
-	msg.Payload = err.Error()
+	msg.Payload = conn.formatError(err)
+	conn.send(msg)
+}
+
+// formats an error sent to the client. protocol errors are created as
+// gqlerrors.FormattedError so formatters that mask internal errors keep them
+func (conn *connection) formatError(err error) gqlerrors.FormattedError {
+	if conn.config.FormatError != nil {
+		return conn.config.FormatError(err)
+	}
+	return gqlerrors.FormatError(err)
+}
+
+func (conn *connection) formatErrors(errs []error) []gqlerrors.FormattedError {
+	formatted := make([]gqlerrors.FormattedError, len(errs))
+	for i, err := range errs {
+		formatted[i] = conn.formatError(err)
+	}
+	return formatted
+}
+
+// sends a connection_error message, which is not tied to an operation
+func (conn *connection) sendConnectionError(err error) {
+	msg := operationMessageForType(gqlConnectionError)
+	msg.Payload = conn.formatError(err)
+	conn.send(msg)
+}
+
+// sends a message unless the connection has been closed
+func (conn *connection) send(msg OperationMessage) {
 	conn.closeMutex.Lock()
 	if !conn.closed {
 		conn.outgoing <- msg
@@ -199,20 +314,25 @@ func (conn *connection) SendError(err error) {
 	conn.closeMutex.Unlock()
 }
 
-func (conn *connection) sendOperationErrors(opID string, errs []error) {
-	if conn.closed {
+// sends a message for an active operation, dropping it once the operation
+// has finished. finish ends the operation with this message. Holding the
+// close mutex while sending keeps the messages of an operation in order
+func (conn *connection) sendOperation(msg OperationMessage, finish bool) {
+	conn.closeMutex.Lock()
+	defer conn.closeMutex.Unlock()
+
+	if conn.closed || !conn.finishOperation(msg.ID, finish) {
 		return
 	}
+	conn.outgoing <- msg
+}
 
+// errors end an operation, no complete message follows
+func (conn *connection) sendOperationErrors(opID string, errs []error) {
 	msg := operationMessageForType(gqlError)
 	msg.ID = opID
-	msg.Payload = errs
-	conn.closeMutex.Lock()
-	if !conn.closed {
-		conn.outgoing <- msg
-	}
-
-	conn.closeMutex.Unlock()
+	msg.Payload = conn.formatErrors(errs)
+	conn.sendOperation(msg, true)
 }
 
 func (conn *connection) close() {
@@ -220,14 +340,23 @@ func (conn *connection) close() {
 	conn.closeMutex.Lock()
 	conn.closed = true
 	close(conn.outgoing)
+	close(conn.done)
 	conn.closeMutex.Unlock()
 
+	// Operations end with the connection; the event handlers can still
+	// list them before they are cleared
+	defer func() {
+		conn.stateMutex.Lock()
+		conn.operations = make(map[string]struct{})
+		conn.stateMutex.Unlock()
+	}()
+
 	// Notify event handlers
 	if conn.config.EventHandlers.Close != nil {
 		conn.config.EventHandlers.Close(conn)
 	}
 
-	conn.logger.Infof("closed connection")
+	conn.logger.Info("closed connection")
 }
 
 func (conn *connection) writeLoop() {
@@ -237,31 +366,200 @@ func (conn *connection) writeLoop() {
 	defer conn.ws.Close()
 
 	for {
-		msg, ok := <-conn.outgoing
+		var msg OperationMessage
+		var ok bool
+
+		select {
+		case msg, ok = <-conn.outgoing:
+		case data := <-conn.closing:
+			conn.ws.WriteControl(websocket.CloseMessage, data, time.Now().Add(writeTimeout))
+			conn.ws.Close()
+			for range conn.outgoing {
+			}
+			return
+		}
+
 		// Close the write loop when the outgoing messages channel is closed;
 		// this will close the connection
 		if !ok {
 			return
 		}
 
-		// conn.logger.Debugf("send message: %s", msg.String())
+		// conn.logger.Debug("send message", "message", msg.String())
 		conn.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
 
 		// Send the message to the client; if this times out, the WebSocket
 		// connection will be corrupt, hence we need to close the write loop
 		// and the connection immediately
 		if err := conn.ws.WriteJSON(msg); err != nil {
-			conn.logger.Warnf("sending message failed: %s", err)
+			conn.logger.Warn("sending message failed", "error", err)
+			conn.ws.Close()
+
+			// Keep draining the outgoing messages so senders do not block
+			// until the read loop closes the connection
+			for range conn.outgoing {
+			}
 			return
 		}
 	}
 }
 
+// extends the read deadline when pings are enabled
+func (conn *connection) extendReadDeadline() {
+	if conn.config.PingInterval <= 0 {
+		return
+	}
+
+	pongTimeout := conn.config.PongTimeout
+	if pongTimeout <= 0 {
+		pongTimeout = conn.config.PingInterval
+	}
+	conn.ws.SetReadDeadline(time.Now().Add(conn.config.PingInterval + pongTimeout))
+}
+
+// Close completes the active operations and closes the WebSocket connection
+// with a close frame, the read loop then closes the connection and notifies
+// the event handlers
+func (conn *connection) Close(code int, reason string) {
+	conn.logger.Info("closing connection", "code", code, "reason", reason)
+	for _, opID := range conn.Operations() {
+		conn.SendComplete(opID)
+	}
+
+	// The write loop sends the close frame after the queued messages
+	select {
+	case conn.closing <- websocket.FormatCloseMessage(code, reason):
+	default:
+	}
+}
+
+func (conn *connection) acknowledge() {
+	conn.stateMutex.Lock()
+	conn.acknowledged = true
+	conn.stateMutex.Unlock()
+	conn.send(operationMessageForType(gqlConnectionAck))
+}
+
+func (conn *connection) isAcknowledged() bool {
+	conn.stateMutex.Lock()
+	defer conn.stateMutex.Unlock()
+	return conn.acknowledged
+}
+
+// registers an operation, returns false if the ID is already in use
+func (conn *connection) startOperation(opID string) bool {
+	conn.stateMutex.Lock()
+	defer conn.stateMutex.Unlock()
+
+	if _, ok := conn.operations[opID]; ok {
+		return false
+	}
+	conn.operations[opID] = struct{}{}
+	return true
+}
+
+// reports whether an operation is active and unregisters it if finish is set
+func (conn *connection) finishOperation(opID string, finish bool) bool {
+	conn.stateMutex.Lock()
+	defer conn.stateMutex.Unlock()
+
+	if _, ok := conn.operations[opID]; !ok {
+		return false
+	}
+	if finish {
+		delete(conn.operations, opID)
+		if len(conn.operations) == 0 {
+			conn.idleSince = time.Now()
+		}
+	}
+	return true
+}
+
+// gets how long the connection has had no active operations
+func (conn *connection) idleTime() time.Duration {
+	conn.stateMutex.Lock()
+	defer conn.stateMutex.Unlock()
+
+	if len(conn.operations) > 0 {
+		return 0
+	}
+	return time.Since(conn.idleSince)
+}
+
+// sends keep-alive messages and pings and closes connections that miss the
+// init deadline or stay idle for too long
+func (conn *connection) keepAlive() {
+	var initTimeout, keepAlive, ping, idle <-chan time.Time
+
+	if conn.config.InitTimeout > 0 {
+		timer := time.NewTimer(conn.config.InitTimeout)
+		defer timer.Stop()
+		initTimeout = timer.C
+	}
+
+	if conn.config.KeepAlive > 0 {
+		ticker := time.NewTicker(conn.config.KeepAlive)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+
+	if conn.config.PingInterval > 0 {
+		ticker := time.NewTicker(conn.config.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	var idleTimer *time.Timer
+	if conn.config.IdleTimeout > 0 {
+		idleTimer = time.NewTimer(conn.config.IdleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+
+	for {
+		select {
+		case <-conn.done:
+			return
+
+		case <-initTimeout:
+			if !conn.isAcknowledged() {
+				conn.Close(closeInitTimeout, "connection initialisation timeout")
+				return
+			}
+
+		case <-keepAlive:
+			if conn.isAcknowledged() {
+				conn.send(operationMessageForType(gqlConnectionKeepAlive))
+			}
+
+		case <-ping:
+			if err := conn.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				conn.logger.Warn("sending ping failed", "error", err)
+			}
+
+		case <-idle:
+			idleTime := conn.idleTime()
+			if idleTime >= conn.config.IdleTimeout {
+				conn.Close(websocket.CloseNormalClosure, "idle timeout")
+				return
+			}
+			idleTimer.Reset(conn.config.IdleTimeout - idleTime)
+		}
+	}
+}
+
 func (conn *connection) readLoop() {
 	// Close the WebSocket connection when leaving the read loop
 	defer conn.ws.Close()
 	conn.ws.SetReadLimit(readLimit)
 
+	// Detect dead peers by requiring a pong or a message before the read deadline
+	conn.extendReadDeadline()
+	conn.ws.SetPongHandler(func(string) error {
+		conn.extendReadDeadline()
+		return nil
+	})
+
 	for {
 		// Read the next message received from the client
 		rawPayload := json.RawMessage{}
@@ -274,28 +572,28 @@ func (conn *connection) readLoop() {
 		// see https://github.com/gorilla/websocket/blob/master/conn.go#L924 for
 		// more information on why this is necessary
 		if err != nil {
-			conn.logger.Warnf("force closing connection: %s", err)
+			conn.logger.Warn("force closing connection", "error", err)
 			conn.close()
 			return
 		}
 
-		// conn.logger.Debugf("received message (%s): %s", msg.ID, msg.Type)
+		conn.extendReadDeadline()
+
+		// conn.logger.Debug("received message", logger.OperationIDKey, msg.ID, "type", msg.Type)
 
 		switch msg.Type {
 		case gqlConnectionAuth:
 			data := map[string]any{}
 			if err := json.Unmarshal(rawPayload, &data); err != nil {
-				conn.logger.Errorf("Invalid %s data: %v", msg.Type, err)
-				conn.SendError(errors.New("invalid GQL_CONNECTION_AUTH payload"))
+				conn.logger.Error("invalid message payload", "type", msg.Type, "error", err)
+				conn.SendError(gqlerrors.NewFormattedError("invalid GQL_CONNECTION_AUTH payload"))
 			} else {
 				if conn.config.Authenticate != nil {
 					ctx, err := conn.config.Authenticate(data, conn)
 					if err != nil {
-						msg := operationMessageForType(gqlConnectionError)
-						msg.Payload = fmt.Sprintf("Failed to authenticate user: %v", err)
-						conn.outgoing <- msg
+						conn.sendConnectionError(fmt.Errorf("Failed to authenticate user: %w", err))
 					} else {
-						conn.context = ctx
+						conn.setContext(ctx)
 					}
 				}
 			}
@@ -304,48 +602,62 @@ func (conn *connection) readLoop() {
 		case gqlConnectionInit:
 			data := map[string]any{}
 			if err := json.Unmarshal(rawPayload, &data); err != nil {
-				conn.logger.Errorf("Invalid %s data: %v", msg.Type, err)
-				conn.SendError(errors.New("invalid GQL_CONNECTION_INIT payload"))
+				conn.logger.Error("invalid message payload", "type", msg.Type, "error", err)
+				conn.SendError(gqlerrors.NewFormattedError("invalid GQL_CONNECTION_INIT payload"))
 			} else {
 				if conn.config.Authenticate != nil {
 					ctx, err := conn.config.Authenticate(data, conn)
 					if err != nil {
-						msg := operationMessageForType(gqlConnectionError)
-						msg.Payload = fmt.Sprintf("Failed to authenticate user: %v", err)
-						conn.outgoing <- msg
+						conn.sendConnectionError(fmt.Errorf("Failed to authenticate user: %w", err))
 					} else {
-						conn.context = ctx
-						conn.outgoing <- operationMessageForType(gqlConnectionAck)
+						conn.setContext(ctx)
+						conn.acknowledge()
 					}
 				} else {
-					conn.outgoing <- operationMessageForType(gqlConnectionAck)
+					conn.acknowledge()
 				}
 			}
 
 		// Let event handlers deal with starting operations
 		case gqlStart:
-			if conn.config.EventHandlers.StartOperation != nil {
-				data := StartMessagePayload{}
-				if err := json.Unmarshal(rawPayload, &data); err != nil {
-					conn.SendError(errors.New("invalid GQL_START payload"))
-				} else {
-					errs := conn.config.EventHandlers.StartOperation(conn, msg.ID, &data)
-					if errs != nil {
-						conn.sendOperationErrors(msg.ID, errs)
-					}
+			// Operation IDs must be unique among the active operations
+			if !conn.startOperation(msg.ID) {
+				errMsg := operationMessageForType(gqlError)
+				errMsg.ID = msg.ID
+				errMsg.Payload = conn.formatErrors([]error{gqlerrors.NewFormattedError(fmt.Sprintf("operation %q is already in use", msg.ID))})
+				conn.send(errMsg)
+				break
+			}
+
+			// operations that are not started release their ID right away
+			if conn.config.EventHandlers.StartOperation == nil {
+				conn.finishOperation(msg.ID, true)
+				break
+			}
+
+			data := StartMessagePayload{}
+			if err := json.Unmarshal(rawPayload, &data); err != nil {
+				conn.finishOperation(msg.ID, true)
+				conn.SendError(gqlerrors.NewFormattedError("invalid GQL_START payload"))
+			} else {
+				errs := conn.config.EventHandlers.StartOperation(conn, msg.ID, &data)
+				if errs != nil {
+					conn.sendOperationErrors(msg.ID, errs)
 				}
 			}
 
-		// Let event handlers deal with stopping operations
+		// Let event handlers deal with stopping operations, then confirm
+		// the stop with a complete message
 		case gqlStop:
 			if conn.config.EventHandlers.StopOperation != nil {
 				conn.config.EventHandlers.StopOperation(conn, msg.ID)
 			}
+			conn.SendComplete(msg.ID)
 
 		// When the GraphQL WS connection is terminated by the client,
 		// close the connection and close the read loop
 		case gqlConnectionTerminate:
-			// conn.logger.Debugf("connection terminated by client")
+			// conn.logger.Debug("connection terminated by client")
 			conn.close()
 			return
 
@@ -353,7 +665,7 @@ func (conn *connection) readLoop() {
 		// a bug in our implementation; make this very obvious by logging
 		// an error
 		default:
-			conn.logger.Errorf("unhandled message: %s", msg.String())
+			conn.logger.Error("unhandled message", logger.OperationIDKey, msg.ID, "type", msg.Type)
 		}
 	}
 }
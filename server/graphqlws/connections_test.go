@@ -0,0 +1,306 @@
+package graphqlws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/gorilla/websocket"
+)
+
+// starts a server that accepts graphql-ws connections and dials it
+func testConnection(t *testing.T, config ConnectionConfig) *websocket.Conn {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"graphql-ws"}}
+	config.Logger = &logger.NoopLogger{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		NewConnection(ws, config)
+	}))
+	t.Cleanup(srv.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return ws
+}
+
+func TestInitTimeout(t *testing.T) {
+	ws := testConnection(t, ConnectionConfig{InitTimeout: 20 * time.Millisecond})
+
+	_, _, err := ws.ReadMessage()
+	if !websocket.IsCloseError(err, closeInitTimeout) {
+		t.Errorf("expected close code %d, got %v", closeInitTimeout, err)
+		return
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	ws := testConnection(t, ConnectionConfig{
+		KeepAlive:   10 * time.Millisecond,
+		InitTimeout: 20 * time.Millisecond,
+	})
+	ws.WriteJSON(OperationMessage{Type: gqlConnectionInit, Payload: map[string]any{}})
+
+	for _, expected := range []string{gqlConnectionAck, gqlConnectionKeepAlive, gqlConnectionKeepAlive} {
+		var msg OperationMessage
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Errorf("failed to read %s: %v", expected, err)
+			return
+		}
+		if msg.Type != expected {
+			t.Errorf("expected %s, got %s", expected, msg.Type)
+			return
+		}
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	ws := testConnection(t, ConnectionConfig{IdleTimeout: 20 * time.Millisecond})
+	ws.WriteJSON(OperationMessage{Type: gqlConnectionInit, Payload: map[string]any{}})
+
+	var msg OperationMessage
+	if err := ws.ReadJSON(&msg); err != nil || msg.Type != gqlConnectionAck {
+		t.Errorf("expected ack, got %v %v", msg, err)
+		return
+	}
+
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("expected normal closure, got %v", err)
+		return
+	}
+}
+
+func TestPongTimeout(t *testing.T) {
+	closed := make(chan struct{})
+	ws := testConnection(t, ConnectionConfig{
+		PingInterval: 10 * time.Millisecond,
+		EventHandlers: ConnectionEventHandlers{
+			Close: func(Connection) { close(closed) },
+		},
+	})
+
+	// ignore pings so the server gives up on the peer
+	ws.SetPingHandler(func(string) error { return nil })
+	go ws.ReadMessage()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the connection to be closed")
+		return
+	}
+}
+
+// reads the next message and checks its type and operation ID
+func expectMessage(t *testing.T, ws *websocket.Conn, msgType, opID string) bool {
+	var msg OperationMessage
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Errorf("failed to read %s: %v", msgType, err)
+		return false
+	}
+	if msg.Type != msgType || msg.ID != opID {
+		t.Errorf("expected %s for %q, got %s", msgType, opID, msg.String())
+		return false
+	}
+	return true
+}
+
+func TestOperationLifecycle(t *testing.T) {
+	stopped := make(chan string, 1)
+	ws := testConnection(t, ConnectionConfig{
+		EventHandlers: ConnectionEventHandlers{
+			StartOperation: func(conn Connection, opID string, data *StartMessagePayload) []error {
+				switch data.Query {
+				case "invalid":
+					return []error{errors.New("invalid query")}
+				case "once":
+					go func() {
+						conn.SendData(opID, &DataMessagePayload{Data: "once"})
+						conn.SendComplete(opID)
+						// dropped after complete
+						conn.SendData(opID, &DataMessagePayload{Data: "late"})
+					}()
+				}
+				return nil
+			},
+			StopOperation: func(conn Connection, opID string) {
+				stopped <- opID
+			},
+		},
+	})
+
+	send := func(msgType, opID, query string) {
+		ws.WriteJSON(OperationMessage{ID: opID, Type: msgType, Payload: StartMessagePayload{Query: query}})
+	}
+
+	send(gqlConnectionInit, "", "")
+	if !expectMessage(t, ws, gqlConnectionAck, "") {
+		return
+	}
+
+	// server side completion
+	send(gqlStart, "1", "once")
+	if !expectMessage(t, ws, gqlData, "1") || !expectMessage(t, ws, gqlComplete, "1") {
+		return
+	}
+
+	// start errors end the operation
+	send(gqlStart, "2", "invalid")
+	if !expectMessage(t, ws, gqlError, "2") {
+		return
+	}
+
+	// duplicate IDs are rejected while the operation is active
+	send(gqlStart, "3", "subscription")
+	send(gqlStart, "3", "subscription")
+	if !expectMessage(t, ws, gqlError, "3") {
+		return
+	}
+
+	// client side stop
+	send(gqlStop, "3", "")
+	if !expectMessage(t, ws, gqlComplete, "3") {
+		return
+	}
+	if opID := <-stopped; opID != "3" {
+		t.Errorf("expected operation 3 to be stopped, got %s", opID)
+		return
+	}
+
+	// finished IDs can be reused
+	send(gqlStart, "1", "once")
+	if !expectMessage(t, ws, gqlData, "1") || !expectMessage(t, ws, gqlComplete, "1") {
+		return
+	}
+
+	// invalid payloads release the ID
+	ws.WriteJSON(OperationMessage{ID: "4", Type: gqlStart, Payload: "invalid"})
+	if !expectMessage(t, ws, gqlError, "") {
+		return
+	}
+	send(gqlStart, "4", "once")
+	if !expectMessage(t, ws, gqlData, "4") || !expectMessage(t, ws, gqlComplete, "4") {
+		return
+	}
+}
+
+func TestIdleTimeoutUnhandledStart(t *testing.T) {
+	ws := testConnection(t, ConnectionConfig{IdleTimeout: 20 * time.Millisecond})
+	ws.WriteJSON(OperationMessage{Type: gqlConnectionInit, Payload: map[string]any{}})
+	if !expectMessage(t, ws, gqlConnectionAck, "") {
+		return
+	}
+
+	// without a StartOperation handler the operation never becomes active
+	ws.WriteJSON(OperationMessage{ID: "1", Type: gqlStart, Payload: StartMessagePayload{Query: "{ a }"}})
+
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("expected normal closure, got %v", err)
+		return
+	}
+}
+
+func TestConcurrentOperations(t *testing.T) {
+	ws := testConnection(t, ConnectionConfig{
+		EventHandlers: ConnectionEventHandlers{
+			StartOperation: func(conn Connection, opID string, data *StartMessagePayload) []error {
+				go func() {
+					for i := 0; i < 10; i++ {
+						conn.SendData(opID, &DataMessagePayload{Data: i})
+					}
+					conn.SendComplete(opID)
+				}()
+				return nil
+			},
+		},
+	})
+
+	ws.WriteJSON(OperationMessage{Type: gqlConnectionInit, Payload: map[string]any{}})
+	for i := 0; i < 10; i++ {
+		ws.WriteJSON(OperationMessage{ID: strconv.Itoa(i), Type: gqlStart, Payload: StartMessagePayload{}})
+	}
+
+	completed := map[string]bool{}
+	for len(completed) < 10 {
+		var msg OperationMessage
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Errorf("failed to read message: %v", err)
+			return
+		}
+		if completed[msg.ID] {
+			t.Errorf("received %s after complete for %s", msg.Type, msg.ID)
+			return
+		}
+		if msg.Type == gqlComplete {
+			completed[msg.ID] = true
+		}
+	}
+}
+
+func TestErrorPayloads(t *testing.T) {
+	ws := testConnection(t, ConnectionConfig{
+		Authenticate: func(data map[string]any, conn Connection) (context.Context, error) {
+			if data["authToken"] != "secret" {
+				return nil, errors.New("invalid token")
+			}
+			return context.Background(), nil
+		},
+		FormatError: func(err error) gqlerrors.FormattedError {
+			formatted := gqlerrors.FormatError(err)
+			formatted.Extensions = map[string]any{"code": "TEST"}
+			return formatted
+		},
+		EventHandlers: ConnectionEventHandlers{
+			StartOperation: func(conn Connection, opID string, data *StartMessagePayload) []error {
+				return []error{errors.New("invalid query")}
+			},
+		},
+	})
+
+	tests := []struct {
+		msg      OperationMessage
+		expected string
+	}{
+		{
+			OperationMessage{Type: gqlConnectionInit, Payload: map[string]any{"authToken": "nope"}},
+			`{"id":"","type":"connection_error","payload":{"message":"Failed to authenticate user: invalid token","locations":[],"extensions":{"code":"TEST"}}}`,
+		},
+		{
+			OperationMessage{Type: gqlConnectionInit, Payload: map[string]any{"authToken": "secret"}},
+			`{"id":"","type":"connection_ack","payload":null}`,
+		},
+		{
+			OperationMessage{ID: "1", Type: gqlStart, Payload: StartMessagePayload{Query: "{ hello }"}},
+			`{"id":"1","type":"error","payload":[{"message":"invalid query","locations":[],"extensions":{"code":"TEST"}}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		ws.WriteJSON(test.msg)
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Errorf("failed to read message: %v", err)
+			return
+		}
+		if strings.TrimSpace(string(data)) != test.expected {
+			t.Errorf("unexpected message %s", data)
+			return
+		}
+	}
+}
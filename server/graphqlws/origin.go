@@ -0,0 +1,55 @@
+package graphqlws
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginChecker creates a websocket origin check that allows the listed
+// origins. An entry is either a full origin like https://example.com or a
+// host, and may start with a *. wildcard to allow all subdomains. "*" allows
+// every origin. Requests without an Origin header do not come from a browser
+// and are always allowed
+func OriginChecker(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		for _, allowed := range allowedOrigins {
+			if matchOrigin(u, allowed) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// matches an origin against an allowed origin pattern
+func matchOrigin(origin *url.URL, allowed string) bool {
+	if allowed == "*" {
+		return true
+	}
+
+	host := allowed
+	if scheme, rest, ok := strings.Cut(allowed, "://"); ok {
+		if !strings.EqualFold(scheme, origin.Scheme) {
+			return false
+		}
+		host = rest
+	}
+
+	if strings.HasPrefix(host, "*.") {
+		return strings.HasSuffix(strings.ToLower(origin.Host), strings.ToLower(host[1:]))
+	}
+
+	return strings.EqualFold(host, origin.Host)
+}
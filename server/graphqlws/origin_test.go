@@ -0,0 +1,32 @@
+package graphqlws
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginChecker(t *testing.T) {
+	check := OriginChecker([]string{"https://app.example.com", "*.example.org", "localhost:3000"})
+
+	tests := map[string]bool{
+		"":                        true,
+		"https://app.example.com": true,
+		"http://app.example.com":  false,
+		"https://evil.com":        false,
+		"https://a.example.org":   true,
+		"http://b.a.example.org":  true,
+		"https://example.org":     false,
+		"http://localhost:3000":   true,
+		"http://localhost:3001":   false,
+	}
+
+	for origin, expected := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if allowed := check(r); allowed != expected {
+			t.Errorf("origin %q: expected %v, got %v", origin, expected, allowed)
+		}
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
 
-	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/cachecontrol"
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql-go-tools/incremental"
+	"github.com/dagger/graphql-go-tools/internal/graphqlhttp"
+	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql-go-tools/trusted"
 	"github.com/dagger/graphql/gqlerrors"
 )
 
@@ -18,234 +23,373 @@ type RequestOptions struct {
 	Query         string         `json:"query" url:"query" schema:"query"`
 	Variables     map[string]any `json:"variables" url:"variables" schema:"variables"`
 	OperationName string         `json:"operationName" url:"operationName" schema:"operationName"`
+	DocumentID    string         `json:"documentId" url:"documentId" schema:"documentId"`
+	Extensions    map[string]any `json:"extensions" url:"extensions" schema:"extensions"`
 }
 
 // a workaround for getting`variables` as a JSON string
 type requestOptionsCompatibility struct {
-	Query         string `json:"query" url:"query" schema:"query"`
-	Variables     string `json:"variables" url:"variables" schema:"variables"`
-	OperationName string `json:"operationName" url:"operationName" schema:"operationName"`
+	Query         string         `json:"query" url:"query" schema:"query"`
+	Variables     string         `json:"variables" url:"variables" schema:"variables"`
+	OperationName string         `json:"operationName" url:"operationName" schema:"operationName"`
+	DocumentID    string         `json:"documentId" url:"documentId" schema:"documentId"`
+	Extensions    map[string]any `json:"extensions" url:"extensions" schema:"extensions"`
+}
+
+// RequestError is a malformed or unsupported request along with the HTTP
+// status code it is reported with
+type RequestError = graphqlhttp.RequestError
+
+func newRequestError(statusCode int, format string, a ...any) *RequestError {
+	return graphqlhttp.NewRequestError(statusCode, format, a...)
 }
 
-func getFromForm(values url.Values) *RequestOptions {
+func getFromForm(values url.Values) (*RequestOptions, error) {
 	query := values.Get("query")
-	if query != "" {
+	documentID := values.Get("documentId")
+	extensionsStr := values.Get("extensions")
+	if query != "" || documentID != "" || extensionsStr != "" {
 		// get variables map
 		variables := make(map[string]any, len(values))
-		variablesStr := values.Get("variables")
-		json.Unmarshal([]byte(variablesStr), &variables)
+		if variablesStr := values.Get("variables"); variablesStr != "" {
+			if err := json.Unmarshal([]byte(variablesStr), &variables); err != nil {
+				return nil, newRequestError(http.StatusBadRequest, "variables must be a JSON object: %v", err)
+			}
+		}
+
+		var extensions map[string]any
+		if extensionsStr != "" {
+			if err := json.Unmarshal([]byte(extensionsStr), &extensions); err != nil {
+				return nil, newRequestError(http.StatusBadRequest, "extensions must be a JSON object: %v", err)
+			}
+		}
 
 		return &RequestOptions{
 			Query:         query,
 			Variables:     variables,
 			OperationName: values.Get("operationName"),
-		}
+			DocumentID:    documentID,
+			Extensions:    extensions,
+		}, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
-// NewRequestOptions Parses a http.Request into GraphQL request options struct
-func NewRequestOptions(r *http.Request) *RequestOptions {
-	if reqOpt := getFromForm(r.URL.Query()); reqOpt != nil {
-		return reqOpt
+// reports a body read error, keeping the status of a RequestError
+func bodyError(message string, err error) error {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr
 	}
+	return newRequestError(http.StatusBadRequest, "%s: %v", message, err)
+}
 
-	if r.Method != http.MethodPost {
+// NewRequestOptions Parses a http.Request into GraphQL request options struct
+func NewRequestOptions(r *http.Request) *RequestOptions {
+	opts, err := parseRequestOptions(r, false)
+	if err != nil {
 		return &RequestOptions{}
 	}
+	return opts
+}
 
-	if r.Body == nil {
+// GetRequestOptions Parses a http.Request into GraphQL request options struct without clearning the body
+func GetRequestOptions(r *http.Request) *RequestOptions {
+	opts, err := parseRequestOptions(r, true)
+	if err != nil {
 		return &RequestOptions{}
 	}
+	return opts
+}
 
-	// TODO: improve Content-Type handling
-	contentTypeStr := r.Header.Get("Content-Type")
-	contentTypeTokens := strings.Split(contentTypeStr, ";")
-	contentType := contentTypeTokens[0]
-
-	switch contentType {
-	case ContentTypeGraphQL:
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return &RequestOptions{}
-		}
-		return &RequestOptions{
-			Query: string(body),
-		}
-	case ContentTypeFormURLEncoded:
-		if err := r.ParseForm(); err != nil {
-			return &RequestOptions{}
-		}
-
-		if reqOpt := getFromForm(r.PostForm); reqOpt != nil {
-			return reqOpt
-		}
-
-		return &RequestOptions{}
-
-	case ContentTypeJSON:
-		fallthrough
-	default:
-		var opts RequestOptions
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return &opts
-		}
-		err = json.Unmarshal(body, &opts)
-		if err != nil {
-			// Probably `variables` was sent as a string instead of an object.
-			// So, we try to be polite and try to parse that as a JSON string
-			var optsCompatible requestOptionsCompatibility
-			json.Unmarshal(body, &optsCompatible)
-			json.Unmarshal([]byte(optsCompatible.Variables), &opts.Variables)
-		}
-		return &opts
-	}
+// ParseRequestOptions Parses a http.Request into GraphQL request options struct
+// and reports a malformed request as a *RequestError
+func ParseRequestOptions(r *http.Request) (*RequestOptions, error) {
+	return parseRequestOptions(r, false)
 }
 
-// GetRequestOptions Parses a http.Request into GraphQL request options struct without clearning the body
-func GetRequestOptions(r *http.Request) *RequestOptions {
-	if reqOpt := getFromForm(r.URL.Query()); reqOpt != nil {
-		return reqOpt
+func parseRequestOptions(r *http.Request, keepBody bool) (*RequestOptions, error) {
+	if reqOpt, err := getFromForm(r.URL.Query()); reqOpt != nil || err != nil {
+		return reqOpt, err
 	}
 
 	if r.Method != http.MethodPost {
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 	}
 
 	if r.Body == nil {
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 	}
 
-	// TODO: improve Content-Type handling
-	contentTypeStr := r.Header.Get("Content-Type")
-	contentTypeTokens := strings.Split(contentTypeStr, ";")
-	contentType := contentTypeTokens[0]
+	readBody := func() ([]byte, error) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, bodyError("failed to read request body", err)
+		}
+		if keepBody {
+			r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		}
+		return body, nil
+	}
 
-	switch contentType {
+	switch graphqlhttp.GetMediaType(r.Header.Get("Content-Type")) {
 	case ContentTypeGraphQL:
-		body, err := ioutil.ReadAll(r.Body)
+		body, err := readBody()
 		if err != nil {
-			return &RequestOptions{}
+			return nil, err
 		}
-		r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
 		return &RequestOptions{
 			Query: string(body),
-		}
+		}, nil
 	case ContentTypeFormURLEncoded:
 		if err := r.ParseForm(); err != nil {
-			return &RequestOptions{}
+			return nil, bodyError("invalid form body", err)
 		}
 
-		if reqOpt := getFromForm(r.PostForm); reqOpt != nil {
-			return reqOpt
+		if reqOpt, err := getFromForm(r.PostForm); reqOpt != nil || err != nil {
+			return reqOpt, err
 		}
 
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 
 	case ContentTypeJSON:
 		fallthrough
 	default:
 		var opts RequestOptions
-		body, err := ioutil.ReadAll(r.Body)
+		body, err := readBody()
 		if err != nil {
-			return &opts
+			return nil, err
 		}
-		r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-		err = json.Unmarshal(body, &opts)
-		if err != nil {
+		if len(bytes.TrimSpace(body)) == 0 {
+			return &opts, nil
+		}
+		if err := json.Unmarshal(body, &opts); err != nil {
 			// Probably `variables` was sent as a string instead of an object.
 			// So, we try to be polite and try to parse that as a JSON string
 			var optsCompatible requestOptionsCompatibility
-			json.Unmarshal(body, &optsCompatible)
-			json.Unmarshal([]byte(optsCompatible.Variables), &opts.Variables)
+			if json.Unmarshal(body, &optsCompatible) != nil {
+				return nil, newRequestError(http.StatusBadRequest, "invalid JSON body: %v", err)
+			}
+			opts = RequestOptions{
+				Query:         optsCompatible.Query,
+				OperationName: optsCompatible.OperationName,
+				DocumentID:    optsCompatible.DocumentID,
+				Extensions:    optsCompatible.Extensions,
+			}
+			if optsCompatible.Variables != "" {
+				if err := json.Unmarshal([]byte(optsCompatible.Variables), &opts.Variables); err != nil {
+					return nil, newRequestError(http.StatusBadRequest, "variables must be a JSON object: %v", err)
+				}
+			}
 		}
-		return &opts
+		return &opts, nil
 	}
 }
 
 // ContextHandler provides an entrypoint into executing graphQL queries with a
 // user-provided context.
 func (s *Server) ContextHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	// requests for the graphiql or playground UI are exempt from the spec checks
+	renderUI := (s.options.GraphiQL != nil || s.options.Playground != nil) && graphqlhttp.AcceptsHTML(r)
+	specCompliant := s.options.SpecCompliant && !renderUI
+	if specCompliant {
+		if err := graphqlhttp.CheckSpecMethod(r); err != nil {
+			s.writeRequestError(w, r, ContentTypeJSON, err)
+			return
+		}
+	}
+
 	// get query
-	opts := NewRequestOptions(r)
+	opts, err := graphqlhttp.Parse(s.limits, w, r, ParseRequestOptions)
+	if err == nil {
+		err = s.limits.Check(opts.Query, opts.Variables)
+	}
+	if err != nil {
+		s.writeRequestError(w, r, ContentTypeJSON, err)
+		return
+	}
+
+	// apply the execution deadline, resolvers observe it through the context
+	ctx, cancel := s.limits.WithDeadline(ctx)
+	defer cancel()
+
+	// tag the log lines of the operation
+	log := s.requestLogger(r)
+	if opts.OperationName != "" {
+		log = log.With(logger.OperationNameKey, opts.OperationName)
+	}
+	ctx = logger.WithContext(ctx, log)
+
+	// collect the cache policy of the response
+	var policy *cachecontrol.Policy
+	if s.options.CacheControl != nil {
+		ctx, policy = cachecontrol.WithPolicy(ctx)
+	}
+
+	// reject mutations requested with GET once the document is known
+	var check *graphqlhttp.MethodCheck
+	if specCompliant {
+		ctx, check = graphqlhttp.WithMethodCheck(ctx)
+	}
 
 	// execute graphql query
-	params := graphql.Params{
-		Schema:         s.schema,
-		RequestString:  opts.Query,
-		VariableValues: opts.Variables,
-		OperationName:  opts.OperationName,
-		Context:        ctx,
+	req := &executor.Request{
+		Query:         opts.Query,
+		Variables:     opts.Variables,
+		OperationName: opts.OperationName,
+		DocumentID:    trusted.DocumentID(opts.DocumentID, opts.Extensions),
+		Extensions:    opts.Extensions,
+		Context:       ctx,
+		Transport:     executor.TransportHTTP,
+		HTTPRequest:   r,
 	}
 	if s.options.RootValueFunc != nil {
-		params.RootObject = s.options.RootValueFunc(ctx, r)
+		req.RootObject = s.options.RootValueFunc(ctx, r)
 	}
-	result := graphql.Do(params)
+	params := req.Params(s.Schema())
 
-	if formatErrorFunc := s.options.FormatErrorFunc; formatErrorFunc != nil && len(result.Errors) > 0 {
-		formatted := make([]gqlerrors.FormattedError, len(result.Errors))
-		for i, formattedError := range result.Errors {
-			formatted[i] = formatErrorFunc(formattedError.OriginalError())
+	mediaType := ContentTypeJSON
+	if specCompliant {
+		if mediaType, err = graphqlhttp.CheckSpecRequest(r, req.Query, req.DocumentID); err != nil {
+			s.writeRequestError(w, r, mediaType, err)
+			return
 		}
-		result.Errors = formatted
 	}
 
-	if s.options.GraphiQL != nil {
-		acceptHeader := r.Header.Get("Accept")
-		_, raw := r.URL.Query()["raw"]
-		if !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
-			renderGraphiQL(s.options.GraphiQL, w, r, params)
-			return
-		}
-	} else if s.options.Playground != nil {
-		acceptHeader := r.Header.Get("Accept")
-		_, raw := r.URL.Query()["raw"]
-		if !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
+	// responses of queries are stored for the cachecontrol plugin, which
+	// serves them once the other plugins accepted the request
+	cached := !renderUI && s.options.CacheControl != nil
+
+	// stream @defer and @stream results to clients that accept them
+	if incremental.Accepts(r) {
+		s.incrementalHandler(w, req, check, mediaType)
+		return
+	}
+
+	result := s.executor.Execute(req)
+
+	if err := check.Err(); err != nil {
+		s.writeRequestError(w, r, mediaType, err)
+		return
+	}
+
+	if renderUI {
+		if s.options.GraphiQL != nil {
+			renderGraphiQL(s.options.GraphiQL, w, r, params, result)
+		} else {
 			renderPlayground(s.options.Playground, w, r)
-			return
 		}
+		return
 	}
 
+	statusCode := graphqlhttp.ResultStatus(mediaType, req.Executed || req.Cached)
+	if timedOut, timeoutStatus := s.limits.CheckDeadline(ctx, result); timeoutStatus != 0 {
+		result, statusCode = timedOut, timeoutStatus
+	}
+
+	if header := policy.Header(); header != "" {
+		w.Header().Set("Cache-Control", header)
+	}
+
+	buff := s.writeResult(w, mediaType, statusCode, result)
+	if cached && statusCode == http.StatusOK && !req.Cached {
+		s.options.CacheControl.Store(req, policy, buff)
+	}
+
+	if s.options.ResultCallbackFunc != nil {
+		s.options.ResultCallbackFunc(ctx, &params, result, buff)
+	}
+}
+
+// writes a result with the response media type and returns the response body
+func (s *Server) writeResult(w http.ResponseWriter, mediaType string, statusCode int, result any) []byte {
 	// use proper JSON Header
-	w.Header().Add("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
 
 	var buff []byte
 	if s.options.Pretty {
-		w.WriteHeader(http.StatusOK)
 		buff, _ = json.MarshalIndent(result, "", "\t")
-
-		w.Write(buff)
 	} else {
-		w.WriteHeader(http.StatusOK)
 		buff, _ = json.Marshal(result)
+	}
 
-		w.Write(buff)
+	w.WriteHeader(statusCode)
+	w.Write(buff)
+	return buff
+}
+
+// writes a malformed or unsupported request error as a GraphQL response
+func (s *Server) writeRequestError(w http.ResponseWriter, r *http.Request, mediaType string, err error) {
+	statusCode := http.StatusBadRequest
+	if reqErr, ok := err.(*RequestError); ok {
+		statusCode = reqErr.StatusCode
 	}
 
-	if s.options.ResultCallbackFunc != nil {
-		s.options.ResultCallbackFunc(ctx, &params, result, buff)
+	if statusCode == http.StatusMethodNotAllowed {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Allow", http.MethodPost)
+		} else {
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		}
+	}
+
+	s.writeResult(w, mediaType, statusCode, map[string]any{
+		"errors": gqlerrors.FormatErrors(err),
+	})
+}
+
+// writes the incremental payloads of an operation as a multipart/mixed response
+func (s *Server) incrementalHandler(w http.ResponseWriter, req *executor.Request, check *graphqlhttp.MethodCheck, mediaType string) {
+	mw := incremental.NewMultipartWriter(w, s.options.Pretty)
+
+	var err error
+	for payload := range s.executor.Incremental(req) {
+		// a rejected request is reported before any payload is written
+		if rejected := check.Err(); rejected != nil && err == nil {
+			s.writeRequestError(w, req.HTTPRequest, mediaType, rejected)
+			err = rejected
+		}
+
+		// keep draining the payloads after a failed write so the executor can finish
+		if err == nil {
+			err = mw.WritePayload(payload)
+		}
+	}
+
+	if err == nil {
+		mw.Close()
 	}
 }
 
 func (s *Server) WSHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	// Refuse new connections once the server is shutting down
+	if !s.reserveConnection() {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	log := s.requestLogger(r)
+	log.Debug("upgrading connection to websocket")
+
 	// Establish a WebSocket connection
 	var ws, err = s.upgrader.Upgrade(w, r, nil)
 
 	// Bail out if the WebSocket connection could not be established
 	if err != nil {
-		s.log.Warnf("Failed to establish WebSocket connection", err)
+		log.Warn("failed to establish WebSocket connection", "error", err)
+		s.wg.Done()
 		return
 	}
 
 	// Close the connection early if it doesn't implement the graphql-ws protocol
 	if ws.Subprotocol() == "graphql-ws" {
-		s.newGraphQLWSConnection(ctx, r, ws)
+		s.newGraphQLWSConnection(ctx, r, ws, log)
 		return
 	}
 
 	// TODO: support other popular protocols
-	s.log.Warnf("Connection does not implement the GraphQL WS protocol. Subprotocol: %s", ws.Subprotocol())
+	log.Warn("connection does not implement the GraphQL WS protocol", "subprotocol", ws.Subprotocol())
 	ws.Close()
+	s.wg.Done()
 }
@@ -1,15 +1,75 @@
+// Package logger defines the structured logger used by the server and the
+// WebSocket connections
 package logger
 
+import (
+	"context"
+	"fmt"
+)
+
+// Keys of the fields added to the log lines of the server
+const (
+	RequestIDKey     = "requestId"
+	ConnectionIDKey  = "connectionId"
+	OperationIDKey   = "operationId"
+	OperationNameKey = "operationName"
+)
+
+// Logger is a leveled logger with key/value fields. Fields alternate keys and
+// values like log/slog
 type Logger interface {
-	Infof(format string, data ...any)
-	Debugf(format string, data ...any)
-	Errorf(format string, data ...any)
-	Warnf(format string, data ...any)
+	Debug(msg string, fields ...any)
+	Info(msg string, fields ...any)
+	Warn(msg string, fields ...any)
+	Error(msg string, fields ...any)
+
+	// With returns a logger that adds the fields to every line
+	With(fields ...any) Logger
+}
+
+// From returns the Logger of a configured logger, which is a Logger or a
+// PrintfLogger of earlier versions. nil gives a NoopLogger
+func From(l any) Logger {
+	switch l := l.(type) {
+	case nil:
+		return &NoopLogger{}
+	case Logger:
+		return l
+	case PrintfLogger:
+		return FromPrintf(l)
+	default:
+		panic(fmt.Sprintf("unsupported logger type %T", l))
+	}
 }
 
 type NoopLogger struct{}
 
+func (n *NoopLogger) Debug(msg string, fields ...any) {}
+func (n *NoopLogger) Info(msg string, fields ...any)  {}
+func (n *NoopLogger) Warn(msg string, fields ...any)  {}
+func (n *NoopLogger) Error(msg string, fields ...any) {}
+func (n *NoopLogger) With(fields ...any) Logger       { return n }
+
+// the printf methods of earlier versions, so NoopLogger still satisfies
+// PrintfLogger
 func (n *NoopLogger) Infof(format string, data ...any)  {}
 func (n *NoopLogger) Debugf(format string, data ...any) {}
 func (n *NoopLogger) Errorf(format string, data ...any) {}
 func (n *NoopLogger) Warnf(format string, data ...any)  {}
+
+type contextKey struct{}
+
+// WithContext returns a context carrying the logger
+func WithContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger of a context. The server attaches loggers
+// tagged with the request, connection and operation to the contexts of the
+// operations, so resolvers can log with the same fields
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return l
+	}
+	return &NoopLogger{}
+}
@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// records the lines of a printf logger
+type printfRecorder struct {
+	lines []string
+}
+
+func (r *printfRecorder) Infof(format string, data ...any) {
+	r.lines = append(r.lines, "INFO "+fmt.Sprintf(format, data...))
+}
+
+func (r *printfRecorder) Debugf(format string, data ...any) {
+	r.lines = append(r.lines, "DEBUG "+fmt.Sprintf(format, data...))
+}
+
+func (r *printfRecorder) Errorf(format string, data ...any) {
+	r.lines = append(r.lines, "ERROR "+fmt.Sprintf(format, data...))
+}
+
+func (r *printfRecorder) Warnf(format string, data ...any) {
+	r.lines = append(r.lines, "WARN "+fmt.Sprintf(format, data...))
+}
+
+func TestFromPrintf(t *testing.T) {
+	r := &printfRecorder{}
+	l := FromPrintf(r).With(ConnectionIDKey, "c1")
+	op := l.With(OperationIDKey, "1")
+
+	op.Info("start operation", "query", "{ hello }")
+	l.Warn("100% done", "count", 2, "dangling")
+	op.Error("failed", 3, "value")
+	l.Debug("empty", "value", "")
+
+	expected := []string{
+		`INFO start operation connectionId=c1 operationId=1 query="{ hello }"`,
+		`WARN 100% done connectionId=c1 count=2 !BADKEY=dangling`,
+		`ERROR failed connectionId=c1 operationId=1 !BADKEY=3 !BADKEY=value`,
+		`DEBUG empty connectionId=c1 value=""`,
+	}
+	if strings.Join(r.lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected lines:\n%s", strings.Join(r.lines, "\n"))
+		return
+	}
+}
+
+func TestFrom(t *testing.T) {
+	r := &printfRecorder{}
+	From(r).Info("hello", "count", 1)
+	if strings.Join(r.lines, "\n") != "INFO hello count=1" {
+		t.Errorf("unexpected lines:\n%s", strings.Join(r.lines, "\n"))
+		return
+	}
+
+	l := FromPrintf(r)
+	if From(l) != l {
+		t.Error("expected a Logger to be returned as is")
+		return
+	}
+
+	if _, ok := From(nil).(*NoopLogger); !ok {
+		t.Error("expected a NoopLogger for nil")
+		return
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an unsupported logger")
+		}
+	}()
+	From("logger")
+}
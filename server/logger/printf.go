@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PrintfLogger is the printf style logger of earlier versions. It is still
+// accepted wherever a logger is configured.
+//
+// Deprecated: implement Logger instead
+type PrintfLogger interface {
+	Infof(format string, data ...any)
+	Debugf(format string, data ...any)
+	Errorf(format string, data ...any)
+	Warnf(format string, data ...any)
+}
+
+// FromPrintf adapts a printf style logger, fields are appended to the
+// message as key=value pairs
+func FromPrintf(l PrintfLogger) Logger {
+	return &printfLogger{l: l}
+}
+
+type printfLogger struct {
+	l      PrintfLogger
+	fields []any
+}
+
+func (p *printfLogger) Debug(msg string, fields ...any) {
+	p.l.Debugf("%s", p.format(msg, fields))
+}
+
+func (p *printfLogger) Info(msg string, fields ...any) {
+	p.l.Infof("%s", p.format(msg, fields))
+}
+
+func (p *printfLogger) Warn(msg string, fields ...any) {
+	p.l.Warnf("%s", p.format(msg, fields))
+}
+
+func (p *printfLogger) Error(msg string, fields ...any) {
+	p.l.Errorf("%s", p.format(msg, fields))
+}
+
+func (p *printfLogger) With(fields ...any) Logger {
+	return &printfLogger{
+		l:      p.l,
+		fields: append(p.fields[:len(p.fields):len(p.fields)], fields...),
+	}
+}
+
+func (p *printfLogger) format(msg string, fields []any) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	writeFields(&b, p.fields)
+	writeFields(&b, fields)
+	return b.String()
+}
+
+// writes key=value pairs, a value without a key is written with the
+// !BADKEY key like log/slog does
+func writeFields(b *strings.Builder, fields []any) {
+	for len(fields) > 0 {
+		key, ok := fields[0].(string)
+		if !ok || len(fields) == 1 {
+			writeField(b, "!BADKEY", fields[0])
+			fields = fields[1:]
+			continue
+		}
+		writeField(b, key, fields[1])
+		fields = fields[2:]
+	}
+}
+
+func writeField(b *strings.Builder, key string, value any) {
+	b.WriteByte(' ')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(quote(fmt.Sprint(value)))
+}
+
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,23 @@
+//go:build go1.21
+
+package logger
+
+import "log/slog"
+
+// FromSlog adapts a log/slog logger
+func FromSlog(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s *slogLogger) Debug(msg string, fields ...any) { s.l.Debug(msg, fields...) }
+func (s *slogLogger) Info(msg string, fields ...any)  { s.l.Info(msg, fields...) }
+func (s *slogLogger) Warn(msg string, fields ...any)  { s.l.Warn(msg, fields...) }
+func (s *slogLogger) Error(msg string, fields ...any) { s.l.Error(msg, fields...) }
+
+func (s *slogLogger) With(fields ...any) Logger {
+	return &slogLogger{l: s.l.With(fields...)}
+}
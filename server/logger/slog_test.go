@@ -0,0 +1,22 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestFromSlog(t *testing.T) {
+	var buff bytes.Buffer
+	l := FromSlog(slog.New(slog.NewTextHandler(&buff, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	l.With(RequestIDKey, "r1").Debug("hello", "count", 1)
+	line := buff.String()
+	if !strings.Contains(line, `level=DEBUG msg=hello requestId=r1 count=1`) {
+		t.Errorf("unexpected line %s", line)
+		return
+	}
+}
@@ -0,0 +1,73 @@
+//go:build go1.21
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dagger/graphql-go-tools/server/logger"
+)
+
+// a buffer safe for concurrent log writes
+type syncBuffer struct {
+	mx   sync.Mutex
+	buff bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buff.Write(p)
+}
+
+func (b *syncBuffer) lines() []map[string]any {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buff.String()), "\n") {
+		var fields map[string]any
+		if json.Unmarshal([]byte(line), &fields) == nil {
+			lines = append(lines, fields)
+		}
+	}
+	return lines
+}
+
+func TestLogFields(t *testing.T) {
+	buff := &syncBuffer{}
+	log := slog.New(slog.NewJSONHandler(buff, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s, _, ws := testWSServer(t, &Options{Logger: logger.FromSlog(log)})
+	startTicks(t, s, ws, "1")
+
+	var start map[string]any
+	for _, line := range buff.lines() {
+		if line["msg"] == "start operation" {
+			start = line
+		}
+		if line[logger.RequestIDKey] == nil || line[logger.RequestIDKey] == "" {
+			t.Errorf("expected a request ID in %v", line)
+			return
+		}
+	}
+
+	if start == nil {
+		t.Error("expected a start operation line")
+		return
+	}
+	for key, expected := range map[string]any{
+		logger.ConnectionIDKey:  s.Connections()[0].ID,
+		logger.OperationIDKey:   "1",
+		logger.OperationNameKey: "Ticks",
+	} {
+		if start[key] != expected {
+			t.Errorf("expected %s %v, got %v", key, expected, start[key])
+			return
+		}
+	}
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dagger/graphql"
 )
@@ -18,6 +21,12 @@ type ResultChan struct {
 	ctx        context.Context
 	cid        string
 	oid        string
+
+	// details reported by Server.Operations
+	operationName string
+	queryHash     string
+	startedAt     time.Time
+	messagesSent  int64
 }
 
 func (c *ChanMgr) Add(rc *ResultChan) { // Add(cid, oid string, ch chan *graphql.Result) {
@@ -49,7 +58,7 @@ func (c *ChanMgr) DelConn(cid string) bool {
 		delete(conn, oid)
 	}
 
-	delete(conn, cid)
+	delete(c.conns, cid)
 	return true
 }
 
@@ -78,3 +87,33 @@ func (c *ChanMgr) Del(cid, oid string) bool {
 
 	return true
 }
+
+// Operations returns a snapshot of the operations of a connection
+func (c *ChanMgr) Operations(cid string) []OperationInfo {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	operations := make([]OperationInfo, 0, len(c.conns[cid]))
+	for _, rc := range c.conns[cid] {
+		operations = append(operations, OperationInfo{
+			ID:            rc.oid,
+			ConnectionID:  rc.cid,
+			OperationName: rc.operationName,
+			QueryHash:     rc.queryHash,
+			StartedAt:     rc.startedAt,
+			MessagesSent:  atomic.LoadInt64(&rc.messagesSent),
+		})
+	}
+
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].StartedAt.Before(operations[j].StartedAt)
+	})
+	return operations
+}
+
+// Count returns the number of operations of a connection
+func (c *ChanMgr) Count(cid string) int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.conns[cid])
+}
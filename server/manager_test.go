@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestChanMgr(t *testing.T) {
+	mgr := &ChanMgr{conns: make(map[string]map[string]*ResultChan)}
+
+	var wg sync.WaitGroup
+	for c := 0; c < 4; c++ {
+		cid := strconv.Itoa(c)
+		for o := 0; o < 10; o++ {
+			oid := strconv.Itoa(o)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithCancel(context.Background())
+				mgr.Add(&ResultChan{ctx: ctx, cancelFunc: cancel, cid: cid, oid: oid})
+				if oid == "0" {
+					mgr.Del(cid, oid)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if !mgr.DelConn("0") || mgr.DelConn("0") {
+		t.Error("expected the connection to be removed once")
+		return
+	}
+	for c := 1; c < 4; c++ {
+		for o := 0; o < 10; o++ {
+			mgr.Del(strconv.Itoa(c), strconv.Itoa(o))
+		}
+	}
+
+	if len(mgr.conns) != 0 {
+		t.Errorf("expected no registered connections, got %d", len(mgr.conns))
+		return
+	}
+}
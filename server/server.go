@@ -4,49 +4,93 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dagger/graphql"
+	"github.com/dagger/graphql-go-tools/cachecontrol"
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql-go-tools/internal/graphqlhttp"
 	"github.com/dagger/graphql-go-tools/server/graphqlws"
 	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql-go-tools/trusted"
 	"github.com/dagger/graphql/gqlerrors"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 // Constants
 const (
-	ContentTypeJSON           = "application/json"
-	ContentTypeGraphQL        = "application/graphql"
-	ContentTypeFormURLEncoded = "application/x-www-form-urlencoded"
+	ContentTypeJSON           = graphqlhttp.ContentTypeJSON
+	ContentTypeGraphQL        = graphqlhttp.ContentTypeGraphQL
+	ContentTypeFormURLEncoded = graphqlhttp.ContentTypeFormURLEncoded
+
+	// ContentTypeGraphQLResponse is the response media type defined by the
+	// GraphQL over HTTP specification
+	ContentTypeGraphQLResponse = graphqlhttp.ContentTypeGraphQLResponse
 )
 
 // ConnKey the connection key
 var ConnKey any = "conn"
 
 type Server struct {
-	schema   graphql.Schema
 	log      logger.Logger
 	options  *Options
 	upgrader websocket.Upgrader
 	mgr      *ChanMgr
+	limits   graphqlhttp.Limits
+	executor *executor.Executor
+	cache    *executor.DocumentCache
+
+	// tracks the WebSocket connections for Shutdown
+	connMutex    sync.Mutex
+	connections  map[string]*wsConnection
+	shuttingDown bool
+	wg           sync.WaitGroup
 }
 
 func New(schema graphql.Schema, options *Options) *Server {
-	if options.Logger == nil {
-		options.Logger = &logger.NoopLogger{}
-	}
-
-	return &Server{
-		schema:  schema,
-		log:     options.Logger,
-		options: options,
-		upgrader: websocket.Upgrader{
-			CheckOrigin:  func(r *http.Request) bool { return true },
-			Subprotocols: []string{"graphql-ws"},
-		},
+	s := &Server{
+		log:      logger.From(options.Logger),
+		options:  options,
+		upgrader: newUpgrader(options.WS),
 		mgr: &ChanMgr{
 			conns: make(map[string]map[string]*ResultChan),
 		},
+		connections: make(map[string]*wsConnection),
+		limits: graphqlhttp.Limits{
+			MaxBodyBytes:      options.MaxBodyBytes,
+			MaxQueryLength:    options.MaxQueryLength,
+			MaxVariablesBytes: options.MaxVariablesBytes,
+			MaxTokens:         options.MaxTokens,
+			ParseTimeout:      options.ParseTimeout,
+			ExecutionTimeout:  options.ExecutionTimeout,
+		},
+	}
+	if options.DocumentCacheSize > 0 {
+		s.cache = executor.NewDocumentCache(options.DocumentCacheSize)
+	}
+	plugins := options.Plugins
+	if options.CacheControl != nil {
+		plugins = append(plugins[:len(plugins):len(plugins)], options.CacheControl.Plugin())
+	}
+	if options.SpecCompliant {
+		// checks mutations requested with GET once documents sent by ID
+		// are resolved
+		plugins = append([]executor.Plugin{graphqlhttp.MethodPlugin()}, plugins...)
+	}
+	if options.TrustedDocuments != nil {
+		// documents sent by ID are replaced before other plugins see them
+		plugins = append([]executor.Plugin{options.TrustedDocuments.Plugin()}, plugins...)
 	}
+	s.executor = executor.New(&executor.Config{
+		Schema:        &schema,
+		Plugins:       plugins,
+		FormatErrorFn: options.FormatErrorFunc,
+		Cache:         s.cache,
+	})
+
+	return s
 }
 
 type RootValueFunc func(ctx context.Context, r *http.Request) map[string]any
@@ -64,14 +108,166 @@ type Options struct {
 	ContextFunc        ContextFunc
 	WSContextFunc      ContextFunc
 	ResultCallbackFunc ResultCallbackFunc
-	Logger             logger.Logger
-	WS                 *WSOptions
-	Playground         *PlaygroundOptions
-	GraphiQL           *GraphiQLOptions
+
+	// Logger is a logger.Logger or a printf style logger.PrintfLogger of
+	// earlier versions, see logger.From
+	Logger any
+
+	// WS configures WebSocket connections. Only same origin connections are
+	// accepted by default, earlier versions accepted every origin. Set
+	// WSOptions.AllowedOrigins to []string{"*"} to keep accepting them
+	WS *WSOptions
+
+	Playground *PlaygroundOptions
+	GraphiQL   *GraphiQLOptions
+
+	// Plugins hook into the execution of every operation over HTTP and
+	// WebSocket
+	Plugins []executor.Plugin
+
+	// DocumentCacheSize caches up to this many parsed and validated
+	// documents, zero disables the cache
+	DocumentCacheSize int
+
+	// CacheControl sets the Cache-Control header of HTTP responses from the
+	// @cacheControl hints of the resolved fields, and caches whole responses
+	// when it has a store
+	CacheControl *cachecontrol.CacheControl
+
+	// TrustedDocuments only runs registered documents, sent by ID or as
+	// their exact text, or logs the other operations in log only mode
+	TrustedDocuments *trusted.TrustedDocuments
+
+	// SpecCompliant follows the GraphQL over HTTP specification for media
+	// types, status codes and GET requests
+	SpecCompliant bool
+
+	// MaxBodyBytes limits the size of the request body
+	MaxBodyBytes int64
+
+	// MaxQueryLength limits the number of characters in the query
+	MaxQueryLength int
+
+	// MaxVariablesBytes limits the JSON encoded size of the variables
+	MaxVariablesBytes int
+
+	// MaxTokens limits the number of tokens the query is lexed into before it
+	// is parsed
+	MaxTokens int
+
+	// ParseTimeout limits the time spent receiving and parsing the request
+	ParseTimeout time.Duration
+
+	// ExecutionTimeout sets a deadline on the context of each operation
+	ExecutionTimeout time.Duration
 }
 
+// WSOptions configures WebSocket connections and their upgrade
 type WSOptions struct {
 	AuthenticateFunc graphqlws.AuthenticateFunc
+
+	// AllowedOrigins lists the origins allowed to open a connection. An entry
+	// is either a full origin like https://example.com or a host, and may start
+	// with a *. wildcard to allow all subdomains. "*" allows every origin. When
+	// neither AllowedOrigins nor CheckOrigin are set only same origin
+	// connections are allowed
+	AllowedOrigins []string
+
+	// CheckOrigin is a custom origin check that takes precedence over AllowedOrigins
+	CheckOrigin func(r *http.Request) bool
+
+	// ReadBufferSize and WriteBufferSize set the I/O buffer sizes in bytes
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// EnableCompression negotiates per message compression with the client
+	EnableCompression bool
+
+	// HandshakeTimeout limits the time spent on the upgrade handshake
+	HandshakeTimeout time.Duration
+
+	// Subprotocols lists the supported subprotocols in order of preference,
+	// defaults to graphql-ws
+	Subprotocols []string
+
+	// IdentityFunc describes the authenticated user of a connection for
+	// Server.Connections from the context returned by AuthenticateFunc
+	IdentityFunc func(ctx context.Context) string
+
+	// KeepAlive sends a ka message at this interval once a connection has
+	// been acknowledged
+	KeepAlive time.Duration
+
+	// PingInterval sends a WebSocket ping at this interval and disconnects
+	// peers that do not respond within PongTimeout, which defaults to
+	// PingInterval
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+
+	// InitTimeout closes connections that do not send a connection_init
+	// message within this duration
+	InitTimeout time.Duration
+
+	// IdleTimeout closes connections that have had no active operations for
+	// this duration
+	IdleTimeout time.Duration
+}
+
+// creates the websocket upgrader from the options
+func newUpgrader(options *WSOptions) websocket.Upgrader {
+	if options == nil {
+		options = &WSOptions{}
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin:       options.CheckOrigin,
+		ReadBufferSize:    options.ReadBufferSize,
+		WriteBufferSize:   options.WriteBufferSize,
+		EnableCompression: options.EnableCompression,
+		HandshakeTimeout:  options.HandshakeTimeout,
+		Subprotocols:      options.Subprotocols,
+	}
+
+	if upgrader.CheckOrigin == nil && len(options.AllowedOrigins) > 0 {
+		upgrader.CheckOrigin = graphqlws.OriginChecker(options.AllowedOrigins)
+	}
+
+	if len(upgrader.Subprotocols) == 0 {
+		upgrader.Subprotocols = []string{"graphql-ws"}
+	}
+
+	return upgrader
+}
+
+// Schema returns the schema new operations are executed against
+func (s *Server) Schema() graphql.Schema {
+	return *s.executor.Schema()
+}
+
+// SetSchema atomically replaces the schema. Operations that already started,
+// including active subscriptions, finish on the previous schema and new
+// operations use the new one. Documents cached for the previous schema are
+// not reused
+func (s *Server) SetSchema(schema graphql.Schema) {
+	s.executor.SetSchema(&schema)
+}
+
+// DocumentCache returns the document cache, or nil when it is disabled
+func (s *Server) DocumentCache() *executor.DocumentCache {
+	return s.cache
+}
+
+// RequestIDHeader carries the request ID added to log lines, an ID is
+// generated for requests without one
+const RequestIDHeader = "X-Request-ID"
+
+// returns the logger of a request tagged with its request ID
+func (s *Server) requestLogger(r *http.Request) logger.Logger {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" {
+		id = uuid.New().String()
+	}
+	return s.log.With(logger.RequestIDKey, id)
 }
 
 func IsWSUpgrade(r *http.Request) bool {
@@ -83,7 +279,6 @@ func IsWSUpgrade(r *http.Request) bool {
 // ServeHTTP provides an entrypoint into executing graphQL queries.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if IsWSUpgrade(r) {
-		s.log.Debugf("Upgrading connection to websocket")
 		ctx := r.Context()
 		if s.options.WSContextFunc != nil {
 			ctx = s.options.WSContextFunc(r)
@@ -97,3 +292,79 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.ContextHandler(ctx, w, r)
 	}
 }
+
+// Shutdown gracefully closes the WebSocket connections. New upgrades are
+// refused, active operations are sent complete and every connection is closed
+// with a going away close frame, which cancels the operation contexts.
+// Shutdown waits for the connections and operations to finish or for ctx to
+// be done. WebSocket connections are hijacked, so http.Server.Shutdown does
+// not wait for them and both should be called
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.connMutex.Lock()
+	s.shuttingDown = true
+	connections := make([]graphqlws.Connection, 0, len(s.connections))
+	for _, wsConn := range s.connections {
+		connections = append(connections, wsConn.conn)
+	}
+	s.connMutex.Unlock()
+
+	for _, conn := range connections {
+		conn.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserves a connection with the wait group unless the server is shutting down
+func (s *Server) reserveConnection() bool {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	if s.shuttingDown {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+// registers an established connection, connections that closed right away
+// have already been removed
+func (s *Server) addConnection(conn graphqlws.Connection, r *http.Request) {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	select {
+	case <-conn.Done():
+		return
+	default:
+	}
+
+	s.connections[conn.ID()] = &wsConnection{
+		conn:       conn,
+		remoteAddr: r.RemoteAddr,
+		startedAt:  time.Now(),
+	}
+	if s.shuttingDown {
+		// Shutdown has already closed the other connections
+		go conn.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// unregisters a closed connection and releases its reservation
+func (s *Server) removeConnection(conn graphqlws.Connection) {
+	s.connMutex.Lock()
+	delete(s.connections, conn.ID())
+	s.connMutex.Unlock()
+	s.wg.Done()
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql-go-tools/server/graphqlws"
+	"github.com/gorilla/websocket"
+)
+
+// starts a server with a ticks subscription that never sends and dials an
+// acknowledged graphql-ws connection
+func testWSServer(t *testing.T, options *Options) (*Server, string, *websocket.Conn) {
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `
+type Query {
+	hello: String
+}
+
+type Subscription {
+	ticks: Int
+}`,
+		Resolvers: map[string]any{
+			"Subscription": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"ticks": &tools.FieldResolve{
+						Subscribe: func(p graphql.ResolveParams) (any, error) {
+							return make(chan any), nil
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to make schema: %v", err)
+	}
+
+	if options.WS == nil {
+		options.WS = &WSOptions{}
+	}
+	options.WS.CheckOrigin = func(*http.Request) bool { return true }
+
+	s := New(schema, options)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+	ws, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	ws.WriteJSON(graphqlws.OperationMessage{Type: "connection_init", Payload: map[string]any{}})
+	var msg graphqlws.OperationMessage
+	if err := ws.ReadJSON(&msg); err != nil || msg.Type != "connection_ack" {
+		t.Fatalf("expected ack, got %v %v", msg, err)
+	}
+
+	return s, url, ws
+}
+
+// starts a ticks subscription and waits for it to be registered
+func startTicks(t *testing.T, s *Server, ws *websocket.Conn, opID string) {
+	ws.WriteJSON(graphqlws.OperationMessage{
+		ID:      opID,
+		Type:    "start",
+		Payload: graphqlws.StartMessagePayload{Query: "subscription Ticks { ticks }", OperationName: "Ticks"},
+	})
+
+	for i := 0; i < 100; i++ {
+		for _, conn := range s.Connections() {
+			for _, op := range s.Operations(conn.ID) {
+				if op.ID == opID {
+					return
+				}
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("operation %s was not registered", opID)
+}
+
+func TestWSOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *WSOptions
+		origin  string
+		ok      bool
+	}{
+		{"default same origin", nil, "", true},
+		{"default cross origin", nil, "https://other.example.com", false},
+		{"allow every origin", &WSOptions{AllowedOrigins: []string{"*"}}, "https://other.example.com", true},
+		{"allowed origin", &WSOptions{AllowedOrigins: []string{"https://app.example.com"}}, "https://app.example.com", true},
+		{"other origin", &WSOptions{AllowedOrigins: []string{"https://app.example.com"}}, "https://other.example.com", false},
+	}
+
+	for _, test := range tests {
+		schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+			TypeDefs: `type Query { hello: String }`,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		srv := httptest.NewServer(New(schema, &Options{WS: test.options}))
+		url := "ws" + strings.TrimPrefix(srv.URL, "http")
+		origin := test.origin
+		if origin == "" {
+			origin = srv.URL
+		}
+
+		dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+		ws, _, err := dialer.Dial(url, http.Header{"Origin": []string{origin}})
+		if err == nil {
+			ws.Close()
+		}
+		srv.Close()
+
+		if (err == nil) != test.ok {
+			t.Errorf("%s: expected the upgrade to succeed %v, got %v", test.name, test.ok, err)
+		}
+	}
+}
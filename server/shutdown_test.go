@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql-go-tools/server/graphqlws"
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdown(t *testing.T) {
+	s, url, ws := testWSServer(t, &Options{})
+	startTicks(t, s, ws, "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(ctx) }()
+
+	var msg graphqlws.OperationMessage
+	if err := ws.ReadJSON(&msg); err != nil || msg.Type != "complete" || msg.ID != "1" {
+		t.Errorf("expected complete, got %v %v", msg, err)
+		return
+	}
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected going away, got %v", err)
+		return
+	}
+
+	if err := <-shutdown; err != nil {
+		t.Errorf("shutdown failed: %v", err)
+		return
+	}
+	if connections := s.Connections(); len(connections) != 0 {
+		t.Errorf("expected no connections, got %d", len(connections))
+		return
+	}
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+	if _, resp, err := dialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected upgrades to be refused, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+// WatchOptions configures Server.WatchSchema
+type WatchOptions struct {
+	// Dir contains the .graphql and .gql files of the TypeDefs
+	Dir string
+
+	// Recursive also reads the files of subdirectories
+	Recursive bool
+
+	// Interval is the time between polls, defaults to 2 seconds
+	Interval time.Duration
+
+	// Config builds the schema, its TypeDefs are replaced by the files of Dir
+	Config tools.ExecutableSchema
+
+	// OnReload is called with the result of every rebuild, err is nil when
+	// the schema was replaced
+	OnReload func(err error)
+}
+
+// WatchSchema polls the TypeDefs directory and rebuilds the schema with the
+// same config when the files change. The schema is only replaced when the
+// build succeeds, otherwise the error is logged and the server keeps the
+// current schema. The files are assumed to match the current schema when the
+// watch starts. WatchSchema returns once the files have been read and the
+// watch stops when ctx is done
+func (s *Server) WatchSchema(ctx context.Context, options WatchOptions) error {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	last, err := tools.ReadSourceFiles(options.Dir, options.Recursive)
+	if err != nil {
+		return err
+	}
+
+	go s.watchSchema(ctx, options, interval, last)
+	return nil
+}
+
+func (s *Server) watchSchema(ctx context.Context, options WatchOptions, interval time.Duration, last string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		typeDefs, err := tools.ReadSourceFiles(options.Dir, options.Recursive)
+		if err != nil {
+			// files may be missing while an editor saves them
+			s.log.Warn("failed to read schema files", "dir", options.Dir, "error", err)
+			continue
+		}
+		if typeDefs == last {
+			continue
+		}
+		last = typeDefs
+
+		config := options.Config
+		config.TypeDefs = typeDefs
+		schema, err := tools.MakeExecutableSchemaWithContext(ctx, config)
+		if err != nil {
+			s.log.Error("failed to rebuild schema, keeping the current schema", "dir", options.Dir, "error", err)
+		} else {
+			s.SetSchema(schema)
+			s.log.Info("schema reloaded", "dir", options.Dir)
+		}
+
+		if options.OnReload != nil {
+			options.OnReload(err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+)
+
+// posts a query to the server and returns the decoded result
+func postQuery(s *Server, query string) map[string]any {
+	body, _ := json.Marshal(map[string]any{"query": query})
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", ContentTypeJSON)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	result := map[string]any{}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return result
+}
+
+func TestSetSchema(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	config := tools.ExecutableSchema{
+		TypeDefs: `type Query { hello: String, slow: String }`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello": &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "old", nil }},
+					"slow": &tools.FieldResolve{
+						Resolve: func(p graphql.ResolveParams) (any, error) {
+							close(started)
+							<-release
+							return "old", nil
+						},
+					},
+				},
+			},
+		},
+	}
+	schema, err := tools.MakeExecutableSchema(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s := New(schema, &Options{DocumentCacheSize: 10})
+
+	// an operation in flight when the schema is replaced finishes on it
+	done := make(chan map[string]any)
+	go func() { done <- postQuery(s, "{ hello slow }") }()
+	<-started
+
+	newSchema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `type Query { hello: String, world: String }`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello": &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "new", nil }},
+					"world": &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "world", nil }},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s.SetSchema(newSchema)
+	close(release)
+
+	if result := <-done; result["data"].(map[string]any)["hello"] != "old" {
+		t.Errorf("expected the in flight operation to use the old schema, got %v", result)
+		return
+	}
+	if result := postQuery(s, "{ hello world }"); result["data"].(map[string]any)["world"] != "world" {
+		t.Errorf("expected new operations to use the new schema, got %v", result)
+	}
+}
+
+func TestWatchSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.graphql")
+	write := func(typeDefs string) {
+		if err := os.WriteFile(path, []byte(typeDefs), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := tools.ExecutableSchema{
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello": &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "hello", nil }},
+					"world": &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "world", nil }},
+				},
+			},
+		},
+	}
+	write(`type Query { hello: String }`)
+	config.TypeDefs = `type Query { hello: String }`
+	schema, err := tools.MakeExecutableSchema(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s := New(schema, &Options{})
+
+	reloads := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.WatchSchema(ctx, WatchOptions{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		Config:   config,
+		OnReload: func(err error) { reloads <- err },
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// a failed build keeps the current schema
+	write(`type Query { hello: Missing }`)
+	if err := <-reloads; err == nil {
+		t.Error("expected the invalid schema to fail")
+		return
+	}
+	if result := postQuery(s, "{ hello }"); result["data"].(map[string]any)["hello"] != "hello" {
+		t.Errorf("expected the current schema to be kept, got %v", result)
+		return
+	}
+
+	write(`type Query { hello: String, world: String }`)
+	if err := <-reloads; err != nil {
+		t.Errorf("failed to reload schema: %v", err)
+		return
+	}
+	if result := postQuery(s, "{ world }"); result["data"].(map[string]any)["world"] != "world" {
+		t.Errorf("expected the reloaded schema, got %v", result)
+	}
+}
@@ -0,0 +1,134 @@
+package trusted
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Store holds the trusted documents by ID
+type Store struct {
+	mx        sync.RWMutex
+	documents map[string]string
+	texts     map[string]bool
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		documents: make(map[string]string),
+		texts:     make(map[string]bool),
+	}
+}
+
+// Hash returns the SHA-256 hex digest of a document, the ID clients send for
+// documents registered without an explicit ID
+func Hash(document string) string {
+	sum := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add registers a document under an ID, an empty ID uses the hash of the
+// document
+func (s *Store) Add(id, document string) {
+	if id == "" {
+		id = Hash(document)
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.documents[id] = document
+	s.texts[document] = true
+}
+
+// Get returns the document of an ID
+func (s *Store) Get(id string) (string, bool) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	document, ok := s.documents[id]
+	return document, ok
+}
+
+// Contains reports whether the exact text of a document is registered
+func (s *Store) Contains(document string) bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.texts[document]
+}
+
+// Len returns the number of documents
+func (s *Store) Len() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return len(s.documents)
+}
+
+// LoadManifest reads a manifest file into a new store
+func LoadManifest(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewStore()
+	if err := store.AddManifest(data); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// AddManifest registers the documents of a manifest, the format is detected
+// from its content:
+//
+//   - Apollo persisted query manifests: {"format":
+//     "apollo-persisted-query-manifest", "operations": [{"id": "...", "body":
+//     "..."}]}
+//   - Relay compiler persisted queries, an object mapping IDs to documents
+//   - JSON arrays of documents, identified by their hash
+func (s *Store) AddManifest(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return s.addJSON(data)
+	}
+
+	var apollo struct {
+		Format     string `json:"format"`
+		Operations []struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"operations"`
+	}
+	if err := json.Unmarshal(data, &apollo); err == nil && apollo.Format != "" {
+		if apollo.Format != "apollo-persisted-query-manifest" {
+			return fmt.Errorf("unsupported manifest format %q", apollo.Format)
+		}
+		for _, op := range apollo.Operations {
+			s.Add(op.ID, op.Body)
+		}
+		return nil
+	}
+
+	relay := map[string]string{}
+	if err := json.Unmarshal(data, &relay); err != nil {
+		return fmt.Errorf("expected an Apollo, Relay or JSON manifest: %w", err)
+	}
+	for id, document := range relay {
+		s.Add(id, document)
+	}
+	return nil
+}
+
+func (s *Store) addJSON(data []byte) error {
+	documents := []string{}
+	if err := json.Unmarshal(data, &documents); err != nil {
+		return fmt.Errorf("expected an array of documents: %w", err)
+	}
+	for _, document := range documents {
+		s.Add("", document)
+	}
+	return nil
+}
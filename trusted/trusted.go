@@ -0,0 +1,213 @@
+// Package trusted restricts the operations a server runs to a set of trusted
+// documents registered ahead of time, like the operations of first party apps
+package trusted
+
+import (
+	"context"
+	"strings"
+
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql-go-tools/server/logger"
+	"github.com/dagger/graphql/gqlerrors"
+	"github.com/dagger/graphql/language/ast"
+)
+
+// Mode decides what happens to operations that are not trusted
+type Mode string
+
+// Modes
+const (
+	// ModeEnforce rejects operations that are not trusted
+	ModeEnforce Mode = "enforce"
+
+	// ModeLogOnly runs operations that are not trusted and logs them, to find
+	// the operations missing from the store before enforcing it
+	ModeLogOnly Mode = "log-only"
+)
+
+// Error codes
+const (
+	CodeDocumentNotFound      = "PERSISTED_QUERY_NOT_FOUND"
+	CodeUntrustedDocument     = "UNTRUSTED_DOCUMENT"
+	CodeIntrospectionDisabled = "INTROSPECTION_DISABLED"
+)
+
+// Config configures trusted documents
+type Config struct {
+	Store *Store
+
+	// Mode defaults to ModeEnforce
+	Mode Mode
+
+	// AllowIntrospection runs introspection queries that are not trusted in
+	// both modes, they are rejected otherwise
+	AllowIntrospection bool
+
+	// Logger reports the operations that are not trusted in ModeLogOnly, the
+	// logger of the operation context is used when it is not set
+	Logger logger.Logger
+}
+
+// TrustedDocuments checks that operations are trusted documents
+type TrustedDocuments struct {
+	store              *Store
+	mode               Mode
+	allowIntrospection bool
+	log                logger.Logger
+}
+
+// New creates trusted documents from a config
+func New(config Config) *TrustedDocuments {
+	if config.Store == nil {
+		config.Store = NewStore()
+	}
+	if config.Mode == "" {
+		config.Mode = ModeEnforce
+	}
+
+	return &TrustedDocuments{
+		store:              config.Store,
+		mode:               config.Mode,
+		allowIntrospection: config.AllowIntrospection,
+		log:                config.Logger,
+	}
+}
+
+// Store returns the store of the trusted documents
+func (t *TrustedDocuments) Store() *Store {
+	return t.store
+}
+
+// Plugin replaces the document IDs of requests with their documents and
+// checks the documents sent as text, it should run before other plugins
+func (t *TrustedDocuments) Plugin() executor.Plugin {
+	return &plugin{t: t}
+}
+
+// DocumentID returns the ID of the document of a request, either its
+// documentId or the hash of an Apollo persisted query extension
+func DocumentID(documentID string, extensions map[string]any) string {
+	if documentID != "" {
+		return documentID
+	}
+	if persisted, ok := extensions["persistedQuery"].(map[string]any); ok {
+		if hash, ok := persisted["sha256Hash"].(string); ok {
+			return hash
+		}
+	}
+	return ""
+}
+
+// creates an error whose code is sent as an extension
+func newError(code, message string) error {
+	return &gqlerrors.Error{
+		Message:       message,
+		OriginalError: tools.NewError(code, message, nil),
+	}
+}
+
+type untrustedKey struct{}
+
+type plugin struct {
+	executor.NoopPlugin
+	t *TrustedDocuments
+}
+
+func (p *plugin) OnRequest(ctx context.Context, req *executor.Request) (context.Context, error) {
+	if id := DocumentID(req.DocumentID, req.Extensions); id != "" {
+		document, ok := p.t.store.Get(id)
+		if !ok {
+			return ctx, newError(CodeDocumentNotFound, "document "+id+" is not trusted")
+		}
+		req.Query = document
+		return ctx, nil
+	}
+
+	if req.Query != "" && p.t.store.Contains(req.Query) {
+		return ctx, nil
+	}
+
+	// the document is parsed before deciding whether it is an introspection
+	// query
+	return context.WithValue(ctx, untrustedKey{}, true), nil
+}
+
+func (p *plugin) OnParse(ctx context.Context, req *executor.Request, doc *ast.Document) error {
+	if untrusted, _ := ctx.Value(untrustedKey{}).(bool); !untrusted {
+		return nil
+	}
+
+	if isIntrospection(doc, req.OperationName) {
+		if p.t.allowIntrospection {
+			return nil
+		}
+		return newError(CodeIntrospectionDisabled, "introspection is disabled")
+	}
+
+	if p.t.mode == ModeLogOnly {
+		log := p.t.log
+		if log == nil {
+			log = logger.FromContext(ctx)
+		}
+		log.Warn("untrusted operation",
+			logger.OperationNameKey, req.OperationName,
+			"documentHash", Hash(req.Query),
+			"transport", req.Transport,
+		)
+		return nil
+	}
+
+	return newError(CodeUntrustedDocument, "only trusted documents are allowed")
+}
+
+// reports whether the operation of a document only selects introspection
+// fields
+func isIntrospection(doc *ast.Document, operationName string) bool {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				operation = d
+			}
+		}
+	}
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return false
+	}
+	return onlyIntrospection(operation.SelectionSet, fragments, map[string]bool{})
+}
+
+func onlyIntrospection(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, visited map[string]bool) bool {
+	if set == nil {
+		return false
+	}
+
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if !strings.HasPrefix(s.Name.Value, "__") {
+				return false
+			}
+		case *ast.InlineFragment:
+			if !onlyIntrospection(s.SelectionSet, fragments, visited) {
+				return false
+			}
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			fragment, ok := fragments[name]
+			if !ok || !onlyIntrospection(fragment.SelectionSet, fragments, visited) {
+				return false
+			}
+		}
+	}
+	return true
+}
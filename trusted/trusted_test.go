@@ -0,0 +1,121 @@
+package trusted
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dagger/graphql"
+	tools "github.com/dagger/graphql-go-tools"
+	"github.com/dagger/graphql-go-tools/executor"
+	"github.com/dagger/graphql-go-tools/server/logger"
+)
+
+const helloQuery = `query Hello { hello }`
+
+func TestAddManifest(t *testing.T) {
+	tests := []struct {
+		manifest string
+		id       string
+	}{
+		{`{"format": "apollo-persisted-query-manifest", "version": 1, "operations": [{"id": "abc", "name": "Hello", "type": "query", "body": "query Hello { hello }"}]}`, "abc"},
+		{`{"abc": "query Hello { hello }"}`, "abc"},
+		{`["query Hello { hello }"]`, Hash(helloQuery)},
+	}
+
+	for _, test := range tests {
+		store := NewStore()
+		if err := store.AddManifest([]byte(test.manifest)); err != nil {
+			t.Errorf("failed to add manifest %s: %v", test.manifest, err)
+			return
+		}
+		if document, ok := store.Get(test.id); !ok || document != helloQuery {
+			t.Errorf("expected document %s in manifest %s", test.id, test.manifest)
+			return
+		}
+		if !store.Contains(helloQuery) {
+			t.Errorf("expected the text of manifest %s", test.manifest)
+			return
+		}
+	}
+
+	if err := NewStore().AddManifest([]byte(`{"format": "other", "operations": []}`)); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+type recordLogger struct {
+	logger.NoopLogger
+	messages []string
+}
+
+func (l *recordLogger) Warn(msg string, fields ...any) {
+	l.messages = append(l.messages, msg)
+}
+
+func TestTrustedDocuments(t *testing.T) {
+	schema, err := tools.MakeExecutableSchema(tools.ExecutableSchema{
+		TypeDefs: `type Query { hello: String, secret: String }`,
+		Resolvers: map[string]any{
+			"Query": &tools.ObjectResolver{
+				Fields: tools.FieldResolveMap{
+					"hello":  &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "world", nil }},
+					"secret": &tools.FieldResolve{Resolve: func(p graphql.ResolveParams) (any, error) { return "secret", nil }},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	store := NewStore()
+	store.Add("hello", helloQuery)
+
+	log := &recordLogger{}
+	tests := []struct {
+		config Config
+		req    executor.Request
+		code   string
+	}{
+		{Config{}, executor.Request{DocumentID: "hello"}, ""},
+		{Config{}, executor.Request{Extensions: map[string]any{"persistedQuery": map[string]any{"version": 1, "sha256Hash": "hello"}}}, ""},
+		{Config{}, executor.Request{Query: helloQuery}, ""},
+		{Config{}, executor.Request{DocumentID: "missing"}, CodeDocumentNotFound},
+		{Config{}, executor.Request{Query: `{ secret }`}, CodeUntrustedDocument},
+		{Config{}, executor.Request{Query: `{ __schema { queryType { name } } }`}, CodeIntrospectionDisabled},
+		{Config{AllowIntrospection: true}, executor.Request{Query: `{ ...Schema } fragment Schema on Query { __schema { queryType { name } } }`}, ""},
+		{Config{AllowIntrospection: true}, executor.Request{Query: `{ __typename secret }`}, CodeUntrustedDocument},
+		{Config{Mode: ModeLogOnly, Logger: log}, executor.Request{Query: `{ secret }`}, ""},
+		{Config{Mode: ModeLogOnly}, executor.Request{Query: `{ __schema { queryType { name } } }`}, CodeIntrospectionDisabled},
+	}
+
+	for _, test := range tests {
+		test.config.Store = store
+		exec := executor.New(&executor.Config{
+			Schema:  &schema,
+			Plugins: []executor.Plugin{New(test.config).Plugin()},
+		})
+
+		req := test.req
+		req.Context = context.Background()
+		result := exec.Execute(&req)
+
+		code := ""
+		if len(result.Errors) > 0 {
+			code, _ = result.Errors[0].Extensions["code"].(string)
+		}
+		if code != test.code {
+			t.Errorf("%+v: expected code %q, got %v", test.req, test.code, result.Errors)
+			return
+		}
+		if code == "" && result.HasErrors() {
+			t.Errorf("%+v: unexpected errors %v", test.req, result.Errors)
+			return
+		}
+	}
+
+	if len(log.messages) != 1 || log.messages[0] != "untrusted operation" {
+		t.Errorf("expected the untrusted operation to be logged, got %v", log.messages)
+	}
+}